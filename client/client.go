@@ -0,0 +1,328 @@
+// Package client 是 MiniArena 的 Go 客户端：连接 /ws，管理输入序列与未确认输入，
+// 将 snapshot / state / delta 应用到本地世界，并按服务器确认序列做预测回滚。
+// 供工具、机器人与集成测试使用，行为与 web/app.js 一致。
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Options 连接参数
+type Options struct {
+	URL    string // 服务地址，如 ws://localhost:8080（自动补全 /ws 路径）
+	Room   string // 默认 room-1
+	Player string // 必填
+	Team   string // 期望加入的队伍（可选，服务端按人数平衡决定）
+
+	// 本地预测参数，需与房间配置一致（默认步长 1，不裁剪边界）
+	Step   float64 // 每条输入的步长：房间 speed / maxInputsPerSecond
+	Width  float64
+	Height float64
+
+	Dialer *websocket.Dialer
+	Header http.Header
+
+	Handlers Handlers
+}
+
+// Handlers 回调均在读协程中调用，世界状态已更新且不持有锁；回调中不应长时间阻塞
+type Handlers struct {
+	// OnWorld 每条世界状态消息（snapshot / state / delta）应用后调用
+	OnWorld func(kind string, w World)
+	// OnReconcile 预测位置被服务器纠正时调用（before 为纠正前的预测位置）
+	OnReconcile func(before, after PlayerState, ack int64)
+	// OnMessage 其他类型的消息（如 room_closed），raw 为完整 JSON
+	OnMessage func(kind string, raw []byte)
+	// OnClose 连接断开时调用一次
+	OnClose func(err error)
+}
+
+// Stats 连接统计
+type Stats struct {
+	Messages int64
+	Bytes    int64
+	ByType   map[string]int64
+}
+
+// Client 单个玩家连接
+type Client struct {
+	opts Options
+	conn *websocket.Conn
+
+	mu      sync.Mutex
+	model   *model
+	stats   Stats
+	sentAt  map[int64]time.Time // 输入发送时间（计算输入到确认的延迟）
+	latency func(seq int64, d time.Duration)
+
+	writeMu sync.Mutex
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// ErrClosed 连接已关闭
+var ErrClosed = errors.New("client: connection closed")
+
+// Dial 连接服务器，并等待初始快照（保证首条输入使用正确的序列号）
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.Player == "" {
+		return nil, errors.New("client: player is required")
+	}
+	if opts.Room == "" {
+		opts.Room = "room-1"
+	}
+	if opts.Step <= 0 {
+		opts.Step = 1
+	}
+	u, err := wsURL(opts)
+	if err != nil {
+		return nil, err
+	}
+	dialer := opts.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, _, err := dialer.DialContext(ctx, u, opts.Header)
+	if err != nil {
+		return nil, fmt.Errorf("client: dial %s: %w", u, err)
+	}
+	c := &Client{
+		opts:   opts,
+		conn:   conn,
+		model:  newModel(opts.Player, opts.Step, opts.Width, opts.Height),
+		stats:  Stats{ByType: make(map[string]int64)},
+		sentAt: make(map[int64]time.Time),
+		done:   make(chan struct{}),
+	}
+	// 同步读取初始快照
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	for {
+		kind, err := c.readOne()
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("client: waiting for snapshot: %w", err)
+		}
+		if kind == "snapshot" || kind == "state" {
+			break
+		}
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	go c.readLoop()
+	return c, nil
+}
+
+func wsURL(opts Options) (string, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return "", fmt.Errorf("client: bad url: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/ws"
+	}
+	q := u.Query()
+	q.Set("room", opts.Room)
+	q.Set("player", opts.Player)
+	if opts.Team != "" {
+		q.Set("team", opts.Team)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Move 本地预测一步并发送 move 输入，返回序列号
+func (c *Client) Move(dir Direction) (int64, error) {
+	select {
+	case <-c.done:
+		return 0, ErrClosed
+	default:
+	}
+	c.mu.Lock()
+	seq := c.model.predict(dir)
+	if c.latency != nil {
+		c.sentAt[seq] = time.Now()
+	}
+	c.mu.Unlock()
+	b, _ := json.Marshal(struct {
+		Type    string    `json:"type"`
+		Command Direction `json:"command"`
+		Seq     int64     `json:"seq"`
+	}{"move", dir, seq})
+	if err := c.Send(b); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// Send 发送一条原始文本消息（用于 move 以外的消息类型）
+func (c *Client) Send(msg []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		c.shutdown(err)
+		return err
+	}
+	return nil
+}
+
+// OnAckLatency 记录每条输入从发送到被服务器确认的耗时（需在发送输入前设置）
+func (c *Client) OnAckLatency(fn func(seq int64, d time.Duration)) {
+	c.mu.Lock()
+	c.latency = fn
+	c.mu.Unlock()
+}
+
+// World 当前本地世界视图（拷贝）
+func (c *Client) World() World {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.model.world()
+}
+
+// Predicted 自己的预测位置
+func (c *Client) Predicted() (PlayerState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.model.predicted, c.model.hasPred
+}
+
+// Ack 服务器已确认的最大输入序列号
+func (c *Client) Ack() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.model.lastAck
+}
+
+// Pending 尚未确认的输入（拷贝）
+func (c *Client) Pending() []PendingInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]PendingInput(nil), c.model.pending...)
+}
+
+// Stats 接收统计（拷贝）
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.ByType = make(map[string]int64, len(c.stats.ByType))
+	for k, v := range c.stats.ByType {
+		s.ByType[k] = v
+	}
+	return s
+}
+
+// Done 连接断开时关闭
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// Err 断开原因（主动 Close 时为 ErrClosed）
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Close 发送关闭帧并断开
+func (c *Client) Close() error {
+	c.writeMu.Lock()
+	_ = c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	c.shutdown(ErrClosed)
+	return nil
+}
+
+func (c *Client) shutdown(err error) {
+	c.once.Do(func() {
+		c.err = err
+		_ = c.conn.Close()
+		close(c.done)
+		if c.opts.Handlers.OnClose != nil {
+			c.opts.Handlers.OnClose(err)
+		}
+	})
+}
+
+func (c *Client) readLoop() {
+	for {
+		if _, err := c.readOne(); err != nil {
+			c.shutdown(err)
+			return
+		}
+	}
+}
+
+// readOne 读取并应用一条消息，返回消息类型
+func (c *Client) readOne() (string, error) {
+	_, raw, err := c.conn.ReadMessage()
+	if err != nil {
+		return "", err
+	}
+	var msg message
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return "", nil // 忽略无法解析的消息
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.stats.Messages++
+	c.stats.Bytes += int64(len(raw))
+	c.stats.ByType[msg.Type]++
+	before, hadPred := c.model.predicted, c.model.hasPred
+	prevAck := c.model.lastAck
+	isWorld := c.model.apply(&msg)
+	var w World
+	var acked []time.Duration
+	var ackedSeq []int64
+	if isWorld {
+		if c.opts.Handlers.OnWorld != nil {
+			w = c.model.world()
+		}
+		if c.latency != nil {
+			for seq := prevAck + 1; seq <= c.model.lastAck; seq++ {
+				if t, ok := c.sentAt[seq]; ok {
+					acked = append(acked, now.Sub(t))
+					ackedSeq = append(ackedSeq, seq)
+					delete(c.sentAt, seq)
+				}
+			}
+		}
+	}
+	after, ack, latency := c.model.predicted, c.model.lastAck, c.latency
+	c.mu.Unlock()
+
+	h := c.opts.Handlers
+	if !isWorld {
+		if h.OnMessage != nil {
+			h.OnMessage(msg.Type, raw)
+		}
+		return msg.Type, nil
+	}
+	for i, d := range acked {
+		latency(ackedSeq[i], d)
+	}
+	if h.OnReconcile != nil && hadPred && before != after {
+		h.OnReconcile(before, after, ack)
+	}
+	if h.OnWorld != nil {
+		h.OnWorld(msg.Type, w)
+	}
+	return msg.Type, nil
+}
@@ -0,0 +1,278 @@
+package client
+
+// Direction 移动方向（与服务端 move 消息的 command 一致）
+type Direction string
+
+const (
+	Up    Direction = "up"
+	Down  Direction = "down"
+	Left  Direction = "left"
+	Right Direction = "right"
+)
+
+// PlayerState 玩家位置
+type PlayerState struct {
+	ID   string  `json:"id"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Team string  `json:"team,omitempty"`
+}
+
+// TeamState 队伍与分数
+type TeamState struct {
+	ID    string `json:"id"`
+	Color string `json:"color"`
+	Score int    `json:"score"`
+}
+
+// FlagState 夺旗玩法的旗帜
+type FlagState struct {
+	Team     string  `json:"team"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	State    string  `json:"state"` // base | carried | dropped
+	Carrier  string  `json:"carrier,omitempty"`
+	ReturnMs int64   `json:"returnMs,omitempty"` // 掉落后距自动归位的剩余时间
+}
+
+// ZoneState 占山为王玩法的得分区域
+type ZoneState struct {
+	Name  string  `json:"name"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	W     float64 `json:"w"`
+	H     float64 `json:"h"`
+	State string  `json:"state"`           // inactive | neutral | held | contested
+	Owner string  `json:"owner,omitempty"` // 独占者：队伍 ID 或玩家 ID
+}
+
+// World 本地世界视图：其他玩家为服务器权威位置，Self 为本地预测位置
+type World struct {
+	Tick     int64
+	TickRate int
+	Players  map[string]PlayerState // 含自己（权威位置）
+	Self     PlayerState            // 自己的预测位置（权威位置 + 未确认输入重演）
+	HasSelf  bool
+	Teams    map[string]TeamState // 房间未分队时为空
+	Flags    []FlagState          // 夺旗玩法的旗帜
+	Zones    []ZoneState          // 占山为王玩法的区域
+	Scores   map[string]int       // 玩法下发的个人得分（占山为王）
+}
+
+// PendingInput 已发送但尚未被服务器确认的输入
+type PendingInput struct {
+	Seq int64
+	Dir Direction
+}
+
+// message 服务端下发的消息（state / snapshot / delta 及其他类型）
+type message struct {
+	Tick     int64          `json:"tick"`
+	Ack      int64          `json:"ack"`
+	Type     string         `json:"type"`
+	TickRate int            `json:"tickRate"`
+	Players  []PlayerState  `json:"players"`
+	Removed  []string       `json:"removed"`
+	Teams    []TeamState    `json:"teams"`  // 全量时总是携带，增量仅在分数变化时携带
+	Flags    []FlagState    `json:"flags"`  // 同上，增量仅在旗帜变化时携带（完整列表）
+	Zones    []ZoneState    `json:"zones"`  // 同上，增量仅在区域变化时携带（完整列表）
+	Scores   map[string]int `json:"scores"` // 随 zones 携带完整得分；单独出现时只含变化的玩家
+}
+
+// model 预测与回滚状态，不涉及网络，由 Client 加锁访问
+type model struct {
+	self   string
+	step   float64
+	width  float64
+	height float64
+
+	tick     int64
+	tickRate int
+	players  map[string]PlayerState
+	teams    map[string]TeamState
+	flags    []FlagState
+	zones    []ZoneState
+	scores   map[string]int
+	authSelf *PlayerState // 最近一次服务器确认的自己的位置
+
+	nextSeq   int64
+	lastAck   int64
+	pending   []PendingInput
+	predicted PlayerState
+	hasPred   bool
+}
+
+func newModel(self string, step, width, height float64) *model {
+	return &model{
+		self:    self,
+		step:    step,
+		width:   width,
+		height:  height,
+		players: make(map[string]PlayerState),
+		teams:   make(map[string]TeamState),
+		nextSeq: 1,
+	}
+}
+
+// apply 应用一条权威消息并重新预测；返回是否为世界状态消息
+func (m *model) apply(msg *message) bool {
+	switch msg.Type {
+	case "snapshot", "state":
+		// 全量：替换整个世界
+		clear(m.players)
+		clear(m.teams)
+		m.flags = nil
+		m.zones, m.scores = nil, nil
+		m.authSelf = nil
+		for _, p := range msg.Players {
+			m.setPlayer(p)
+		}
+		if msg.TickRate > 0 {
+			m.tickRate = msg.TickRate
+		}
+	case "delta":
+		for _, id := range msg.Removed {
+			delete(m.players, id)
+			if id == m.self {
+				m.authSelf = nil
+			}
+		}
+		for _, p := range msg.Players {
+			m.setPlayer(p)
+		}
+	default:
+		return false
+	}
+	for _, t := range msg.Teams {
+		m.teams[t.ID] = t
+	}
+	if msg.Flags != nil {
+		m.flags = msg.Flags
+	}
+	if msg.Zones != nil {
+		m.zones, m.scores = msg.Zones, msg.Scores
+	} else if msg.Scores != nil {
+		if m.scores == nil {
+			m.scores = make(map[string]int, len(msg.Scores))
+		}
+		for id, n := range msg.Scores {
+			m.scores[id] = n
+		}
+	}
+	m.tick = msg.Tick
+	m.acknowledge(msg.Ack)
+	m.repredict()
+	return true
+}
+
+func (m *model) setPlayer(p PlayerState) {
+	m.players[p.ID] = p
+	if p.ID == m.self {
+		st := p
+		m.authSelf = &st
+	}
+}
+
+// acknowledge 丢弃已确认输入，并按确认序列推进 nextSeq（重连后避免从 1 发送被判旧包）
+func (m *model) acknowledge(ack int64) {
+	if ack > m.lastAck {
+		m.lastAck = ack
+	}
+	n := 0
+	for n < len(m.pending) && m.pending[n].Seq <= ack {
+		n++
+	}
+	m.pending = append(m.pending[:0], m.pending[n:]...)
+	if ack+1 > m.nextSeq {
+		m.nextSeq = ack + 1
+	}
+}
+
+// repredict 以权威位置为起点重演全部未确认输入
+func (m *model) repredict() {
+	var base PlayerState
+	switch {
+	case m.authSelf != nil:
+		base = *m.authSelf
+	case m.hasPred:
+		base = m.predicted
+	default:
+		return
+	}
+	for _, in := range m.pending {
+		base = m.move(base, in.Dir)
+	}
+	m.predicted = base
+	m.hasPred = true
+}
+
+// predict 记录一条新输入并立即在本地生效，返回其序列号
+func (m *model) predict(dir Direction) int64 {
+	seq := m.nextSeq
+	m.nextSeq++
+	m.pending = append(m.pending, PendingInput{Seq: seq, Dir: dir})
+	if m.hasPred {
+		m.predicted = m.move(m.predicted, dir)
+	}
+	return seq
+}
+
+// move 与服务端 applyMove 相同的移动与越界裁剪
+func (m *model) move(p PlayerState, dir Direction) PlayerState {
+	switch dir {
+	case Up:
+		p.Y -= m.step
+	case Down:
+		p.Y += m.step
+	case Left:
+		p.X -= m.step
+	case Right:
+		p.X += m.step
+	}
+	if m.width > 0 {
+		p.X = clamp(p.X, 0, m.width)
+	}
+	if m.height > 0 {
+		p.Y = clamp(p.Y, 0, m.height)
+	}
+	return p
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// world 拷贝当前世界视图
+func (m *model) world() World {
+	w := World{
+		Tick:     m.tick,
+		TickRate: m.tickRate,
+		Players:  make(map[string]PlayerState, len(m.players)),
+		Self:     m.predicted,
+		HasSelf:  m.hasPred,
+		Flags:    append([]FlagState(nil), m.flags...),
+		Zones:    append([]ZoneState(nil), m.zones...),
+	}
+	if len(m.scores) > 0 {
+		w.Scores = make(map[string]int, len(m.scores))
+		for id, n := range m.scores {
+			w.Scores[id] = n
+		}
+	}
+	for id, p := range m.players {
+		w.Players[id] = p
+	}
+	if len(m.teams) > 0 {
+		w.Teams = make(map[string]TeamState, len(m.teams))
+		for id, t := range m.teams {
+			w.Teams[id] = t
+		}
+	}
+	return w
+}
@@ -0,0 +1,103 @@
+package client
+
+import "testing"
+
+func selfState(x, y float64) PlayerState { return PlayerState{ID: "me", X: x, Y: y} }
+
+func TestModelPredictsBeforeFirstAuthority(t *testing.T) {
+	m := newModel("me", 1, 10, 10)
+	if seq := m.predict(Right); seq != 1 {
+		t.Fatalf("first seq = %d, want 1", seq)
+	}
+	if m.hasPred {
+		t.Fatal("prediction without an authoritative position")
+	}
+
+	// 首个快照尚未确认输入 1：以权威位置为起点重演
+	m.apply(&message{Type: "snapshot", Tick: 5, Players: []PlayerState{selfState(2, 2)}})
+	if !m.hasPred || m.predicted != selfState(3, 2) {
+		t.Fatalf("predicted = %+v (has=%v), want x=3 y=2", m.predicted, m.hasPred)
+	}
+}
+
+func TestModelReconcileReplaysPendingInputs(t *testing.T) {
+	m := newModel("me", 1, 0, 0)
+	m.apply(&message{Type: "snapshot", Players: []PlayerState{selfState(0, 0)}})
+	for _, d := range []Direction{Right, Right, Down} {
+		m.predict(d)
+	}
+	if m.predicted != selfState(2, 1) {
+		t.Fatalf("predicted = %+v, want x=2 y=1", m.predicted)
+	}
+
+	// 服务器只确认了输入 1，且位置与预测不同（例如被其他规则修正）
+	m.apply(&message{Type: "delta", Ack: 1, Players: []PlayerState{selfState(5, 0)}})
+	if len(m.pending) != 2 || m.pending[0].Seq != 2 {
+		t.Fatalf("pending = %+v, want seqs 2,3", m.pending)
+	}
+	if m.predicted != selfState(6, 1) {
+		t.Fatalf("predicted = %+v, want replay from authority x=6 y=1", m.predicted)
+	}
+
+	// 增量未携带自己时沿用上次权威位置
+	m.apply(&message{Type: "delta", Ack: 3})
+	if len(m.pending) != 0 || m.predicted != selfState(5, 0) {
+		t.Fatalf("after full ack: pending=%+v predicted=%+v", m.pending, m.predicted)
+	}
+}
+
+func TestModelAckAdvancesNextSeq(t *testing.T) {
+	m := newModel("me", 1, 0, 0)
+	// 重连后服务器确认序列高于本地，新输入从其后继续
+	m.apply(&message{Type: "snapshot", Ack: 41, Players: []PlayerState{selfState(0, 0)}})
+	if seq := m.predict(Up); seq != 42 {
+		t.Fatalf("seq after reconnect = %d, want 42", seq)
+	}
+	// 乱序的旧确认不回退
+	m.apply(&message{Type: "delta", Ack: 10})
+	if m.lastAck != 41 || len(m.pending) != 1 {
+		t.Fatalf("lastAck=%d pending=%+v", m.lastAck, m.pending)
+	}
+}
+
+func TestModelClampsToWorld(t *testing.T) {
+	m := newModel("me", 3, 10, 10)
+	m.apply(&message{Type: "snapshot", Players: []PlayerState{selfState(9, 1)}})
+	m.predict(Right)
+	m.predict(Up)
+	if m.predicted != selfState(10, 0) {
+		t.Fatalf("predicted = %+v, want clamped to x=10 y=0", m.predicted)
+	}
+}
+
+func TestModelFullStateReplacesWorld(t *testing.T) {
+	m := newModel("me", 1, 0, 0)
+	m.apply(&message{Type: "snapshot", Players: []PlayerState{selfState(0, 0), {ID: "other", X: 1}},
+		Teams: []TeamState{{ID: "red", Score: 1}}})
+	m.apply(&message{Type: "delta", Removed: []string{"other"}})
+	if _, ok := m.players["other"]; ok {
+		t.Fatal("removed player still present")
+	}
+	m.apply(&message{Type: "state", TickRate: 30, Players: []PlayerState{selfState(4, 4)}})
+	w := m.world()
+	if len(w.Players) != 1 || w.Teams != nil || w.TickRate != 30 || w.Self != selfState(4, 4) {
+		t.Fatalf("world after state = %+v", w)
+	}
+	if m.apply(&message{Type: "chat"}) {
+		t.Fatal("non-world message reported as applied")
+	}
+}
+
+func TestModelMergesPartialScores(t *testing.T) {
+	m := newModel("me", 1, 0, 0)
+	m.apply(&message{Type: "snapshot", Zones: []ZoneState{{Name: "hill"}}, Scores: map[string]int{"me": 1, "other": 2}})
+	m.apply(&message{Type: "delta", Scores: map[string]int{"me": 3}})
+	if w := m.world(); w.Scores["me"] != 3 || w.Scores["other"] != 2 {
+		t.Fatalf("scores after partial delta = %v", w.Scores)
+	}
+	// 随 zones 下发的得分是完整的，替换旧值
+	m.apply(&message{Type: "delta", Zones: []ZoneState{{Name: "hill"}}, Scores: map[string]int{}})
+	if w := m.world(); len(w.Scores) != 0 {
+		t.Fatalf("scores after reset = %v", w.Scores)
+	}
+}
@@ -0,0 +1,259 @@
+// Package loadtest 实现 `miniarena loadtest` 子命令：按爬坡速率建立 N 个 WebSocket 客户端，
+// 以指定速率与模式发送输入，统计输入到确认的延迟、各类消息速率、接收字节数与断线数，
+// 每秒输出一行 CSV / JSON，结束时打印汇总。
+package loadtest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"miniarena/client"
+)
+
+// Config 压测参数
+type Config struct {
+	URL      string
+	Rooms    int // 客户端依次分配到 room-1..room-N
+	Room     string
+	Clients  int
+	Ramp     time.Duration
+	Duration time.Duration
+	Rate     float64 // 每客户端每秒输入数
+	Pattern  string  // random | circle | line | burst
+	Prefix   string
+
+	CSV  string // 每秒记录输出路径（"-" 为标准输出）
+	JSON string // 每秒记录输出路径（JSON Lines）
+
+	// CI 阈值：超出时退出码为 1（0 / 负数表示不检查）
+	MaxP99         time.Duration
+	MaxDisconnects int
+}
+
+// Main 解析参数并执行压测，返回进程退出码
+func Main(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	var cfg Config
+	fs.StringVar(&cfg.URL, "url", "ws://localhost:8080", "server base url")
+	fs.StringVar(&cfg.Room, "room", "room-1", "room id (when -rooms is 0)")
+	fs.IntVar(&cfg.Rooms, "rooms", 0, "spread clients over room-1..room-N")
+	fs.IntVar(&cfg.Clients, "clients", 100, "number of clients")
+	fs.DurationVar(&cfg.Ramp, "ramp", 10*time.Second, "time to open all clients")
+	fs.DurationVar(&cfg.Duration, "duration", 30*time.Second, "total test duration (including ramp)")
+	fs.Float64Var(&cfg.Rate, "rate", 5, "inputs per second per client")
+	fs.StringVar(&cfg.Pattern, "pattern", "random", "input pattern: random|circle|line|burst")
+	fs.StringVar(&cfg.Prefix, "prefix", "lt", "player id prefix")
+	fs.StringVar(&cfg.CSV, "csv", "", "per-second CSV output file (- for stdout)")
+	fs.StringVar(&cfg.JSON, "json", "", "per-second JSON Lines output file (- for stdout)")
+	fs.DurationVar(&cfg.MaxP99, "max-p99", 0, "fail if overall input-to-ack p99 exceeds this")
+	fs.IntVar(&cfg.MaxDisconnects, "max-disconnects", -1, "fail if unexpected disconnects exceed this")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if cfg.Clients <= 0 || cfg.Rate <= 0 {
+		fmt.Fprintln(os.Stderr, "loadtest: -clients and -rate must be positive")
+		return 2
+	}
+	if _, ok := patterns[cfg.Pattern]; !ok {
+		fmt.Fprintf(os.Stderr, "loadtest: unknown pattern %q\n", cfg.Pattern)
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	sum, err := Run(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+		return 1
+	}
+	sum.Print(os.Stdout)
+	if cfg.MaxP99 > 0 && sum.P99 > cfg.MaxP99 {
+		fmt.Fprintf(os.Stderr, "loadtest: p99 %s exceeds %s\n", sum.P99, cfg.MaxP99)
+		return 1
+	}
+	if cfg.MaxDisconnects >= 0 && sum.Disconnects > int64(cfg.MaxDisconnects) {
+		fmt.Fprintf(os.Stderr, "loadtest: %d disconnects exceed %d\n", sum.Disconnects, cfg.MaxDisconnects)
+		return 1
+	}
+	return 0
+}
+
+// Run 执行压测直到时长结束或 ctx 取消
+func Run(ctx context.Context, cfg Config) (*Summary, error) {
+	var outputs []recordWriter
+	for _, o := range []struct {
+		path string
+		mk   func(io.Writer) recordWriter
+	}{{cfg.CSV, newCSVWriter}, {cfg.JSON, newJSONWriter}} {
+		if o.path == "" {
+			continue
+		}
+		w, closeFn, err := openOutput(o.path)
+		if err != nil {
+			return nil, err
+		}
+		defer closeFn()
+		outputs = append(outputs, o.mk(w))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	col := newCollector()
+	var wg sync.WaitGroup
+	interval := time.Duration(0)
+	if cfg.Clients > 1 {
+		interval = cfg.Ramp / time.Duration(cfg.Clients-1)
+	}
+
+	// 每秒采样
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		sec := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				sec++
+				rec := col.sample(sec)
+				for _, w := range outputs {
+					w.write(rec)
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+ramp:
+	for i := 0; i < cfg.Clients; i++ {
+		room := cfg.Room
+		if cfg.Rooms > 0 {
+			room = fmt.Sprintf("room-%d", i%cfg.Rooms+1)
+		}
+		id := fmt.Sprintf("%s-%d", cfg.Prefix, i+1)
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			runClient(ctx, cfg, room, id, col, seed)
+		}(int64(i + 1))
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				break ramp
+			case <-time.After(interval):
+			}
+		}
+	}
+	wg.Wait()
+	<-sampled
+	return col.summary(time.Since(start)), nil
+}
+
+// runClient 单个客户端：连接、按节奏发送输入、断开时计数
+func runClient(ctx context.Context, cfg Config, room, id string, col *collector, seed int64) {
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	c, err := client.Dial(dialCtx, client.Options{URL: cfg.URL, Room: room, Player: id})
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			atomic.AddInt64(&col.connectErrors, 1)
+		}
+		return
+	}
+	col.attach(c)
+	atomic.AddInt64(&col.connects, 1)
+	c.OnAckLatency(col.observeLatency)
+	defer func() {
+		col.detach(c)
+	}()
+
+	next := patterns[cfg.Pattern](rand.New(rand.NewSource(seed)))
+	period := time.Duration(float64(time.Second) / cfg.Rate)
+	burst := 1
+	if cfg.Pattern == "burst" {
+		// 每秒集中发送一次
+		period, burst = time.Second, int(cfg.Rate+0.5)
+	}
+	t := time.NewTicker(period)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = c.Close()
+			return
+		case <-c.Done():
+			atomic.AddInt64(&col.disconnects, 1)
+			return
+		case <-t.C:
+			for i := 0; i < burst; i++ {
+				if _, err := c.Move(next()); err != nil {
+					break
+				}
+				atomic.AddInt64(&col.inputs, 1)
+			}
+		}
+	}
+}
+
+// patterns 输入模式：返回方向生成器
+var patterns = map[string]func(*rand.Rand) func() client.Direction{
+	"random": func(rng *rand.Rand) func() client.Direction {
+		dirs := []client.Direction{client.Up, client.Down, client.Left, client.Right}
+		return func() client.Direction { return dirs[rng.Intn(len(dirs))] }
+	},
+	"circle": func(*rand.Rand) func() client.Direction {
+		return cycle(run{client.Right, 5}, run{client.Down, 5}, run{client.Left, 5}, run{client.Up, 5})
+	},
+	"line": func(*rand.Rand) func() client.Direction {
+		return cycle(run{client.Right, 10}, run{client.Left, 10})
+	},
+	"burst": func(*rand.Rand) func() client.Direction {
+		return cycle(run{client.Right, 3}, run{client.Left, 3})
+	},
+}
+
+// run 连续 n 次同一方向
+type run struct {
+	dir client.Direction
+	n   int
+}
+
+// cycle 按 run 序列循环
+func cycle(runs ...run) func() client.Direction {
+	var seq []client.Direction
+	for _, r := range runs {
+		for n := 0; n < r.n; n++ {
+			seq = append(seq, r.dir)
+		}
+	}
+	i := 0
+	return func() client.Direction {
+		d := seq[i%len(seq)]
+		i++
+		return d
+	}
+}
+
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "-" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { _ = f.Close() }, nil
+}
@@ -0,0 +1,86 @@
+package loadtest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"miniarena/server"
+)
+
+// testServer 在 httptest 上启动 /ws 接入，返回地址与本次使用的房间；测试结束时停止该房间
+func testServer(t *testing.T) (url, room string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := server.InitLogger(server.LoggingConfig{File: filepath.Join(dir, "test.log"), Level: "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	room = fmt.Sprintf("loadtest-%d", time.Now().UnixNano())
+	srv := httptest.NewServer(http.HandlerFunc(server.HandleWS))
+	t.Cleanup(func() {
+		if r, ok := server.GetRoomManager().GetRoom(room); ok {
+			r.Stop("test done")
+		}
+	})
+	t.Cleanup(srv.Close)
+	return srv.URL, room
+}
+
+func TestRunSmoke(t *testing.T) {
+	url, room := testServer(t)
+	out := filepath.Join(t.TempDir(), "records.jsonl")
+	cfg := Config{
+		URL:      url,
+		Room:     room,
+		Clients:  3,
+		Ramp:     100 * time.Millisecond,
+		Duration: 1500 * time.Millisecond,
+		Rate:     10,
+		Pattern:  "circle",
+		Prefix:   "bot",
+		JSON:     out,
+	}
+	sum, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum.Connects != 3 || sum.ConnectErrors != 0 || sum.Disconnects != 0 {
+		t.Fatalf("connects=%d errors=%d disconnects=%d", sum.Connects, sum.ConnectErrors, sum.Disconnects)
+	}
+	if sum.Inputs == 0 || sum.Acks == 0 || sum.Acks > sum.Inputs {
+		t.Fatalf("inputs=%d acks=%d", sum.Inputs, sum.Acks)
+	}
+	if sum.P50 <= 0 || sum.P50 > sum.P99 || sum.P99 > sum.Max {
+		t.Fatalf("latency p50=%s p99=%s max=%s", sum.P50, sum.P99, sum.Max)
+	}
+	if sum.Messages == 0 || sum.Bytes == 0 || sum.ByType["snapshot"] != 3 || sum.ByType["state"]+sum.ByType["delta"] == 0 {
+		t.Fatalf("messages=%d bytes=%d by type=%v", sum.Messages, sum.Bytes, sum.ByType)
+	}
+
+	// 每秒记录
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid record %s: %v", sc.Bytes(), err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 1 || recs[0].Second != 1 || recs[0].Clients != 3 || recs[0].Messages == 0 || recs[0].Inputs == 0 {
+		t.Fatalf("records = %+v", recs)
+	}
+}
@@ -0,0 +1,232 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"miniarena/client"
+)
+
+// Record 每秒统计
+type Record struct {
+	Second        int     `json:"second"`
+	Clients       int     `json:"clients"`
+	Connects      int64   `json:"connects"`
+	Disconnects   int64   `json:"disconnects"`
+	ConnectErrors int64   `json:"connectErrors"`
+	Inputs        int64   `json:"inputs"`
+	Acks          int64   `json:"acks"`
+	P50Ms         float64 `json:"p50Ms"`
+	P95Ms         float64 `json:"p95Ms"`
+	P99Ms         float64 `json:"p99Ms"`
+	MaxMs         float64 `json:"maxMs"`
+	Snapshots     int64   `json:"snapshots"`
+	States        int64   `json:"states"`
+	Deltas        int64   `json:"deltas"`
+	Messages      int64   `json:"messages"`
+	Bytes         int64   `json:"bytes"`
+}
+
+// Summary 全程汇总
+type Summary struct {
+	Elapsed       time.Duration
+	Connects      int64
+	Disconnects   int64
+	ConnectErrors int64
+	Inputs        int64
+	Acks          int64
+	P50, P95, P99 time.Duration
+	Max           time.Duration
+	Messages      int64
+	Bytes         int64
+	ByType        map[string]int64
+}
+
+// collector 汇总所有客户端的计数与延迟样本
+type collector struct {
+	connects      int64
+	disconnects   int64
+	connectErrors int64
+	inputs        int64
+
+	mu      sync.Mutex
+	clients map[*client.Client]struct{}
+	retired client.Stats     // 已断开客户端的累计统计
+	window  []time.Duration  // 本秒的延迟样本
+	all     []time.Duration  // 全部延迟样本
+	last    client.Stats     // 上一次采样时的累计统计
+	prev    map[string]int64 // 上一次采样时的计数器
+	byType  map[string]int64 // 全部消息按类型累计
+}
+
+func newCollector() *collector {
+	return &collector{
+		clients: make(map[*client.Client]struct{}),
+		retired: client.Stats{ByType: make(map[string]int64)},
+		last:    client.Stats{ByType: make(map[string]int64)},
+		prev:    make(map[string]int64),
+	}
+}
+
+func (c *collector) attach(cl *client.Client) {
+	c.mu.Lock()
+	c.clients[cl] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (c *collector) detach(cl *client.Client) {
+	s := cl.Stats()
+	c.mu.Lock()
+	delete(c.clients, cl)
+	addStats(&c.retired, s)
+	c.mu.Unlock()
+}
+
+func (c *collector) observeLatency(_ int64, d time.Duration) {
+	c.mu.Lock()
+	c.window = append(c.window, d)
+	c.all = append(c.all, d)
+	c.mu.Unlock()
+}
+
+func addStats(dst *client.Stats, s client.Stats) {
+	dst.Messages += s.Messages
+	dst.Bytes += s.Bytes
+	for k, v := range s.ByType {
+		dst.ByType[k] += v
+	}
+}
+
+// totals 当前全部客户端（含已断开）的累计接收统计
+func (c *collector) totals() client.Stats {
+	t := client.Stats{ByType: make(map[string]int64)}
+	addStats(&t, c.retired)
+	for cl := range c.clients {
+		addStats(&t, cl.Stats())
+	}
+	return t
+}
+
+// sample 生成本秒记录（与上一次采样的差值）
+func (c *collector) sample(sec int) Record {
+	counters := map[string]int64{
+		"connects":      atomic.LoadInt64(&c.connects),
+		"disconnects":   atomic.LoadInt64(&c.disconnects),
+		"connectErrors": atomic.LoadInt64(&c.connectErrors),
+		"inputs":        atomic.LoadInt64(&c.inputs),
+	}
+	c.mu.Lock()
+	cur := c.totals()
+	window := c.window
+	c.window = nil
+	rec := Record{
+		Second:        sec,
+		Clients:       len(c.clients),
+		Connects:      counters["connects"] - c.prev["connects"],
+		Disconnects:   counters["disconnects"] - c.prev["disconnects"],
+		ConnectErrors: counters["connectErrors"] - c.prev["connectErrors"],
+		Inputs:        counters["inputs"] - c.prev["inputs"],
+		Acks:          int64(len(window)),
+		Snapshots:     cur.ByType["snapshot"] - c.last.ByType["snapshot"],
+		States:        cur.ByType["state"] - c.last.ByType["state"],
+		Deltas:        cur.ByType["delta"] - c.last.ByType["delta"],
+		Messages:      cur.Messages - c.last.Messages,
+		Bytes:         cur.Bytes - c.last.Bytes,
+	}
+	c.prev, c.last = counters, cur
+	c.mu.Unlock()
+
+	p := percentiles(window)
+	rec.P50Ms, rec.P95Ms, rec.P99Ms, rec.MaxMs = ms(p[0]), ms(p[1]), ms(p[2]), ms(p[3])
+	return rec
+}
+
+func (c *collector) summary(elapsed time.Duration) *Summary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.totals()
+	p := percentiles(c.all)
+	return &Summary{
+		Elapsed:       elapsed,
+		Connects:      atomic.LoadInt64(&c.connects),
+		Disconnects:   atomic.LoadInt64(&c.disconnects),
+		ConnectErrors: atomic.LoadInt64(&c.connectErrors),
+		Inputs:        atomic.LoadInt64(&c.inputs),
+		Acks:          int64(len(c.all)),
+		P50:           p[0],
+		P95:           p[1],
+		P99:           p[2],
+		Max:           p[3],
+		Messages:      t.Messages,
+		Bytes:         t.Bytes,
+		ByType:        t.ByType,
+	}
+}
+
+// percentiles 返回 p50 / p95 / p99 / max（会对样本原地排序）
+func percentiles(d []time.Duration) [4]time.Duration {
+	var out [4]time.Duration
+	if len(d) == 0 {
+		return out
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	at := func(q float64) time.Duration { return d[int(q*float64(len(d)-1))] }
+	out[0], out[1], out[2], out[3] = at(0.50), at(0.95), at(0.99), d[len(d)-1]
+	return out
+}
+
+func ms(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+// Print 输出可读汇总
+func (s *Summary) Print(w io.Writer) {
+	secs := s.Elapsed.Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	fmt.Fprintf(w, "elapsed        %s\n", s.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "connects       %d (errors %d, unexpected disconnects %d)\n", s.Connects, s.ConnectErrors, s.Disconnects)
+	fmt.Fprintf(w, "inputs         %d sent, %d acked\n", s.Inputs, s.Acks)
+	fmt.Fprintf(w, "input->ack     p50 %s  p95 %s  p99 %s  max %s\n", s.P50, s.P95, s.P99, s.Max)
+	fmt.Fprintf(w, "messages       %d (%.1f/s)  snapshot %d  state %d  delta %d\n",
+		s.Messages, float64(s.Messages)/secs, s.ByType["snapshot"], s.ByType["state"], s.ByType["delta"])
+	fmt.Fprintf(w, "received       %d bytes (%.1f KiB/s)\n", s.Bytes, float64(s.Bytes)/1024/secs)
+}
+
+// recordWriter 每秒记录输出
+type recordWriter interface {
+	write(Record)
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) recordWriter { return &csvWriter{w: csv.NewWriter(w)} }
+
+func (c *csvWriter) write(r Record) {
+	if !c.header {
+		c.header = true
+		_ = c.w.Write([]string{"second", "clients", "connects", "disconnects", "connect_errors", "inputs", "acks",
+			"p50_ms", "p95_ms", "p99_ms", "max_ms", "snapshots", "states", "deltas", "messages", "bytes"})
+	}
+	i := func(v int64) string { return strconv.FormatInt(v, 10) }
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	_ = c.w.Write([]string{strconv.Itoa(r.Second), strconv.Itoa(r.Clients), i(r.Connects), i(r.Disconnects), i(r.ConnectErrors),
+		i(r.Inputs), i(r.Acks), f(r.P50Ms), f(r.P95Ms), f(r.P99Ms), f(r.MaxMs),
+		i(r.Snapshots), i(r.States), i(r.Deltas), i(r.Messages), i(r.Bytes)})
+	c.w.Flush()
+}
+
+type jsonWriter struct{ enc *json.Encoder }
+
+func newJSONWriter(w io.Writer) recordWriter { return &jsonWriter{enc: json.NewEncoder(w)} }
+
+func (j *jsonWriter) write(r Record) { _ = j.enc.Encode(r) }
@@ -1,53 +1,153 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"miniarena/loadtest"
 	"miniarena/server"
 )
 
 // MiniArena 入口：启动 HTTP + WebSocket 服务，并初始化房间管理器
 func main() {
-	var addr string
-	flag.StringVar(&addr, "addr", ":8080", "server listen address, e.g. :8080")
+	// 子命令：miniarena loadtest [flags]
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		os.Exit(loadtest.Main(os.Args[2:]))
+	}
+
+	var addr, configPath string
+	flag.StringVar(&configPath, "config", os.Getenv("MINIARENA_CONFIG"), "config file path (JSON), optional")
+	flag.StringVar(&addr, "addr", "", "server listen address, e.g. :8080 (overrides config)")
 	flag.Parse()
-	// 使用第三方 zap 日志库写入 app.log（带滚动）
-	if err := server.InitLogger("app.log"); err != nil {
+
+	// 命令行 -addr 等价于环境变量覆盖，热加载时同样生效
+	if addr != "" {
+		_ = os.Setenv("MINIARENA_ADDR", addr)
+	}
+	cfg, err := server.LoadConfig(configPath)
+	if err != nil {
+		panic(err)
+	}
+	server.SetConfig(cfg)
+
+	// 使用第三方 zap 日志库写入日志文件（带滚动）
+	if err := server.InitLogger(cfg.Logging); err != nil {
 		panic(err)
 	}
 	defer server.SyncLogger()
 
+	// 对局历史：结算结果写入，供 /matches 查询
+	history, err := server.OpenMatchHistory(cfg.Server.History)
+	if err != nil {
+		server.Log.Fatalf("match history: %v", err)
+	}
+	server.SetMatchHistory(history)
+	// 玩家档案：加入时读取，结算时累加生涯统计
+	profiles, err := server.OpenPlayerStore(cfg.Server.Profiles)
+	if err != nil {
+		server.Log.Fatalf("player store: %v", err)
+	}
+	server.SetPlayerStore(profiles)
+
 	rm := server.GetRoomManager()
-	// 先预创建一个默认房间，便于快速试跑
-	_ = rm.GetOrCreateRoom("room-1")
+	// 预创建配置中的房间，便于快速试跑
+	for _, id := range cfg.Server.StartRooms {
+		_ = rm.GetOrCreateRoom(id)
+	}
+
+	// Tick 看门狗：发现停滞的房间
+	server.StartWatchdog()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", server.HandleWS)
+	// 大厅房间列表与只读房间事件流（SSE）
+	mux.HandleFunc("/rooms", server.HandleRooms)
+	mux.HandleFunc("/rooms/", server.HandleRooms)
+	// 对局历史
+	mux.HandleFunc("/matches", server.HandleMatches)
+	mux.HandleFunc("/matches/", server.HandleMatches)
+	// 玩家档案（鉴权同 /ws）与评分排行榜
+	mux.HandleFunc("/profiles/", server.HandleProfiles)
+	mux.HandleFunc("/leaderboard", server.HandleLeaderboard)
 	// 前后端分离：将 / 映射到 web 目录的静态资源
-	mux.Handle("/", http.FileServer(http.Dir("web")))
+	mux.Handle("/", http.FileServer(http.Dir(cfg.Server.WebDir)))
 	// 管理与监控接口
 	mux.HandleFunc("/admin/config", server.HandleAdminConfig)
+	mux.HandleFunc("/admin/rooms/", server.HandleAdminRooms)
 	mux.HandleFunc("/metrics", server.HandleMetrics)
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("ok"))
-	})
+	mux.HandleFunc("/healthz", server.HandleHealthz)
 
-	srv := &http.Server{Addr: addr, Handler: mux}
+	srv := &http.Server{Addr: cfg.Server.Addr, Handler: mux}
+
+	// 可选：UDP 传输（低延迟原生客户端）
+	var udp *server.UDPServer
+	if cfg.Server.UDPAddr != "" {
+		udp, err = server.ListenUDP(cfg.Server.UDPAddr)
+		if err != nil {
+			server.Log.Fatalf("udp listen: %v", err)
+		}
+		go func() {
+			server.Log.Infof("MiniArena UDP listening on %s", udp.Addr())
+			if err := udp.Serve(); err != nil {
+				server.Log.Errorf("udp serve: %v", err)
+			}
+		}()
+	}
 
 	go func() {
-		server.Log.Infof("MiniArena listening on %s; open http://localhost%v/", addr, addr)
+		server.Log.Infof("MiniArena listening on %s; open http://localhost%v/", cfg.Server.Addr, cfg.Server.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			server.Log.Fatalf("listen: %v", err)
 		}
 	}()
 
-	// 优雅退出（Ctrl+C）
+	// SIGHUP 热加载配置；Ctrl+C 优雅退出
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	server.Log.Info("Shutting down...")
+	for {
+		select {
+		case <-hup:
+			if err := server.ReloadConfig(); err != nil {
+				server.Log.Errorf("config reload failed: %v", err)
+			}
+		case <-quit:
+			server.Log.Info("Shutting down...")
+			shutdown(srv, udp, history, profiles)
+			return
+		}
+	}
+}
+
+// shutdownTimeout 优雅退出的总时限
+const shutdownTimeout = 10 * time.Second
+
+// shutdown 先停止接入与房间 Tick，再关闭存储：结算只在 Tick 中写入存储，房间停止后不会再写
+func shutdown(srv *http.Server, udp *server.UDPServer, stores ...io.Closer) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	// 先关闭监听；SSE 等长连接在房间停止后才会结束，因此与停止房间并行等待
+	httpDone := make(chan error, 1)
+	go func() { httpDone <- srv.Shutdown(ctx) }()
+	if udp != nil {
+		_ = udp.Close()
+	}
+	if err := server.GetRoomManager().Shutdown(ctx); err != nil {
+		server.Log.Warnf("rooms shutdown: %v", err)
+	}
+	if err := <-httpDone; err != nil {
+		server.Log.Warnf("http shutdown: %v", err)
+	}
+	for _, s := range stores {
+		if err := s.Close(); err != nil {
+			server.Log.Errorf("store close: %v", err)
+		}
+	}
 }
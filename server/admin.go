@@ -3,10 +3,13 @@ package server
 import (
     "encoding/json"
     "net/http"
+    "sort"
+    "strings"
+    "time"
 )
 
 // HandleAdminConfig 提供房间配置的读取与更新（热更新基本规则）
-// GET /admin/config?room=room-1  返回当前配置
+// GET /admin/config?room=room-1  返回当前配置（含 ticksPerSecond）
 // POST /admin/config?room=room-1 以 JSON 载荷更新部分字段
 func HandleAdminConfig(w http.ResponseWriter, r *http.Request) {
     roomID := r.URL.Query().Get("room")
@@ -15,21 +18,35 @@ func HandleAdminConfig(w http.ResponseWriter, r *http.Request) {
     room := rm.GetOrCreateRoom(roomID)
 
     type cfg struct {
-        Step                *float64 `json:"step,omitempty"`
-        MaxInputsPerTick    *int     `json:"maxInputsPerTick,omitempty"`
+        TicksPerSecond      *int     `json:"ticksPerSecond,omitempty"`
+        Speed               *float64 `json:"speed,omitempty"`
+        MaxInputsPerSecond  *int     `json:"maxInputsPerSecond,omitempty"`
         SimulateDelayMinMs  *int     `json:"simulateDelayMinMs,omitempty"`
         SimulateDelayMaxMs  *int     `json:"simulateDelayMaxMs,omitempty"`
         SimulateDropProb    *float64 `json:"simulateDropProb,omitempty"`
     }
 
+    // simulate* 为旧字段，映射到房间默认入站网络条件：min=latency，max=latency+jitter，drop=loss
+    // 房间参数只在 Tick 线程中读写
     switch r.Method {
     case http.MethodGet:
-        cur := cfg{
-            Step:               &room.step,
-            MaxInputsPerTick:   &room.maxInputsPerTick,
-            SimulateDelayMinMs: &room.simulateDelayMinMs,
-            SimulateDelayMaxMs: &room.simulateDelayMaxMs,
-            SimulateDropProb:   &room.simulateDropProb,
+        var cur cfg
+        ok := room.Query(func() {
+            in := room.netDefault.Inbound
+            tps, speed, maxInputs := room.TickRate(), room.speed, room.maxInputsPerSecond
+            maxMs := in.LatencyMs + in.JitterMs
+            cur = cfg{
+                TicksPerSecond:     &tps,
+                Speed:              &speed,
+                MaxInputsPerSecond: &maxInputs,
+                SimulateDelayMinMs: &in.LatencyMs,
+                SimulateDelayMaxMs: &maxMs,
+                SimulateDropProb:   &in.Loss,
+            }
+        })
+        if !ok {
+            http.Error(w, "room closed", http.StatusServiceUnavailable)
+            return
         }
         w.Header().Set("Content-Type", "application/json")
         _ = json.NewEncoder(w).Encode(cur)
@@ -40,15 +57,55 @@ func HandleAdminConfig(w http.ResponseWriter, r *http.Request) {
             http.Error(w, "invalid json", http.StatusBadRequest)
             return
         }
-        if body.Step != nil { room.step = *body.Step }
-        if body.MaxInputsPerTick != nil { room.maxInputsPerTick = *body.MaxInputsPerTick }
-        if body.SimulateDelayMinMs != nil { room.simulateDelayMinMs = *body.SimulateDelayMinMs }
-        if body.SimulateDelayMaxMs != nil { room.simulateDelayMaxMs = *body.SimulateDelayMaxMs }
-        if body.SimulateDropProb != nil { room.simulateDropProb = *body.SimulateDropProb }
+        // 先校验与当前值无关的字段，再在一次 Tick 线程调用中读取、合并、校验并整体生效
+        if (body.Speed != nil && *body.Speed <= 0) || (body.MaxInputsPerSecond != nil && *body.MaxInputsPerSecond <= 0) {
+            http.Error(w, "speed and maxInputsPerSecond must be positive", http.StatusBadRequest)
+            return
+        }
+        if body.TicksPerSecond != nil {
+            if err := validateTickRate(*body.TicksPerSecond); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+        var err error
+        var tps, maxInputs int
+        var speed float64
+        var net NetConditions
+        ok := room.Query(func() {
+            tps, speed, maxInputs, net = room.TickRate(), room.speed, room.maxInputsPerSecond, room.netDefault
+            if body.TicksPerSecond != nil { tps = *body.TicksPerSecond }
+            if body.Speed != nil { speed = *body.Speed }
+            if body.MaxInputsPerSecond != nil { maxInputs = *body.MaxInputsPerSecond }
+            if body.SimulateDelayMinMs != nil || body.SimulateDelayMaxMs != nil || body.SimulateDropProb != nil {
+                in := net.Inbound
+                minMs, maxMs := in.LatencyMs, in.LatencyMs+in.JitterMs
+                if body.SimulateDelayMinMs != nil { minMs = *body.SimulateDelayMinMs }
+                if body.SimulateDelayMaxMs != nil { maxMs = *body.SimulateDelayMaxMs }
+                if maxMs < minMs { maxMs = minMs }
+                if body.SimulateDropProb != nil { in.Loss = *body.SimulateDropProb }
+                in.LatencyMs, in.JitterMs = minMs, maxMs-minMs
+                net.Inbound = in
+                if err = net.Validate(); err != nil {
+                    return
+                }
+            }
+            room.speed, room.maxInputsPerSecond, room.netDefault = speed, maxInputs, net
+            // 同时重新换算步长与每 Tick 额度
+            room.setTickRate(tps)
+        })
+        if !ok {
+            http.Error(w, "room closed", http.StatusServiceUnavailable)
+            return
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
         w.Header().Set("Content-Type", "application/json")
         _ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
-        Log.Infof("config updated: room=%s step=%.2f maxInputsPerTick=%d delay=[%d,%d] drop=%.2f",
-            roomID, room.step, room.maxInputsPerTick, room.simulateDelayMinMs, room.simulateDelayMaxMs, room.simulateDropProb)
+        Log.Infof("config updated: room=%s tps=%d speed=%.2f maxInputsPerSecond=%d net=%+v",
+            roomID, tps, speed, maxInputs, net)
         return
     default:
         http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -56,19 +113,249 @@ func HandleAdminConfig(w http.ResponseWriter, r *http.Request) {
     }
 }
 
-// HandleMetrics 输出指定房间的运行指标
-// GET /metrics?room=room-1
-func HandleMetrics(w http.ResponseWriter, r *http.Request) {
-    roomID := r.URL.Query().Get("room")
-    if roomID == "" { roomID = "room-1" }
-    rm := GetRoomManager()
-    room := rm.GetOrCreateRoom(roomID)
-    payload := map[string]any{
-        "room":    roomID,
-        "tick":    room.tickSeq,
-        "metrics": room.metrics.Snapshot(),
+// HandleAdminRooms 房间级管理接口（按路径分发）
+// GET /admin/rooms/{id}/metrics                       返回房间运行指标（JSON）
+// GET|POST /admin/rooms/{id}/net                      读取/修改房间默认网络条件（可带 seed）
+// GET|POST|DELETE /admin/rooms/{id}/players/{pid}/net 读取/覆盖/恢复单个玩家的网络条件
+// GET /admin/rooms/{id}/teams                         队伍、分数与成员
+// POST /admin/rooms/{id}/players/{pid}/team           将玩家移到指定队伍，载荷 {"team":"blue"}
+// GET /admin/rooms/{id}/chat                          最近聊天消息与禁言列表
+// POST|DELETE /admin/rooms/{id}/players/{pid}/mute    禁言（载荷 {"durationMs":60000}，省略为永久）/ 解除禁言
+func HandleAdminRooms(w http.ResponseWriter, r *http.Request) {
+    parts := splitPath(strings.TrimPrefix(r.URL.Path, "/admin/rooms/"))
+    if len(parts) < 2 {
+        http.NotFound(w, r)
+        return
+    }
+    room, ok := GetRoomManager().GetRoom(parts[0])
+    if !ok {
+        http.Error(w, "room not found", http.StatusNotFound)
+        return
+    }
+    switch parts[1] {
+    case "metrics":
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        var tick int64
+        room.Query(func() { tick = room.tickSeq })
+        payload := map[string]any{
+            "room":    room.ID,
+            "mode":    room.mode.Name(),
+            "tick":    tick,
+            "metrics": room.metrics.Snapshot(),
+        }
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(payload)
+    case "net":
+        handleRoomNet(w, r, room)
+    case "teams":
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        handleTeams(w, room)
+    case "chat":
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        handleChat(w, room)
+    case "players":
+        if len(parts) != 4 {
+            http.NotFound(w, r)
+            return
+        }
+        switch parts[3] {
+        case "net":
+            handlePlayerNet(w, r, room, PlayerID(parts[2]))
+        case "team":
+            handlePlayerTeam(w, r, room, PlayerID(parts[2]))
+        case "mute":
+            handlePlayerMute(w, r, room, PlayerID(parts[2]))
+        default:
+            http.NotFound(w, r)
+        }
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+// handleRoomNet 房间默认网络条件；POST 载荷 {"inbound":{...},"outbound":{...},"seed":1}
+func handleRoomNet(w http.ResponseWriter, r *http.Request, room *Room) {
+    switch r.Method {
+    case http.MethodGet:
+        var def NetConditions
+        overrides := map[PlayerID]NetConditions{}
+        room.Query(func() {
+            def = room.netDefault
+            for pid, c := range room.netOverrides { overrides[pid] = c }
+        })
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"room": room.ID, "default": def, "players": overrides})
+    case http.MethodPost:
+        var body struct {
+            NetConditions
+            Seed *int64 `json:"seed,omitempty"`
+        }
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+            http.Error(w, "invalid json", http.StatusBadRequest)
+            return
+        }
+        if err := room.SetNetDefault(body.NetConditions, body.Seed); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// handlePlayerNet 单个玩家的网络条件（玩家无需在线，覆盖在重连后仍生效）
+func handlePlayerNet(w http.ResponseWriter, r *http.Request, room *Room, pid PlayerID) {
+    switch r.Method {
+    case http.MethodGet:
+        var cur NetConditions
+        var overridden bool
+        room.Query(func() {
+            cur = room.netFor(pid)
+            _, overridden = room.netOverrides[pid]
+        })
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"player": pid, "net": cur, "override": overridden})
+    case http.MethodPost, http.MethodPut:
+        var body NetConditions
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+            http.Error(w, "invalid json", http.StatusBadRequest)
+            return
+        }
+        if err := room.SetPlayerNet(pid, &body); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+    case http.MethodDelete:
+        _ = room.SetPlayerNet(pid, nil)
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// handleTeams 列出队伍、分数与在线成员
+func handleTeams(w http.ResponseWriter, room *Room) {
+    type teamView struct {
+        ID      string     `json:"id"`
+        Color   string     `json:"color"`
+        Score   int        `json:"score"`
+        Members []PlayerID `json:"members"`
+    }
+    var out []teamView
+    room.Query(func() {
+        for _, t := range room.teams {
+            v := teamView{ID: t.ID, Color: t.Color, Score: t.Score, Members: []PlayerID{}}
+            for id, p := range room.Players {
+                if p.Team == t.ID { v.Members = append(v.Members, id) }
+            }
+            sort.Slice(v.Members, func(i, j int) bool { return v.Members[i] < v.Members[j] })
+            out = append(out, v)
+        }
+    })
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(map[string]any{"room": room.ID, "teams": out})
+}
+
+// handlePlayerTeam 管理员调整玩家队伍（玩家离线时在重连后生效）
+func handlePlayerTeam(w http.ResponseWriter, r *http.Request, room *Room, pid PlayerID) {
+    if r.Method != http.MethodPost && r.Method != http.MethodPut {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    var body struct {
+        Team string `json:"team"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+        http.Error(w, "invalid json", http.StatusBadRequest)
+        return
+    }
+    var err error
+    if !room.Query(func() { err = room.SetTeam(pid, body.Team) }) {
+        http.Error(w, "room closed", http.StatusGone)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+}
+
+// handleChat 最近聊天消息（含队伍频道）与当前禁言
+func handleChat(w http.ResponseWriter, room *Room) {
+    var recent []ChatMessage
+    mutes := map[PlayerID]*time.Time{}
+    room.Query(func() {
+        recent = room.chat.history()
+        for id, until := range room.chat.mutes {
+            if until.IsZero() {
+                mutes[id] = nil // 永久
+            } else if until.After(time.Now()) {
+                u := until
+                mutes[id] = &u
+            }
+        }
+    })
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(map[string]any{"room": room.ID, "messages": recent, "muted": mutes})
+}
+
+// handlePlayerMute 禁言/解除禁言（玩家无需在线，重连后仍生效）
+func handlePlayerMute(w http.ResponseWriter, r *http.Request, room *Room, pid PlayerID) {
+    switch r.Method {
+    case http.MethodPost, http.MethodPut:
+        var body struct {
+            DurationMs int64 `json:"durationMs"`
+        }
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+                http.Error(w, "invalid json", http.StatusBadRequest)
+                return
+            }
+        }
+        if body.DurationMs < 0 {
+            http.Error(w, "durationMs must be non-negative", http.StatusBadRequest)
+            return
+        }
+        if !room.Query(func() { room.Mute(pid, time.Duration(body.DurationMs)*time.Millisecond) }) {
+            http.Error(w, "room closed", http.StatusGone)
+            return
+        }
+    case http.MethodDelete:
+        if !room.Query(func() { room.Unmute(pid) }) {
+            http.Error(w, "room closed", http.StatusGone)
+            return
+        }
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
     }
     w.Header().Set("Content-Type", "application/json")
-    _ = json.NewEncoder(w).Encode(payload)
+    _ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
 }
 
+// splitPath 将 "a/b/c" 拆分为非空片段
+func splitPath(p string) []string {
+    var out []string
+    for _, s := range strings.Split(p, "/") {
+        if s != "" {
+            out = append(out, s)
+        }
+    }
+    return out
+}
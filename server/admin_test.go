@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// managedRoom 在房间管理器中创建运行中的房间（供 HTTP 接口查找），测试结束时停止并移除
+func managedRoom(t *testing.T) *Room {
+	t.Helper()
+	rm := GetRoomManager()
+	r := rm.GetOrCreateRoom(strings.ReplaceAll(t.Name(), "/", "-"))
+	t.Cleanup(func() {
+		r.Stop("test done")
+		rm.removeRoom(r, "")
+	})
+	return r
+}
+
+// serve 以 httptest 调用处理器，返回状态码与响应体
+func serve(h http.HandlerFunc, method, target, body string) (int, string) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestAdminConfigAppliesAllFields(t *testing.T) {
+	r := managedRoom(t)
+	url := "/admin/config?room=" + r.ID
+	code, body := serve(HandleAdminConfig, http.MethodPost, url,
+		`{"ticksPerSecond":25,"speed":20,"maxInputsPerSecond":10,"simulateDelayMinMs":10,"simulateDelayMaxMs":30,"simulateDropProb":0.1}`)
+	if code != http.StatusOK {
+		t.Fatalf("POST = %d %s", code, body)
+	}
+
+	code, body = serve(HandleAdminConfig, http.MethodGet, url, "")
+	if code != http.StatusOK {
+		t.Fatalf("GET = %d %s", code, body)
+	}
+	var got struct {
+		TicksPerSecond     int     `json:"ticksPerSecond"`
+		Speed              float64 `json:"speed"`
+		MaxInputsPerSecond int     `json:"maxInputsPerSecond"`
+		SimulateDelayMinMs int     `json:"simulateDelayMinMs"`
+		SimulateDelayMaxMs int     `json:"simulateDelayMaxMs"`
+		SimulateDropProb   float64 `json:"simulateDropProb"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TicksPerSecond != 25 || got.Speed != 20 || got.MaxInputsPerSecond != 10 ||
+		got.SimulateDelayMinMs != 10 || got.SimulateDelayMaxMs != 30 || got.SimulateDropProb != 0.1 {
+		t.Fatalf("config after POST = %+v", got)
+	}
+	var step, perTick float64
+	r.Query(func() { step, perTick = r.step, r.inputsPerTick })
+	if step != 2 || perTick != 0.4 {
+		t.Fatalf("step=%v inputsPerTick=%v, want 2 and 0.4", step, perTick)
+	}
+}
+
+func TestAdminConfigRejectsWithoutPartialApply(t *testing.T) {
+	r := managedRoom(t)
+	tps := r.TickRate()
+	var speed float64
+	r.Query(func() { speed = r.speed })
+
+	// 合法的频率与速度不能在丢包率非法时先行生效
+	target := 25
+	if tps == target {
+		target = 20
+	}
+	code, _ := serve(HandleAdminConfig, http.MethodPost, "/admin/config?room="+r.ID,
+		`{"ticksPerSecond":`+strconv.Itoa(target)+`,"speed":99,"simulateDropProb":1.5}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("POST with invalid drop prob = %d, want 400", code)
+	}
+	var after float64
+	r.Query(func() { after = r.speed })
+	if r.TickRate() != tps || after != speed {
+		t.Fatalf("partially applied: tps %d -> %d, speed %v -> %v", tps, r.TickRate(), speed, after)
+	}
+}
+
+func TestAdminConfigClosedRoom(t *testing.T) {
+	r := managedRoom(t)
+	r.Close()
+	for _, m := range []string{http.MethodGet, http.MethodPost} {
+		if code, _ := serve(HandleAdminConfig, m, "/admin/config?room="+r.ID, `{"speed":5}`); code != http.StatusServiceUnavailable {
+			t.Fatalf("%s on closed room = %d, want 503", m, code)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 聊天频道
+const (
+	ChatRoom = "room" // 房间内全部玩家（并写入事件流）
+	ChatTeam = "team" // 仅发送者所在队伍
+)
+
+// ChatConfig 聊天参数（支持热加载）
+type ChatConfig struct {
+	Enabled      bool     `json:"enabled"`
+	MaxLength    int      `json:"maxLength"`    // 单条消息最大字符数
+	PerSecond    float64  `json:"perSecond"`    // 每名玩家的持续发送速率
+	Burst        int      `json:"burst"`        // 允许的突发条数
+	HistorySize  int      `json:"historySize"`  // 管理接口可读的最近消息数
+	BlockedWords []string `json:"blockedWords"` // 屏蔽词（不区分大小写，替换为 *）
+}
+
+// Validate 检查聊天参数
+func (c ChatConfig) Validate() error {
+	if c.MaxLength <= 0 || c.PerSecond <= 0 || c.Burst <= 0 {
+		return fmt.Errorf("maxLength, perSecond and burst must be positive")
+	}
+	if c.HistorySize < 0 {
+		return fmt.Errorf("historySize must be non-negative")
+	}
+	return nil
+}
+
+// ChatMessage 一条聊天消息；ID 与时间戳由服务端分配
+type ChatMessage struct {
+	Type    string    `json:"type"`
+	ID      int64     `json:"id"` // 房间内递增
+	Channel string    `json:"channel"`
+	Team    string    `json:"team,omitempty"`
+	From    PlayerID  `json:"from"`
+	Name    string    `json:"name,omitempty"`
+	Text    string    `json:"text"`
+	Tick    int64     `json:"tick"`
+	At      time.Time `json:"at"`
+}
+
+// 拒绝原因，作为错误响应的 code 回复发送者
+const (
+	ChatRejectDisabled = "disabled"
+	ChatRejectEmpty    = "empty"
+	ChatRejectTooLong  = "too_long"
+	ChatRejectRate     = "rate_limited"
+	ChatRejectMuted    = "muted"
+	ChatRejectChannel  = "bad_channel"
+	ChatRejectFiltered = "filtered"
+)
+
+// ChatRejected 过滤器拒绝消息时返回的错误；Reason 作为错误码回复给发送者
+type ChatRejected struct {
+	Reason string
+	Detail string
+}
+
+func (e *ChatRejected) Error() string {
+	if e.Detail == "" {
+		return e.Reason
+	}
+	return e.Reason + ": " + e.Detail
+}
+
+// ChatFilter 聊天过滤器：可改写消息内容，返回错误时拒绝该消息（Tick 线程调用）
+type ChatFilter interface {
+	Filter(r *Room, msg *ChatMessage) error
+}
+
+// ChatFilterFunc 函数形式的过滤器
+type ChatFilterFunc func(r *Room, msg *ChatMessage) error
+
+func (f ChatFilterFunc) Filter(r *Room, msg *ChatMessage) error { return f(r, msg) }
+
+var (
+	chatFiltersMu sync.RWMutex
+	// 内置过滤器：禁言列表 → 屏蔽词；RegisterChatFilter 追加在其后
+	chatFilters = []ChatFilter{ChatFilterFunc(muteFilter), ChatFilterFunc(wordFilter)}
+)
+
+// RegisterChatFilter 追加全局聊天过滤器，通常在 init 中调用
+func RegisterChatFilter(f ChatFilter) {
+	chatFiltersMu.Lock()
+	chatFilters = append(chatFilters, f)
+	chatFiltersMu.Unlock()
+}
+
+// muteFilter 拒绝被禁言玩家的消息
+func muteFilter(r *Room, msg *ChatMessage) error {
+	until, ok := r.chat.mutes[msg.From]
+	if !ok {
+		return nil
+	}
+	if !until.IsZero() && msg.At.After(until) {
+		delete(r.chat.mutes, msg.From)
+		return nil
+	}
+	return &ChatRejected{Reason: ChatRejectMuted}
+}
+
+// wordFilter 将屏蔽词替换为等长的 *
+func wordFilter(r *Room, msg *ChatMessage) error {
+	for _, w := range r.chat.cfg.BlockedWords {
+		if w != "" {
+			msg.Text = maskWord(msg.Text, w)
+		}
+	}
+	return nil
+}
+
+// maskWord 不区分大小写地替换 text 中的 word
+func maskWord(text, word string) string {
+	lower := strings.ToLower(text)
+	lw := strings.ToLower(word)
+	if len(lower) != len(text) || !strings.Contains(lower, lw) {
+		// 大小写转换改变了字节长度时无法按下标对应，退化为区分大小写
+		return strings.ReplaceAll(text, word, strings.Repeat("*", utf8.RuneCountInString(word)))
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, lw)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		b.WriteString(text[:i])
+		b.WriteString(strings.Repeat("*", utf8.RuneCountInString(text[i:i+len(lw)])))
+		text, lower = text[i+len(lw):], lower[i+len(lw):]
+	}
+}
+
+// chatState 房间聊天状态（Tick 线程访问）
+type chatState struct {
+	cfg     ChatConfig
+	seq     int64
+	buckets map[PlayerID]*tokenBucket
+	mutes   map[PlayerID]time.Time // 禁言到期时间，零值表示永久
+	recent  []ChatMessage          // 最近消息（环形）
+	start   int
+}
+
+func (c *chatState) allow(id PlayerID, now time.Time) bool {
+	b, ok := c.buckets[id]
+	if !ok {
+		b = newTokenBucket(c.cfg.Burst, now)
+		c.buckets[id] = b
+	}
+	return b.take(now, c.cfg.PerSecond, c.cfg.Burst)
+}
+
+func (c *chatState) remember(msg ChatMessage) {
+	n := c.cfg.HistorySize
+	if n == 0 {
+		c.recent, c.start = nil, 0
+		return
+	}
+	if len(c.recent) < n {
+		if c.start != 0 {
+			// 热加载调大了缓冲：先恢复时间顺序
+			c.recent, c.start = c.history(), 0
+		}
+		c.recent = append(c.recent, msg)
+		return
+	}
+	if len(c.recent) > n {
+		// 热加载调小了缓冲
+		c.recent = append([]ChatMessage(nil), c.history()[len(c.recent)-n:]...)
+		c.start = 0
+	}
+	c.recent[c.start] = msg
+	c.start = (c.start + 1) % n
+}
+
+// history 按时间先后的最近消息
+func (c *chatState) history() []ChatMessage {
+	out := make([]ChatMessage, 0, len(c.recent))
+	out = append(out, c.recent[c.start:]...)
+	return append(out, c.recent[:c.start]...)
+}
+
+func (r *Room) initChat() {
+	r.chat.buckets = make(map[PlayerID]*tokenBucket)
+	r.chat.mutes = make(map[PlayerID]time.Time)
+}
+
+// chatRequest 客户端 chat 消息
+type chatRequest struct {
+	Channel string `json:"channel"` // room（默认）或 team
+	Text    string `json:"text"`
+}
+
+// 路由层的聊天速率上限：在投递到 Tick 线程之前拦截刷屏，房间聊天配置的限流在其内生效
+const (
+	chatRoutePerSecond = 5
+	chatRouteBurst     = 10
+)
+
+func init() {
+	// 限流与拒绝原因由房间聊天配置处理，以统一的错误响应回复（code 为拒绝原因）
+	RegisterMessage("chat", JSONHandler(OnTick, func(ctx *MessageContext, m *chatRequest) error {
+		return ctx.Room.Chat(ctx.Player, m.Channel, m.Text)
+	}).Limit(chatRoutePerSecond, chatRouteBurst))
+}
+
+// Chat 处理玩家发送的聊天消息（客户端 chat 消息）：校验、限流、过滤后可靠投递到频道；
+// 被拒绝时返回 *MessageError，Code 为拒绝原因
+func (r *Room) Chat(id PlayerID, channel, text string) error {
+	p, ok := r.Players[id]
+	if !ok {
+		return nil
+	}
+	err := r.chatMessage(p, channel, text)
+	if err == nil {
+		return nil
+	}
+	reason, detail := ChatRejectFiltered, err.Error()
+	var rej *ChatRejected
+	if errors.As(err, &rej) {
+		reason, detail = rej.Reason, rej.Detail
+	}
+	Log.Infof("chat rejected: room=%s player=%s reason=%s", r.ID, id, reason)
+	return &MessageError{Code: reason, Msg: detail}
+}
+
+func (r *Room) chatMessage(p *Player, channel, text string) error {
+	c := &r.chat
+	if !c.cfg.Enabled {
+		return &ChatRejected{Reason: ChatRejectDisabled}
+	}
+	// 先扣额度再校验：被拒绝的消息同样消耗额度，无法借无效消息绕过限流
+	now := time.Now()
+	if !c.allow(p.ID, now) {
+		return &ChatRejected{Reason: ChatRejectRate}
+	}
+	// 字节数超过字符上限可能的最大编码长度时直接拒绝，不再逐字符处理
+	if len(text) > c.cfg.MaxLength*utf8.UTFMax {
+		return &ChatRejected{Reason: ChatRejectTooLong, Detail: fmt.Sprintf("%d bytes", len(text))}
+	}
+	text = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text))
+	if text == "" {
+		return &ChatRejected{Reason: ChatRejectEmpty}
+	}
+	if n := utf8.RuneCountInString(text); n > c.cfg.MaxLength {
+		return &ChatRejected{Reason: ChatRejectTooLong, Detail: fmt.Sprintf("%d > %d", n, c.cfg.MaxLength)}
+	}
+	msg := ChatMessage{Type: "chat", Channel: channel, From: p.ID, Name: p.Name, Text: text, Tick: r.tickSeq, At: now}
+	switch channel {
+	case "", ChatRoom:
+		msg.Channel = ChatRoom
+	case ChatTeam:
+		if p.Team == "" {
+			return &ChatRejected{Reason: ChatRejectChannel, Detail: "not in a team"}
+		}
+		msg.Team = p.Team
+	default:
+		return &ChatRejected{Reason: ChatRejectChannel, Detail: channel}
+	}
+	chatFiltersMu.RLock()
+	filters := chatFilters
+	chatFiltersMu.RUnlock()
+	for _, f := range filters {
+		if err := f.Filter(r, &msg); err != nil {
+			return err
+		}
+	}
+	c.seq++
+	msg.ID = c.seq
+	c.remember(msg)
+	if msg.Channel == ChatTeam {
+		r.BroadcastTeam(msg.Team, msg)
+	} else {
+		r.broadcastControl("chat", msg)
+	}
+	return nil
+}
+
+// Mute 禁言玩家（玩家无需在线）；d <= 0 表示永久
+func (r *Room) Mute(id PlayerID, d time.Duration) {
+	var until time.Time
+	if d > 0 {
+		until = time.Now().Add(d)
+	}
+	r.chat.mutes[id] = until
+	Log.Infof("player muted: room=%s player=%s duration=%s", r.ID, id, d)
+}
+
+// Unmute 解除禁言
+func (r *Room) Unmute(id PlayerID) {
+	if _, ok := r.chat.mutes[id]; ok {
+		delete(r.chat.mutes, id)
+		Log.Infof("player unmuted: room=%s player=%s", r.ID, id)
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendChat 经消息路由发送一条房间聊天
+func sendChat(t *testing.T, in *inbound, text string) {
+	t.Helper()
+	b, err := json.Marshal(map[string]string{"type": "chat", "text": text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.dispatch(b)
+}
+
+// chatReasons 会话收到的聊天拒绝原因（ref 为 chat 的错误码）与成功投递的聊天条数
+func chatReasons(t *testing.T, s *MemSession) (reasons []string, delivered int) {
+	t.Helper()
+	for {
+		select {
+		case b := <-s.Recv():
+			var m errorMessage
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("invalid message %s: %v", b, err)
+			}
+			switch m.Type {
+			case "error":
+				if m.Ref != "chat" {
+					t.Fatalf("error for %q: %s", m.Ref, b)
+				}
+				reasons = append(reasons, m.Code)
+			case "chat":
+				delivered++
+			}
+		default:
+			return reasons, delivered
+		}
+	}
+}
+
+func TestChatRejectedMessagesConsumeRate(t *testing.T) {
+	r := testRoom(t, func(p *RoomProfile) {
+		p.Chat = ChatConfig{Enabled: true, MaxLength: 10, PerSecond: 0.001, Burst: 2}
+	})
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	chatReasons(t, s)
+
+	in := newInbound(r, "alice")
+	sendChat(t, in, "   ")
+	sendChat(t, in, strings.Repeat("x", 11))
+	sendChat(t, in, "hello")
+	r.runTick(time.Now())
+	reasons, delivered := chatReasons(t, s)
+	want := []string{ChatRejectEmpty, ChatRejectTooLong, ChatRejectRate}
+	if delivered != 0 || strings.Join(reasons, ",") != strings.Join(want, ",") {
+		t.Fatalf("reasons=%v delivered=%d, want %v and nothing delivered", reasons, delivered, want)
+	}
+}
+
+func TestChatOversizedPayloadRejectedByBytes(t *testing.T) {
+	r := testRoom(t, func(p *RoomProfile) {
+		p.Chat = ChatConfig{Enabled: true, MaxLength: 4, PerSecond: 10, Burst: 10}
+	})
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	chatReasons(t, s)
+
+	in := newInbound(r, "alice")
+	// 4 个字符的多字节文本仍然允许
+	sendChat(t, in, "世界你好")
+	// 控制字符会被去掉，但原始字节数已超过上限
+	sendChat(t, in, "ok"+strings.Repeat("\x00", 4*4))
+	r.runTick(time.Now())
+	reasons, delivered := chatReasons(t, s)
+	if delivered != 1 || len(reasons) != 1 || reasons[0] != ChatRejectTooLong {
+		t.Fatalf("reasons=%v delivered=%d, want one delivery and too_long", reasons, delivered)
+	}
+}
+
+func TestChatRouteLimit(t *testing.T) {
+	r := testRoom(t, func(p *RoomProfile) {
+		p.Chat = ChatConfig{Enabled: true, MaxLength: 10, PerSecond: 100, Burst: 100}
+	})
+	s := ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	chatReasons(t, s)
+
+	// 房间配置允许的速率高于路由上限时，超出路由突发的消息在进入命令队列前被拒绝
+	in := newInbound(r, "alice")
+	for i := 0; i < chatRouteBurst+5; i++ {
+		in.dispatch([]byte(`{"type":"chat","text":"spam"}`))
+		r.runTick(time.Now())
+	}
+	_, delivered := chatReasons(t, s)
+	if delivered != chatRouteBurst {
+		t.Fatalf("delivered %d chats, want %d", delivered, chatRouteBurst)
+	}
+}
@@ -0,0 +1,441 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Config 服务端声明式配置（JSON 文件 + 环境变量覆盖）
+type Config struct {
+	Server  ServerConfig  `json:"server"`
+	Logging LoggingConfig `json:"logging"`
+	// Room 默认房间参数
+	Room RoomProfile `json:"room"`
+	// Rooms 按房间 ID 覆盖默认参数，只需写出差异字段
+	Rooms map[string]json.RawMessage `json:"rooms,omitempty"`
+
+	path string // 配置文件路径（热加载时重新读取）
+}
+
+// ServerConfig 进程级参数（修改后需重启）
+type ServerConfig struct {
+	Addr          string   `json:"addr"`          // 监听地址
+	WebDir        string   `json:"webDir"`        // 静态资源目录
+	SendQueueSize int      `json:"sendQueueSize"` // 每连接发送队列容量
+	StartRooms    []string `json:"startRooms"`    // 启动时预创建的房间
+	UDPAddr       string   `json:"udpAddr"`       // UDP 监听地址，为空则不启用
+
+	SlowConsumer SlowConsumerPolicy `json:"slowConsumer"`
+	Watchdog     WatchdogConfig     `json:"watchdog"`
+
+	// Tick 调度模式：goroutine（每房间一个协程）或 pool（工作协程池，默认 GOMAXPROCS 个）
+	Scheduler        string `json:"scheduler"`
+	SchedulerWorkers int    `json:"schedulerWorkers"`
+
+	// 对局历史存储
+	History HistoryConfig `json:"history"`
+	// 玩家档案存储
+	Profiles ProfileStoreConfig `json:"profiles"`
+}
+
+// WatchdogConfig Tick 看门狗：房间超过 StallTicks 个 Tick 间隔未推进即判定停滞
+type WatchdogConfig struct {
+	StallTicks      int `json:"stallTicks"`
+	CheckIntervalMs int `json:"checkIntervalMs"`
+}
+
+// SlowConsumerPolicy 慢消费者处理策略（0 表示不启用对应规则）
+type SlowConsumerPolicy struct {
+	MaxConsecutiveDrops int `json:"maxConsecutiveDrops"` // 连续丢弃达到该次数即断开
+	MaxDegradedMs       int `json:"maxDegradedMs"`       // 持续处于“仅全量”降级超过该时长即断开
+	WriteStallMs        int `json:"writeStallMs"`        // 单次写出阻塞超过该时长即断开
+}
+
+// LoggingConfig 日志参数（仅 level 支持热加载）
+type LoggingConfig struct {
+	File       string `json:"file"`
+	Level      string `json:"level"` // debug / info / warn / error
+	MaxSizeMB  int    `json:"maxSizeMB"`
+	MaxBackups int    `json:"maxBackups"`
+	MaxAgeDays int    `json:"maxAgeDays"`
+	Compress   bool   `json:"compress"`
+}
+
+// RoomProfile 房间模拟参数
+type RoomProfile struct {
+	// 以下字段支持 SIGHUP 热加载
+	TicksPerSecond int     `json:"ticksPerSecond"` // 世界推进频率
+	Width          float64 `json:"width"`
+	Height         float64 `json:"height"`
+	SpawnX         float64 `json:"spawnX"`
+	SpawnY         float64 `json:"spawnY"`
+	// 移动速度与输入限流按秒定义，与 Tick 频率无关：每条输入移动 Speed / MaxInputsPerSecond，
+	// 每 Tick 的输入额度按当前频率换算（见 Room.setTickRate）
+	Speed              float64 `json:"speed"`              // 每秒最大移动距离
+	MaxInputsPerSecond int     `json:"maxInputsPerSecond"` // 每名玩家每秒接受的输入数
+	// 默认网络条件模拟（可通过管理接口按玩家覆盖）
+	Net NetConditions `json:"net"`
+	// 故障恢复：快照保存间隔（按 Tick 频率换算为帧数）；连续 panic 超过该次数则关闭房间
+	SnapshotIntervalMs   int `json:"snapshotIntervalMs"`
+	MaxConsecutiveFaults int `json:"maxConsecutiveFaults"`
+	// 追帧策略：catchup（补跑，最多 MaxCatchUpTicks 帧）或 skip（跳过落后的 Tick；旧名称 slowdown）
+	CatchUpPolicy   string `json:"catchUpPolicy"`
+	MaxCatchUpTicks int    `json:"maxCatchUpTicks"`
+	// 对局流程（等待 → 准备 → 倒计时 → 进行中 → 结算），默认关闭
+	Match MatchConfig `json:"match"`
+	// 对局进行中定时广播记分板的间隔，0 表示仅在客户端请求时发送
+	ScoreboardIntervalMs int `json:"scoreboardIntervalMs"`
+	// 房间聊天
+	Chat ChatConfig `json:"chat"`
+
+	// 以下字段仅在房间创建时生效
+	Mode       string          `json:"mode"`                 // 玩法名称，见 mode.go
+	ModeConfig json.RawMessage `json:"modeConfig,omitempty"` // 玩法参数，由玩法自行解析
+	// 队伍（为空则不分队）；指定队伍加入时允许的最大人数差
+	Teams            []TeamConfig `json:"teams,omitempty"`
+	MaxTeamImbalance int          `json:"maxTeamImbalance"`
+	NetSeed          int64        `json:"netSeed"` // 网络模拟随机种子，0 表示按时间
+	InputQueueSize   int          `json:"inputQueueSize"`
+}
+
+// DefaultConfig 返回与历史硬编码一致的默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:          ":8080",
+			WebDir:        "web",
+			SendQueueSize: 64,
+			StartRooms:    []string{"room-1"},
+			SlowConsumer: SlowConsumerPolicy{
+				MaxConsecutiveDrops: 32,
+				MaxDegradedMs:       5000,
+				WriteStallMs:        3000,
+			},
+			Watchdog: WatchdogConfig{
+				StallTicks:      10,
+				CheckIntervalMs: 500,
+			},
+			Scheduler: SchedulerGoroutine,
+			History:   HistoryConfig{MaxEntries: 1000},
+			Profiles:  ProfileStoreConfig{FlushMs: 5000},
+		},
+		Logging: LoggingConfig{
+			File:       "app.log",
+			Level:      "debug",
+			MaxSizeMB:  10,
+			MaxBackups: 3,
+			MaxAgeDays: 7,
+		},
+		Room: RoomProfile{
+			TicksPerSecond:     TicksPerSecond,
+			Width:              100,
+			Height:             100,
+			SpawnX:             50,
+			SpawnY:             50,
+			Speed:              TicksPerSecond, // 20 TPS 下每 Tick 一条输入、每条移动 1
+			MaxInputsPerSecond: TicksPerSecond,
+			// 与早期硬编码一致：入站延迟 150~300ms，丢包 10%
+			Net: NetConditions{
+				Inbound: NetProfile{LatencyMs: 150, JitterMs: 150, Loss: 0.10},
+			},
+			SnapshotIntervalMs:   1000,
+			MaxConsecutiveFaults: 3,
+			CatchUpPolicy:        CatchUpRun,
+			MaxCatchUpTicks:      3,
+			Match: MatchConfig{
+				MinPlayers:   2,
+				CountdownMs:  3000,
+				TimeLimitMs:  120000,
+				ResultsMs:    5000,
+				AfterResults: AfterResultsReset,
+			},
+			Chat: ChatConfig{
+				Enabled:     true,
+				MaxLength:   200,
+				PerSecond:   1,
+				Burst:       5,
+				HistorySize: 100,
+			},
+			Mode:             DefaultMode,
+			MaxTeamImbalance: 1,
+			InputQueueSize:   256,
+		},
+	}
+}
+
+// LoadConfig 读取配置：默认值 → 配置文件（可选）→ 环境变量
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		if err := json.Unmarshal(b, cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	cfg.path = path
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ProfileFor 返回指定房间的生效参数（默认值叠加房间覆盖）
+func (c *Config) ProfileFor(roomID string) RoomProfile {
+	p := c.Room
+	if raw, ok := c.Rooms[roomID]; ok {
+		// 校验阶段已保证可解析
+		_ = json.Unmarshal(raw, &p)
+	}
+	return p
+}
+
+// Validate 检查配置合法性
+func (c *Config) Validate() error {
+	if c.Server.SendQueueSize <= 0 {
+		return fmt.Errorf("server.sendQueueSize must be positive")
+	}
+	if sc := c.Server.SlowConsumer; sc.MaxConsecutiveDrops < 0 || sc.MaxDegradedMs < 0 || sc.WriteStallMs < 0 {
+		return fmt.Errorf("server.slowConsumer values must be non-negative")
+	}
+	if c.Server.Scheduler != SchedulerGoroutine && c.Server.Scheduler != SchedulerPool {
+		return fmt.Errorf("unknown server.scheduler: %q", c.Server.Scheduler)
+	}
+	if c.Server.SchedulerWorkers < 0 {
+		return fmt.Errorf("server.schedulerWorkers must be non-negative")
+	}
+	if c.Server.History.MaxEntries < 0 {
+		return fmt.Errorf("server.history.maxEntries must be non-negative")
+	}
+	if c.Server.Profiles.FlushMs <= 0 {
+		return fmt.Errorf("server.profiles.flushMs must be positive")
+	}
+	if c.Server.Watchdog.StallTicks <= 0 || c.Server.Watchdog.CheckIntervalMs <= 0 {
+		return fmt.Errorf("server.watchdog values must be positive")
+	}
+	if _, err := parseLogLevel(c.Logging.Level); err != nil {
+		return err
+	}
+	if err := c.Room.validate(); err != nil {
+		return fmt.Errorf("room: %w", err)
+	}
+	for id, raw := range c.Rooms {
+		p := c.Room
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return fmt.Errorf("rooms.%s: %w", id, err)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("rooms.%s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (p RoomProfile) validate() error {
+	if err := validateTickRate(p.TicksPerSecond); err != nil {
+		return err
+	}
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("world size must be positive")
+	}
+	if p.Speed <= 0 {
+		return fmt.Errorf("speed must be positive")
+	}
+	if p.MaxInputsPerSecond <= 0 {
+		return fmt.Errorf("maxInputsPerSecond must be positive")
+	}
+	if err := p.Net.Validate(); err != nil {
+		return fmt.Errorf("net: %w", err)
+	}
+	if p.SnapshotIntervalMs < 0 || p.MaxConsecutiveFaults < 0 {
+		return fmt.Errorf("recovery settings must be non-negative")
+	}
+	switch p.CatchUpPolicy {
+	case CatchUpRun, CatchUpSkip, catchUpSlowdown:
+	default:
+		return fmt.Errorf("unknown catchUpPolicy: %q", p.CatchUpPolicy)
+	}
+	if p.MaxCatchUpTicks < 0 {
+		return fmt.Errorf("maxCatchUpTicks must be non-negative")
+	}
+	if p.ScoreboardIntervalMs < 0 {
+		return fmt.Errorf("scoreboardIntervalMs must be non-negative")
+	}
+	if err := p.Match.Validate(); err != nil {
+		return fmt.Errorf("match: %w", err)
+	}
+	if err := p.Chat.Validate(); err != nil {
+		return fmt.Errorf("chat: %w", err)
+	}
+	if p.InputQueueSize <= 0 {
+		return fmt.Errorf("inputQueueSize must be positive")
+	}
+	if err := validateTeams(p.Teams, p.Width, p.Height, p.MaxTeamImbalance); err != nil {
+		return fmt.Errorf("teams: %w", err)
+	}
+	if _, err := newMode(p); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateTickRate 检查 Tick 频率范围
+func validateTickRate(tps int) error {
+	if tps <= 0 || tps > MaxTicksPerSecond {
+		return fmt.Errorf("ticksPerSecond out of range (1..%d): %d", MaxTicksPerSecond, tps)
+	}
+	return nil
+}
+
+// envOverrides 环境变量 → 配置字段
+var envOverrides = []struct {
+	name string
+	set  func(c *Config, v string) error
+}{
+	{"MINIARENA_ADDR", func(c *Config, v string) error { c.Server.Addr = v; return nil }},
+	{"MINIARENA_WEB_DIR", func(c *Config, v string) error { c.Server.WebDir = v; return nil }},
+	{"MINIARENA_UDP_ADDR", func(c *Config, v string) error { c.Server.UDPAddr = v; return nil }},
+	{"MINIARENA_TPS", func(c *Config, v string) error { return setInt(&c.Room.TicksPerSecond, v) }},
+	{"MINIARENA_SEND_QUEUE_SIZE", func(c *Config, v string) error { return setInt(&c.Server.SendQueueSize, v) }},
+	{"MINIARENA_SCHEDULER", func(c *Config, v string) error { c.Server.Scheduler = v; return nil }},
+	{"MINIARENA_SCHEDULER_WORKERS", func(c *Config, v string) error { return setInt(&c.Server.SchedulerWorkers, v) }},
+	{"MINIARENA_START_ROOMS", func(c *Config, v string) error { c.Server.StartRooms = splitList(v); return nil }},
+	{"MINIARENA_HISTORY_FILE", func(c *Config, v string) error { c.Server.History.File = v; return nil }},
+	{"MINIARENA_PROFILES_FILE", func(c *Config, v string) error { c.Server.Profiles.File = v; return nil }},
+	{"MINIARENA_LOG_FILE", func(c *Config, v string) error { c.Logging.File = v; return nil }},
+	{"MINIARENA_LOG_LEVEL", func(c *Config, v string) error { c.Logging.Level = v; return nil }},
+	{"MINIARENA_ROOM_WIDTH", func(c *Config, v string) error { return setFloat(&c.Room.Width, v) }},
+	{"MINIARENA_ROOM_HEIGHT", func(c *Config, v string) error { return setFloat(&c.Room.Height, v) }},
+	{"MINIARENA_ROOM_SPEED", func(c *Config, v string) error { return setFloat(&c.Room.Speed, v) }},
+	{"MINIARENA_ROOM_MODE", func(c *Config, v string) error { c.Room.Mode = v; return nil }},
+	{"MINIARENA_ROOM_MAX_INPUTS_PER_SECOND", func(c *Config, v string) error { return setInt(&c.Room.MaxInputsPerSecond, v) }},
+	{"MINIARENA_ROOM_IN_LATENCY_MS", func(c *Config, v string) error { return setInt(&c.Room.Net.Inbound.LatencyMs, v) }},
+	{"MINIARENA_ROOM_IN_JITTER_MS", func(c *Config, v string) error { return setInt(&c.Room.Net.Inbound.JitterMs, v) }},
+	{"MINIARENA_ROOM_IN_LOSS", func(c *Config, v string) error { return setFloat(&c.Room.Net.Inbound.Loss, v) }},
+	{"MINIARENA_ROOM_OUT_LATENCY_MS", func(c *Config, v string) error { return setInt(&c.Room.Net.Outbound.LatencyMs, v) }},
+	{"MINIARENA_ROOM_OUT_JITTER_MS", func(c *Config, v string) error { return setInt(&c.Room.Net.Outbound.JitterMs, v) }},
+	{"MINIARENA_ROOM_OUT_LOSS", func(c *Config, v string) error { return setFloat(&c.Room.Net.Outbound.Loss, v) }},
+	{"MINIARENA_ROOM_NET_SEED", func(c *Config, v string) error {
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		c.Room.NetSeed = n
+		return err
+	}},
+}
+
+func (c *Config) applyEnv() error {
+	for _, o := range envOverrides {
+		v, ok := os.LookupEnv(o.name)
+		if !ok {
+			continue
+		}
+		if err := o.set(c, v); err != nil {
+			return fmt.Errorf("env %s: %w", o.name, err)
+		}
+	}
+	return nil
+}
+
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+func setFloat(dst *float64, v string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return err
+	}
+	*dst = f
+	return nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var (
+	currentConfig atomic.Pointer[Config]
+	reloadMu      sync.Mutex
+)
+
+// CurrentConfig 返回当前生效配置（未设置时为默认值）
+func CurrentConfig() *Config {
+	if c := currentConfig.Load(); c != nil {
+		return c
+	}
+	return DefaultConfig()
+}
+
+// SetConfig 设置当前生效配置（启动时调用）
+func SetConfig(c *Config) {
+	currentConfig.Store(c)
+}
+
+// ReloadConfig 重新读取配置文件，并将可热更新的部分应用到运行中的房间
+// 不可热更新的字段发生变化时仅记录告警，保留旧值
+func ReloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	old := CurrentConfig()
+	next, err := LoadConfig(old.path)
+	if err != nil {
+		return err
+	}
+	// 进程级参数保持启动时的值
+	if !sameServerConfig(old.Server, next.Server) {
+		Log.Warnf("config reload: server section changed, restart required to apply")
+	}
+	next.Server = old.Server
+	level := next.Logging.Level
+	if next.Logging != old.Logging {
+		if next.Logging.File != old.Logging.File || next.Logging.MaxSizeMB != old.Logging.MaxSizeMB ||
+			next.Logging.MaxBackups != old.Logging.MaxBackups || next.Logging.MaxAgeDays != old.Logging.MaxAgeDays ||
+			next.Logging.Compress != old.Logging.Compress {
+			Log.Warnf("config reload: logging file settings changed, restart required to apply")
+		}
+		next.Logging = old.Logging
+		next.Logging.Level = level
+	}
+	if err := SetLogLevel(level); err != nil {
+		return err
+	}
+	SetConfig(next)
+
+	rooms := GetRoomManager().Rooms()
+	for _, room := range rooms {
+		room.ApplyProfile(next.ProfileFor(room.ID))
+	}
+	Log.Infof("config reloaded: path=%s rooms=%d level=%s", next.path, len(rooms), level)
+	return nil
+}
+
+func sameServerConfig(a, b ServerConfig) bool {
+	if a.Addr != b.Addr || a.WebDir != b.WebDir || a.SendQueueSize != b.SendQueueSize || a.UDPAddr != b.UDPAddr {
+		return false
+	}
+	if a.SlowConsumer != b.SlowConsumer || a.Watchdog != b.Watchdog || a.Scheduler != b.Scheduler || a.SchedulerWorkers != b.SchedulerWorkers ||
+		a.History != b.History || a.Profiles != b.Profiles {
+		return false
+	}
+	return strings.Join(a.StartRooms, ",") == strings.Join(b.StartRooms, ",")
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig 将配置内容写入临时文件并返回路径
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultConfig()
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("config without file differs from defaults:\n got %+v\nwant %+v", cfg, want)
+	}
+
+	// 文件只覆盖写出的字段
+	cfg, err = LoadConfig(writeConfig(t, `{"server":{"addr":":9000"},"room":{"width":200}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":9000" || cfg.Room.Width != 200 {
+		t.Fatalf("file values not applied: addr=%q width=%v", cfg.Server.Addr, cfg.Room.Width)
+	}
+	if cfg.Room.Height != want.Room.Height || cfg.Server.SendQueueSize != want.Server.SendQueueSize {
+		t.Fatal("defaults lost for fields missing from the file")
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	t.Setenv("MINIARENA_ADDR", ":7000")
+	t.Setenv("MINIARENA_TPS", "30")
+	t.Setenv("MINIARENA_START_ROOMS", "a, b,,c")
+	t.Setenv("MINIARENA_ROOM_IN_LOSS", "0.25")
+	t.Setenv("MINIARENA_ROOM_NET_SEED", "42")
+
+	// 环境变量优先于配置文件
+	cfg, err := LoadConfig(writeConfig(t, `{"server":{"addr":":9000"},"room":{"ticksPerSecond":10}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":7000" || cfg.Room.TicksPerSecond != 30 {
+		t.Fatalf("env did not override file: addr=%q tps=%d", cfg.Server.Addr, cfg.Room.TicksPerSecond)
+	}
+	if got := strings.Join(cfg.Server.StartRooms, ","); got != "a,b,c" {
+		t.Fatalf("start rooms = %q", got)
+	}
+	if cfg.Room.Net.Inbound.Loss != 0.25 || cfg.Room.NetSeed != 42 {
+		t.Fatalf("loss=%v seed=%d", cfg.Room.Net.Inbound.Loss, cfg.Room.NetSeed)
+	}
+
+	t.Setenv("MINIARENA_TPS", "fast")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "MINIARENA_TPS") {
+		t.Fatalf("bad env value: err=%v", err)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	cases := []struct {
+		name, body, want string
+	}{
+		{"malformed", `{"server":`, "parse config"},
+		{"send queue", `{"server":{"sendQueueSize":0}}`, "sendQueueSize"},
+		{"scheduler", `{"server":{"scheduler":"threads"}}`, "server.scheduler"},
+		{"watchdog", `{"server":{"watchdog":{"stallTicks":0}}}`, "watchdog"},
+		{"log level", `{"logging":{"level":"loud"}}`, "loud"},
+		{"tick rate", `{"room":{"ticksPerSecond":0}}`, "room: ticksPerSecond"},
+		{"catch-up", `{"room":{"catchUpPolicy":"rewind"}}`, "catchUpPolicy"},
+		{"room override", `{"rooms":{"arena":{"speed":-1}}}`, "rooms.arena: speed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tc.body))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want containing %q", err, tc.want)
+			}
+		})
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("missing file accepted")
+	}
+}
+
+func TestReloadConfigKeepsPreviousOnError(t *testing.T) {
+	prev := currentConfig.Load()
+	t.Cleanup(func() {
+		currentConfig.Store(prev)
+		_ = SetLogLevel("warn")
+	})
+
+	path := writeConfig(t, `{"logging":{"level":"warn"},"room":{"ticksPerSecond":20}}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetConfig(cfg)
+
+	if err := os.WriteFile(path, []byte(`{"logging":{"level":"warn"},"room":{"ticksPerSecond":-5}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfig(); err == nil {
+		t.Fatal("invalid file reloaded")
+	}
+	if CurrentConfig() != cfg {
+		t.Fatal("failed reload replaced the current config")
+	}
+
+	// 进程级参数保留启动值，房间参数更新
+	body := `{"server":{"addr":":9999"},"logging":{"level":"warn"},"room":{"ticksPerSecond":30}}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	next := CurrentConfig()
+	if next.Room.TicksPerSecond != 30 || next.Server.Addr != cfg.Server.Addr {
+		t.Fatalf("after reload tps=%d addr=%q", next.Room.TicksPerSecond, next.Server.Addr)
+	}
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 只读房间事件流（Server-Sent Events）：观察者不注册 Player，不参与模拟
+//
+// 每条事件的 id 为房间内单调递增的事件序号（同一 Tick 可有多条事件，Tick 在负载中），
+// 断线重连时携带 Last-Event-ID 即可从该事件之后续传；超出历史缓冲范围时改发一次 snapshot 重新对齐
+const (
+	eventHistorySize = 256              // 每个房间保留的最近事件数
+	eventHistoryKeep = 30 * time.Second // 最后一个观察者离开后继续记录的时长，便于续传
+)
+
+// roomEvent 一条已编码的房间事件
+type roomEvent struct {
+	id    int64
+	typ   string
+	frame *Frame
+}
+
+// eventLog 最近事件环形缓冲；仅在有观察者（或刚离开不久）时记录
+type eventLog struct {
+	buf       []roomEvent
+	start, n  int
+	seq       int64 // 最近一条事件的序号；未记录的事件同样占用序号，清空历史时不归零
+	keepUntil time.Time
+	watchers  map[*watcher]struct{}
+}
+
+// watcher 单个观察者；ch 由 Tick 线程写入与关闭
+type watcher struct {
+	ch chan roomEvent
+}
+
+func (l *eventLog) recording() bool {
+	return len(l.watchers) > 0 || time.Now().Before(l.keepUntil)
+}
+
+func (l *eventLog) push(e roomEvent) {
+	if l.buf == nil {
+		l.buf = make([]roomEvent, eventHistorySize)
+	}
+	e.frame.Retain()
+	if l.n == len(l.buf) {
+		l.buf[l.start].frame.Release()
+		l.buf[l.start] = e
+		l.start = (l.start + 1) % len(l.buf)
+		return
+	}
+	l.buf[(l.start+l.n)%len(l.buf)] = e
+	l.n++
+}
+
+// since 返回序号 id 之后的全部事件；缓冲无法覆盖该区间（含历史已清空）时返回 false
+func (l *eventLog) since(id int64) ([]roomEvent, bool) {
+	if id > l.seq {
+		return nil, false
+	}
+	if id == l.seq {
+		return nil, true
+	}
+	if l.n == 0 || l.buf[l.start].id > id+1 {
+		return nil, false
+	}
+	var out []roomEvent
+	for i := 0; i < l.n; i++ {
+		if e := l.buf[(l.start+i)%len(l.buf)]; e.id > id {
+			out = append(out, e)
+		}
+	}
+	return out, true
+}
+
+// skip 不记录时丢弃历史，但仍推进序号：此前的 id 续传时将落在缓冲之外而改发快照
+func (l *eventLog) skip() {
+	if l.n > 0 {
+		l.reset()
+	}
+	l.seq++
+}
+
+func (l *eventLog) reset() {
+	for i := 0; i < l.n; i++ {
+		l.buf[(l.start+i)%len(l.buf)].frame.Release()
+	}
+	l.buf, l.start, l.n = nil, 0, 0
+}
+
+// publish 记录一条事件并推送给观察者（Tick 线程调用）；跟不上的观察者被断开，由其携带 Last-Event-ID 重连续传
+func (r *Room) publish(typ string, f *Frame) {
+	l := &r.events
+	if !l.recording() {
+		l.skip()
+		return
+	}
+	l.seq++
+	e := roomEvent{id: l.seq, typ: typ, frame: f}
+	l.push(e)
+	for w := range l.watchers {
+		f.Retain()
+		select {
+		case w.ch <- e:
+		default:
+			f.Release()
+			Log.Warnf("event watcher lagging, disconnect: room=%s", r.ID)
+			r.unwatch(w)
+		}
+	}
+}
+
+// publishPresence 玩家加入/离开事件，仅推送给观察者
+func (r *Room) publishPresence(typ string, id PlayerID) {
+	if !r.events.recording() {
+		r.events.skip()
+		return
+	}
+	b := append([]byte(`{"type":"`), typ...)
+	b = append(b, `","tick":`...)
+	b = strconv.AppendInt(b, r.tickSeq, 10)
+	b = append(b, `,"id":`...)
+	b = appendJSONString(b, string(id))
+	f := frameFromBytes(append(b, '}'))
+	r.publish(typ, f)
+	f.Release()
+}
+
+// watch 登记观察者并补发事件：可续传时补发 lastID 之后的历史，否则发送一次快照
+func (r *Room) watch(w *watcher, lastID int64, resume bool) {
+	l := &r.events
+	if l.watchers == nil {
+		l.watchers = make(map[*watcher]struct{})
+	}
+	l.watchers[w] = struct{}{}
+	r.metrics.SetWatchers(len(l.watchers))
+
+	if resume {
+		if backlog, ok := l.since(lastID); ok && len(backlog) <= cap(w.ch) {
+			for _, e := range backlog {
+				e.frame.Retain()
+				w.ch <- e
+			}
+			return
+		}
+	}
+	f := r.encodeState("snapshot")
+	// 快照反映到最近一条事件为止的状态，之后的事件从下一个序号继续
+	w.ch <- roomEvent{id: l.seq, typ: "snapshot", frame: f}
+}
+
+// unwatch 移除观察者并关闭其通道（Tick 线程调用，可重复调用）
+func (r *Room) unwatch(w *watcher) {
+	l := &r.events
+	if _, ok := l.watchers[w]; !ok {
+		return
+	}
+	delete(l.watchers, w)
+	close(w.ch)
+	r.metrics.SetWatchers(len(l.watchers))
+	if len(l.watchers) == 0 {
+		l.keepUntil = time.Now().Add(eventHistoryKeep)
+	}
+}
+
+// HandleRooms 房间公开接口（按路径分发）
+// GET /rooms              大厅房间列表（玩法、阶段、人数与平均评分）
+// GET /rooms/{id}/events  只读事件流（SSE），支持 Last-Event-ID 续传（按事件序号，而非 Tick）
+// GET /rooms/{id}/match   当前对局阶段与最近一局结算
+func HandleRooms(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/rooms"))
+	if len(parts) == 0 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		serveLobby(w)
+		return
+	}
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	room, ok := GetRoomManager().GetRoom(parts[0])
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	switch parts[1] {
+	case "events":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		serveEvents(w, r, room)
+	case "match":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		serveMatch(w, room)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveEvents 以 SSE 格式写出房间事件，直到客户端断开、落后过多或房间关闭
+func serveEvents(w http.ResponseWriter, r *http.Request, room *Room) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// Last-Event-ID 是事件序号：同一 Tick 可有多条事件，按 Tick 续传会重复或遗漏
+	lastID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	resume := err == nil
+
+	// 容量足以一次补发全部历史
+	wt := &watcher{ch: make(chan roomEvent, 2*eventHistorySize)}
+	if !room.Query(func() { room.watch(wt, lastID, resume) }) {
+		http.Error(w, "room closed", http.StatusGone)
+		return
+	}
+	defer func() {
+		room.Do(func() { room.unwatch(wt) })
+		// 归还未写出的事件引用（通道由 Tick 线程关闭）
+		for {
+			select {
+			case e, ok := <-wt.ch:
+				if !ok {
+					return
+				}
+				e.frame.Release()
+			case <-room.done:
+				return
+			}
+		}
+	}()
+	Log.Infof("event watcher connected: room=%s resume=%v last=%d", room.ID, resume, lastID)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	var buf []byte
+	for {
+		select {
+		case e, ok := <-wt.ch:
+			if !ok {
+				return
+			}
+			buf = append(buf[:0], "id: "...)
+			buf = strconv.AppendInt(buf, e.id, 10)
+			buf = append(buf, "\nevent: "...)
+			buf = append(buf, e.typ...)
+			buf = append(buf, "\ndata: "...)
+			buf = e.frame.AppendTo(buf, 0)
+			buf = append(buf, "\n\n"...)
+			e.frame.Release()
+			if _, err := w.Write(buf); err != nil {
+				return
+			}
+			// 已排队的事件合并为一次刷新
+			if len(wt.ch) == 0 {
+				flusher.Flush()
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-room.done:
+			return
+		}
+	}
+}
+
+// lobbyRoom 大厅列表中的一个房间
+type lobbyRoom struct {
+	ID      string   `json:"id"`
+	Mode    string   `json:"mode"`
+	Phase   Phase    `json:"phase"`
+	Rated   bool     `json:"rated"`
+	Players int      `json:"players"`
+	Teams   []string `json:"teams,omitempty"`
+	Rating  float64  `json:"rating"` // 在线玩家的平均评分（无玩家时为 0）
+}
+
+// serveLobby 列出运行中的房间及其在线玩家的平均评分，供选择房间与匹配参考
+func serveLobby(w http.ResponseWriter) {
+	out := make([]lobbyRoom, 0)
+	for _, room := range GetRoomManager().Rooms() {
+		var lr lobbyRoom
+		var ids []PlayerID
+		ok := room.Query(func() {
+			lr = lobbyRoom{ID: room.ID, Mode: room.mode.Name(), Phase: room.match.phase, Rated: room.match.cfg.Rated, Players: len(room.Players)}
+			for _, t := range room.teams {
+				lr.Teams = append(lr.Teams, t.ID)
+			}
+			for id := range room.Players {
+				ids = append(ids, id)
+			}
+		})
+		if !ok {
+			continue
+		}
+		// 评分从档案存储读取，不占用 Tick 线程
+		for _, id := range ids {
+			lr.Rating += PlayerRating(id).Rating
+		}
+		if len(ids) > 0 {
+			lr.Rating = math.Round(lr.Rating / float64(len(ids)))
+		}
+		out = append(out, lr)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"rooms": out})
+}
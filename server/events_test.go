@@ -0,0 +1,90 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// watchEvents 在 Tick 线程之外直接登记观察者（测试房间不运行 Tick 循环），返回已排队的事件
+func watchEvents(t *testing.T, r *Room, lastID int64, resume bool) (*watcher, []roomEvent) {
+	t.Helper()
+	w := &watcher{ch: make(chan roomEvent, 2*eventHistorySize)}
+	r.watch(w, lastID, resume)
+	t.Cleanup(func() { r.unwatch(w) })
+	var out []roomEvent
+	for len(w.ch) > 0 {
+		e := <-w.ch
+		e.frame.Release()
+		out = append(out, e)
+	}
+	return w, out
+}
+
+func TestEventIDsAreUniqueWithinTick(t *testing.T) {
+	r := testRoom(t, nil)
+	w, first := watchEvents(t, r, 0, false)
+	if len(first) != 1 || first[0].typ != "snapshot" || first[0].id != 0 {
+		t.Fatalf("initial events = %+v, want one snapshot with id 0", first)
+	}
+
+	ConnectMem(r, "alice", 64)
+	ConnectMem(r, "bob", 64)
+	r.runTick(time.Now())
+	var ids []int64
+	for len(w.ch) > 0 {
+		e := <-w.ch
+		e.frame.Release()
+		ids = append(ids, e.id)
+	}
+	if len(ids) < 3 {
+		t.Fatalf("got %d events in one tick, want joins and a broadcast", len(ids))
+	}
+	for i, id := range ids {
+		if id != int64(i+1) {
+			t.Fatalf("event ids = %v, want 1..%d", ids, len(ids))
+		}
+	}
+
+	// 从同一 Tick 内的中间事件续传，只补发其后的事件
+	_, backlog := watchEvents(t, r, 1, true)
+	if len(backlog) != len(ids)-1 || backlog[0].id != 2 {
+		t.Fatalf("resume from 1: got %d events starting at %+v", len(backlog), backlog)
+	}
+	_, backlog = watchEvents(t, r, ids[len(ids)-1], true)
+	if len(backlog) != 0 {
+		t.Fatalf("resume from latest: got %+v, want nothing", backlog)
+	}
+	_, backlog = watchEvents(t, r, 1000, true)
+	if len(backlog) != 1 || backlog[0].typ != "snapshot" || backlog[0].id != ids[len(ids)-1] {
+		t.Fatalf("resume from unknown id: got %+v, want snapshot", backlog)
+	}
+}
+
+func TestResumeAfterUnrecordedEventsSendsSnapshot(t *testing.T) {
+	r := testRoom(t, nil)
+	ConnectMem(r, "alice", 64)
+	w := &watcher{ch: make(chan roomEvent, 2*eventHistorySize)}
+	r.watch(w, 0, false)
+	r.runTick(time.Now())
+	var last int64
+	for len(w.ch) > 0 {
+		e := <-w.ch
+		e.frame.Release()
+		last = e.id
+	}
+	r.unwatch(w)
+
+	// 保留期结束后的事件不再记录，但仍占用序号
+	r.events.keepUntil = time.Now().Add(-time.Second)
+	r.runTick(time.Now())
+	ConnectMem(r, "bob", 64)
+	r.runTick(time.Now())
+	if r.events.seq == last {
+		t.Fatal("unrecorded publishes did not advance the sequence")
+	}
+
+	_, backlog := watchEvents(t, r, last, true)
+	if len(backlog) != 1 || backlog[0].typ != "snapshot" || backlog[0].id != r.events.seq {
+		t.Fatalf("resume after unrecorded events: got %+v, want snapshot at %d", backlog, r.events.seq)
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Frame 一条已编码的出站消息；同一 Frame 被多个接收者共享，按引用计数归还缓冲池
+//
+// 按接收者定制的消息（state / delta / snapshot）只编码一次共享主体，
+// 写出时由各连接在前面拼上自己的小头部 {"tick":T,"ack":A, ——主体从首个字段开始，无需重新编码
+type Frame struct {
+	buf      []byte
+	refs     int32
+	personal bool  // 是否需要拼接接收者头部
+	tick     int64 // 头部中的服务器 Tick
+	reliable bool  // 需可靠有序投递（快照、关闭通知等）；不可靠传输据此选择通道
+	unpooled bool  // 包装外部负载，释放后不归还缓冲池
+}
+
+var framePool = sync.Pool{
+	New: func() any { return &Frame{buf: make([]byte, 0, 1024)} },
+}
+
+// newFrame 从池中取出一个空 Frame，调用方持有 1 个引用
+func newFrame() *Frame {
+	f := framePool.Get().(*Frame)
+	f.buf = f.buf[:0]
+	f.refs = 1
+	f.personal = false
+	f.tick = 0
+	f.reliable = false
+	f.unpooled = false
+	return f
+}
+
+// newPersonalFrame 取出一个需要接收者头部的 Frame；主体以首个字段开头（不含 '{'）
+func newPersonalFrame(tick int64) *Frame {
+	f := newFrame()
+	f.personal = true
+	f.tick = tick
+	return f
+}
+
+// frameFromBytes 包装一段独立的负载（低频消息使用，不经过缓冲池：释放后交由 GC 回收）
+func frameFromBytes(b []byte) *Frame {
+	return &Frame{buf: b, refs: 1, unpooled: true}
+}
+
+// Reliable 是否需要可靠有序投递
+func (f *Frame) Reliable() bool { return f.reliable }
+
+// Bytes 返回编码后的共享主体（只读）
+func (f *Frame) Bytes() []byte { return f.buf }
+
+// AppendHeader 向 dst 追加接收者头部；普通消息无头部
+func (f *Frame) AppendHeader(dst []byte, ack int64) []byte {
+	if !f.personal {
+		return dst
+	}
+	dst = append(dst, `{"tick":`...)
+	dst = strconv.AppendInt(dst, f.tick, 10)
+	dst = append(dst, `,"ack":`...)
+	dst = strconv.AppendInt(dst, ack, 10)
+	return append(dst, ',')
+}
+
+// AppendTo 追加完整消息（头部 + 主体），用于需要连续字节的场景
+func (f *Frame) AppendTo(dst []byte, ack int64) []byte {
+	return append(f.AppendHeader(dst, ack), f.buf...)
+}
+
+// outbound 发送队列元素：共享 Frame + 该接收者的确认序列
+type outbound struct {
+	frame *Frame
+	ack   int64
+}
+
+// Retain 增加一个引用（入队前调用）
+func (f *Frame) Retain() { atomic.AddInt32(&f.refs, 1) }
+
+// Release 释放一个引用；最后一个引用释放时归还缓冲池
+func (f *Frame) Release() {
+	if atomic.AddInt32(&f.refs, -1) == 0 {
+		// 外部负载与超大缓冲不回收，避免池中的 Frame 引用外部切片或常驻大块内存
+		if !f.unpooled && cap(f.buf) <= 64<<10 {
+			framePool.Put(f)
+		}
+	}
+}
+
+// 以下为热路径使用的手写 JSON 编码，直接追加到复用缓冲区
+
+func appendJSONString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' && c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if c < utf8.RuneSelf {
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, `\u00`...)
+				b = append(b, hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONFloat 与 encoding/json 相同的数字格式；NaN 与 ±Inf 不是合法 JSON 数字，编码为 null
+func appendJSONFloat(b []byte, f float64) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return append(b, "null"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// 指数去掉前导 0：1e-07 → 1e-7
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// appendPlayerState 编码 {"id":..,"x":..,"y":..}，有队伍时追加 "team"
+func appendPlayerState(b []byte, p *Player) []byte {
+	b = append(b, `{"id":`...)
+	b = appendJSONString(b, string(p.ID))
+	b = append(b, `,"x":`...)
+	b = appendJSONFloat(b, p.X)
+	b = append(b, `,"y":`...)
+	b = appendJSONFloat(b, p.Y)
+	if p.Team != "" {
+		b = append(b, `,"team":`...)
+		b = appendJSONString(b, p.Team)
+	}
+	return append(b, '}')
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestAppendJSONString(t *testing.T) {
+	cases := []string{
+		"",
+		"alice",
+		`quote " and backslash \`,
+		"line\nbreak\rreturn\ttab",
+		"control \x00\x01\x08\x0c\x1f\x7f",
+		"html <b>&amp;</b>",
+		"中文玩家",
+		"emoji 🚩 and combining é",
+		"separators \u2028 \u2029",
+		"invalid \xff\xfe utf-8",
+		"truncated \xe4\xb8",
+		"\xed\xa0\x80 surrogate",
+	}
+	for _, s := range cases {
+		got := appendJSONString(nil, s)
+		if !json.Valid(got) {
+			t.Errorf("%q: invalid JSON %s", s, got)
+			continue
+		}
+		var decoded, want string
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		ref, _ := json.Marshal(s)
+		if err := json.Unmarshal(ref, &want); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if decoded != want {
+			t.Errorf("%q: decoded %q, encoding/json gives %q", s, decoded, want)
+		}
+	}
+}
+
+func TestAppendJSONStringAppends(t *testing.T) {
+	got := appendJSONString([]byte(`{"id":`), "a\"b")
+	if string(got) != `{"id":"a\"b"` {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestAppendJSONFloat(t *testing.T) {
+	cases := []float64{
+		0, math.Copysign(0, -1), 1, -1, 0.5, 50, 99.99999, 1.0 / 3,
+		1e-6, 9.99e-7, 1e-7, 1.5e-300, math.SmallestNonzeroFloat64,
+		1e20, 1e21, 123456789012345678901234.0, -1e22, math.MaxFloat64,
+	}
+	for _, f := range cases {
+		want, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := appendJSONFloat(nil, f); string(got) != string(want) {
+			t.Errorf("%v: got %s, encoding/json gives %s", f, got, want)
+		}
+	}
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if got := appendJSONFloat(nil, f); string(got) != "null" {
+			t.Errorf("%v: got %s, want null", f, got)
+		}
+	}
+}
+
+func TestAppendPlayerState(t *testing.T) {
+	for _, p := range []*Player{
+		{ID: "alice", X: 12.5, Y: 0},
+		{ID: `we"ird\name`, X: -3, Y: 1e21, Team: "红队"},
+	} {
+		b := appendPlayerState(nil, p)
+		var got struct {
+			ID   PlayerID `json:"id"`
+			X, Y float64
+			Team string `json:"team"`
+		}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if got.ID != p.ID || got.X != p.X || got.Y != p.Y || got.Team != p.Team {
+			t.Errorf("round trip %s: got %+v", b, got)
+		}
+	}
+}
+
+func TestFrameHeader(t *testing.T) {
+	f := newPersonalFrame(42)
+	f.buf = append(f.buf, `"type":"delta"}`...)
+	defer f.Release()
+	got := f.AppendTo(nil, 7)
+	if string(got) != `{"tick":42,"ack":7,"type":"delta"}` {
+		t.Fatalf("got %s", got)
+	}
+	plain := frameFromBytes([]byte(`{"type":"phase"}`))
+	if got := plain.AppendTo(nil, 7); string(got) != `{"type":"phase"}` {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestFrameFromBytesNotPooled(t *testing.T) {
+	payload := []byte(`{"type":"chat"}`)
+	f := frameFromBytes(payload)
+	f.Release()
+	for i := 0; i < 4; i++ {
+		g := newFrame()
+		if g == f {
+			t.Fatal("frame wrapping an external payload returned to the pool")
+		}
+		defer g.Release()
+	}
+	if string(payload) != `{"type":"chat"}` {
+		t.Fatalf("external payload modified: %s", payload)
+	}
+}
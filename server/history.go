@@ -0,0 +1,289 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HistoryConfig 对局历史存储参数（进程级）
+type HistoryConfig struct {
+	File       string `json:"file"`       // JSON Lines 文件，每局一行；为空则仅保存在内存
+	MaxEntries int    `json:"maxEntries"` // 内存中保留（及启动时从文件加载）的最近对局数
+}
+
+// HistoryQuery 对局历史查询条件（空字段不过滤），结果按结束时间倒序
+type HistoryQuery struct {
+	Room   string
+	Player PlayerID
+	Mode   string
+	Offset int
+	Limit  int
+}
+
+// MatchHistory 对局历史存储：房间在结算时写入（Tick 线程，不得阻塞），HTTP 接口查询
+type MatchHistory interface {
+	Record(res *MatchResult) error
+	Query(q HistoryQuery) (matches []*MatchResult, total int)
+	Get(id string) (*MatchResult, bool)
+	Close() error
+}
+
+// historyStore 内存环形保留最近对局，可选追加写入 JSON Lines 文件（后台协程写出）
+type historyStore struct {
+	mu      sync.RWMutex
+	entries []*MatchResult // 按结束先后
+	max     int
+
+	file   *os.File
+	writes chan *MatchResult
+	done   chan struct{}
+	closed bool // 已关闭后拒绝写入（受 mu 保护）
+}
+
+const historyWriteQueue = 64
+
+var errHistoryClosed = errors.New("history closed")
+
+// OpenMatchHistory 按配置创建对局历史；配置了文件时加载其中最近的对局并追加写入
+func OpenMatchHistory(cfg HistoryConfig) (MatchHistory, error) {
+	h := &historyStore{max: cfg.MaxEntries}
+	if cfg.File == "" {
+		return h, nil
+	}
+	if err := h.load(cfg.File); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	h.file = f
+	h.writes = make(chan *MatchResult, historyWriteQueue)
+	h.done = make(chan struct{})
+	go h.writeLoop()
+	return h, nil
+}
+
+func (h *historyStore) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		var res MatchResult
+		if err := json.Unmarshal(sc.Bytes(), &res); err != nil {
+			Log.Warnf("history line skipped: file=%s line=%d err=%v", path, line, err)
+			continue
+		}
+		h.append(&res)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+	Log.Infof("history loaded: file=%s matches=%d", path, len(h.entries))
+	return nil
+}
+
+func (h *historyStore) append(res *MatchResult) {
+	h.entries = append(h.entries, res)
+	if h.max > 0 && len(h.entries) > h.max {
+		n := len(h.entries) - h.max
+		clear(h.entries[:n])
+		h.entries = h.entries[n:]
+	}
+}
+
+// Record 保存一局结果；文件写出在后台进行，队列满时仅保留在内存
+func (h *historyStore) Record(res *MatchResult) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// 持锁投递，Close 不会在判断与发送之间关闭写队列
+	if h.closed {
+		return errHistoryClosed
+	}
+	h.append(res)
+	if h.writes == nil {
+		return nil
+	}
+	select {
+	case h.writes <- res:
+		return nil
+	default:
+		return fmt.Errorf("history write queue full")
+	}
+}
+
+func (h *historyStore) writeLoop() {
+	defer close(h.done)
+	w := bufio.NewWriter(h.file)
+	enc := json.NewEncoder(w)
+	for res := range h.writes {
+		if err := enc.Encode(res); err != nil {
+			Log.Errorf("history write failed: match=%s err=%v", res.ID, err)
+			continue
+		}
+		if len(h.writes) == 0 {
+			if err := w.Flush(); err != nil {
+				Log.Errorf("history flush failed: err=%v", err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		Log.Errorf("history flush failed: err=%v", err)
+	}
+}
+
+func (h *historyStore) Query(q HistoryQuery) ([]*MatchResult, int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var out []*MatchResult
+	total := 0
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		res := h.entries[i]
+		if !q.matches(res) {
+			continue
+		}
+		if total >= q.Offset && (q.Limit <= 0 || len(out) < q.Limit) {
+			out = append(out, res)
+		}
+		total++
+	}
+	return out, total
+}
+
+func (q HistoryQuery) matches(res *MatchResult) bool {
+	if q.Room != "" && res.Room != q.Room {
+		return false
+	}
+	if q.Mode != "" && res.Mode != q.Mode {
+		return false
+	}
+	if q.Player == "" {
+		return true
+	}
+	for _, s := range res.Players {
+		if s.ID == q.Player {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *historyStore) Get(id string) (*MatchResult, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].ID == id {
+			return h.entries[i], true
+		}
+	}
+	return nil, false
+}
+
+// Close 写出排队中的记录并关闭文件
+func (h *historyStore) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	close(h.writes)
+	<-h.done
+	return h.file.Close()
+}
+
+var (
+	historyMu sync.RWMutex
+	history   MatchHistory = &historyStore{max: 1000}
+)
+
+// SetMatchHistory 替换全局对局历史存储（启动时调用）
+func SetMatchHistory(h MatchHistory) {
+	historyMu.Lock()
+	history = h
+	historyMu.Unlock()
+}
+
+// GetMatchHistory 全局对局历史存储；未设置时为仅内存存储
+func GetMatchHistory() MatchHistory {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	return history
+}
+
+// recordMatch 将结算结果写入历史（Tick 线程）
+func recordMatch(res *MatchResult) {
+	if err := GetMatchHistory().Record(res); err != nil {
+		Log.Warnf("history record failed: match=%s err=%v", res.ID, err)
+	}
+}
+
+// HandleMatches 对局历史查询
+//
+//	GET /matches?room=&player=&mode=&offset=&limit=  最近对局（倒序分页，limit 默认 20、最大 100）
+//	GET /matches/{id}                                单局结算结果
+func HandleMatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h := GetMatchHistory()
+	if id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/matches"), "/"); id != "" {
+		res, ok := h.Get(id)
+		if !ok {
+			http.Error(w, "match not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	qs := r.URL.Query()
+	q := HistoryQuery{Room: qs.Get("room"), Player: PlayerID(qs.Get("player")), Mode: qs.Get("mode"), Limit: 20}
+	if v := qs.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		q.Offset = n
+	}
+	if v := qs.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(n, 100)
+	}
+	matches, total := h.Query(q)
+	if matches == nil {
+		matches = []*MatchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"total":   total,
+		"offset":  q.Offset,
+		"matches": matches,
+	})
+}
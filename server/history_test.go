@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryRecordAfterClose(t *testing.T) {
+	cfg := HistoryConfig{File: filepath.Join(t.TempDir(), "matches.jsonl"), MaxEntries: 10}
+	h, err := OpenMatchHistory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Record(&MatchResult{ID: "m-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Record(&MatchResult{ID: "m-2"}); !errors.Is(err, errHistoryClosed) {
+		t.Fatalf("record after close: err=%v, want errHistoryClosed", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+
+	// 重新打开后只有关闭前写入的对局
+	h, err = OpenMatchHistory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if _, total := h.Query(HistoryQuery{}); total != 1 {
+		t.Fatalf("reloaded %d matches, want 1", total)
+	}
+}
+
+// historyFixture 替换全局对局历史，按顺序结算 n 局（偶数局在 room-a，奇数局在 room-b）
+func historyFixture(t *testing.T, n int) {
+	t.Helper()
+	prev := GetMatchHistory()
+	h, err := OpenMatchHistory(HistoryConfig{MaxEntries: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetMatchHistory(h)
+	t.Cleanup(func() { SetMatchHistory(prev) })
+	for i := 0; i < n; i++ {
+		room := []string{"room-a", "room-b"}[i%2]
+		res := &MatchResult{ID: fmt.Sprintf("m-%d", i), Room: room, Mode: DefaultMode, Players: []Standing{{ID: "alice"}}}
+		if i%3 == 0 {
+			res.Players = append(res.Players, Standing{ID: "bob"})
+		}
+		if err := h.Record(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// matchPage /matches 响应
+type matchPage struct {
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+	Matches []*MatchResult `json:"matches"`
+}
+
+func (p matchPage) ids() string {
+	ids := make([]string, len(p.Matches))
+	for i, m := range p.Matches {
+		ids[i] = m.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestHandleMatchesPagination(t *testing.T) {
+	historyFixture(t, 30)
+	cases := []struct {
+		query     string
+		total     int
+		count     int
+		first     string
+		wantSlice string
+	}{
+		{"", 30, 20, "m-29", ""},
+		{"?limit=3", 30, 3, "m-29", "m-29,m-28,m-27"},
+		{"?offset=27&limit=5", 30, 3, "m-2", "m-2,m-1,m-0"},
+		{"?offset=40", 30, 0, "", ""},
+		{"?limit=500", 30, 30, "m-29", ""},
+		{"?room=room-a&limit=3", 15, 3, "m-28", "m-28,m-26,m-24"},
+		{"?player=bob&offset=1&limit=2", 10, 2, "m-24", "m-24,m-21"},
+		{"?room=room-b&player=bob", 5, 5, "m-27", "m-27,m-21,m-15,m-9,m-3"},
+		{"?mode=ctf", 0, 0, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			code, body := serve(HandleMatches, http.MethodGet, "/matches"+tc.query, "")
+			if code != http.StatusOK {
+				t.Fatalf("GET = %d %s", code, body)
+			}
+			var page matchPage
+			if err := json.Unmarshal([]byte(body), &page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Matches == nil {
+				t.Fatalf("matches must be an array: %s", body)
+			}
+			if page.Total != tc.total || len(page.Matches) != tc.count {
+				t.Fatalf("total=%d count=%d, want %d and %d", page.Total, len(page.Matches), tc.total, tc.count)
+			}
+			if tc.count > 0 && page.Matches[0].ID != tc.first {
+				t.Fatalf("first = %s, want %s", page.Matches[0].ID, tc.first)
+			}
+			if tc.wantSlice != "" && page.ids() != tc.wantSlice {
+				t.Fatalf("matches = %s, want %s", page.ids(), tc.wantSlice)
+			}
+		})
+	}
+}
+
+func TestHandleMatchesRejectsBadQuery(t *testing.T) {
+	historyFixture(t, 1)
+	for _, target := range []string{"/matches?limit=0", "/matches?limit=x", "/matches?offset=-1"} {
+		if code, body := serve(HandleMatches, http.MethodGet, target, ""); code != http.StatusBadRequest {
+			t.Fatalf("GET %s = %d %s, want 400", target, code, body)
+		}
+	}
+	if code, _ := serve(HandleMatches, http.MethodPost, "/matches", ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST = %d, want 405", code)
+	}
+}
+
+func TestHandleMatchByID(t *testing.T) {
+	historyFixture(t, 3)
+	code, body := serve(HandleMatches, http.MethodGet, "/matches/m-1", "")
+	if code != http.StatusOK {
+		t.Fatalf("GET = %d %s", code, body)
+	}
+	var res MatchResult
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != "m-1" || res.Room != "room-b" {
+		t.Fatalf("match = %+v", res)
+	}
+	if code, _ := serve(HandleMatches, http.MethodGet, "/matches/m-9", ""); code != http.StatusNotFound {
+		t.Fatalf("unknown id = %d, want 404", code)
+	}
+}
@@ -1,5 +1,9 @@
 package server
 
+import (
+    "strings"
+)
+
 // Input 客户端输入（意图），由服务端在 Tick 中解释并驱动世界状态
 type Input struct {
     PlayerID PlayerID
@@ -7,10 +11,41 @@ type Input struct {
     Seq      int64 // 客户端本地序列号，用于去重与确认
 }
 
-// 入站输入的简单 JSON 结构（WebSocket 文本消息）
-// 示例：{"type":"move","command":"up"}
+// InputMessage move 消息（WebSocket 文本 / UDP 载荷）
+// 示例：{"type":"move","command":"up","seq":17}
 type InputMessage struct {
     Type    string `json:"type"`
     Command string `json:"command"`
     Seq     int64  `json:"seq,omitempty"`
 }
+
+func init() {
+    // move 在读协程中直接进入输入队列，限流由房间的每 Tick 输入上限负责
+    RegisterMessage("move", JSONHandler(OnRead, func(ctx *MessageContext, im *InputMessage) error {
+        // 未知或空方向按原地不动处理，仍推进确认序列（客户端可据此发送空闲输入）
+        ctx.Room.OnInput(decodeInput(*im, ctx.Player))
+        return nil
+    }))
+}
+
+// directionNames move 消息的方向名称，未知名称为 DirNone
+var directionNames = map[string]Direction{
+    "up":    DirUp,
+    "down":  DirDown,
+    "left":  DirLeft,
+    "right": DirRight,
+}
+
+// decodeInput 将 move 消息转换为输入
+func decodeInput(im InputMessage, playerID PlayerID) Input {
+    dir := directionNames[strings.ToLower(im.Command)]
+    // 调试日志：观察输入是否被识别
+    // 示例：input player=alice type=move cmd=right seq=123 dir=DirRight
+    // 注意：线上应调整为更轻量的日志
+    if dir == DirNone {
+        Log.Debugf("input unrecognized: player=%s type=%s cmd=%s seq=%d", playerID, im.Type, im.Command, im.Seq)
+    } else {
+        Log.Debugf("input recv: player=%s type=%s cmd=%s seq=%d", playerID, im.Type, im.Command, im.Seq)
+    }
+    return Input{PlayerID: playerID, Command: dir, Seq: im.Seq}
+}
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"fmt"
+	"strings"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -9,16 +12,24 @@ import (
 // Log 是全局可用的 SugaredLogger，用于统一日志输出到文件
 var Log *zap.SugaredLogger
 
+// logLevel 可动态调整的日志级别（配置热加载）
+var logLevel = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
 // InitLogger 初始化 zap 日志到本地文件（支持滚动）
-// filePath: 日志文件路径，如 "app.log"
-func InitLogger(filePath string) error {
-	// 文件滚动策略：10MB 每文件，保留3个备份，按天备份可选
+// cfg.File: 日志文件路径，如 "app.log"
+func InitLogger(cfg LoggingConfig) error {
+	level, err := parseLogLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	logLevel.SetLevel(level)
+	// 文件滚动策略：按大小滚动，保留若干备份
 	lj := &lumberjack.Logger{
-		Filename:   filePath,
-		MaxSize:    10, // MB
-		MaxBackups: 3,
-		MaxAge:     7, // days
-		Compress:   false,
+		Filename:   cfg.File,
+		MaxSize:    cfg.MaxSizeMB, // MB
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays, // days
+		Compress:   cfg.Compress,
 	}
 
 	ws := zapcore.AddSync(lj)
@@ -36,7 +47,7 @@ func InitLogger(filePath string) error {
 	}
 	// 控制台风格更易读，也可改为 JSON：zapcore.NewJSONEncoder(encCfg)
 	encoder := zapcore.NewConsoleEncoder(encCfg)
-	core := zapcore.NewCore(encoder, ws, zapcore.DebugLevel) // Debug 级别，便于排查
+	core := zapcore.NewCore(encoder, ws, logLevel)
 
 	// 添加调用者信息（文件:行号）
 	logger := zap.New(core, zap.AddCaller())
@@ -50,3 +61,27 @@ func SyncLogger() {
 		_ = Log.Sync()
 	}
 }
+
+// SetLogLevel 运行期调整日志级别
+func SetLogLevel(level string) error {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	logLevel.SetLevel(l)
+	return nil
+}
+
+func parseLogLevel(level string) (zapcore.Level, error) {
+	switch strings.ToLower(level) {
+	case "", "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "warn":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	return zapcore.DebugLevel, fmt.Errorf("unknown log level: %q", level)
+}
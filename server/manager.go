@@ -1,11 +1,19 @@
 package server
 
-import "sync"
+import (
+    "context"
+    "sort"
+    "sync"
+)
 
 // RoomManager 管理多个房间的生命周期
 type RoomManager struct {
     mu    sync.RWMutex
     rooms map[string]*Room
+    // 因故障被关闭的房间（room ID → 原因），供 /healthz 报告
+    closedFaulted map[string]string
+    // 进程退出中：不再创建会 Tick 的房间
+    closing bool
 }
 
 var (
@@ -16,7 +24,7 @@ var (
 // GetRoomManager 单例房间管理器
 func GetRoomManager() *RoomManager {
     once.Do(func() {
-        defaultManager = &RoomManager{rooms: make(map[string]*Room)}
+        defaultManager = &RoomManager{rooms: make(map[string]*Room), closedFaulted: make(map[string]string)}
     })
     return defaultManager
 }
@@ -26,11 +34,78 @@ func (m *RoomManager) GetOrCreateRoom(id string) *Room {
     m.mu.Lock()
     defer m.mu.Unlock()
     r, ok := m.rooms[id]
+    if !ok && m.closing {
+        // 退出过程中的迟到请求：返回已关闭的房间，调用方按房间关闭处理
+        r = NewRoom(id, CurrentConfig().ProfileFor(id))
+        r.Close()
+        return r
+    }
     if !ok {
-        r = NewRoom(id)
+        r = NewRoom(id, CurrentConfig().ProfileFor(id))
         m.rooms[id] = r
+        delete(m.closedFaulted, id)
         r.StartTicker()
     }
     return r
 }
 
+
+// GetRoom 查找已存在的房间（不创建）
+func (m *RoomManager) GetRoom(id string) (*Room, bool) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    r, ok := m.rooms[id]
+    return r, ok
+}
+
+// Rooms 返回当前所有房间的快照列表
+func (m *RoomManager) Rooms() []*Room {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    out := make([]*Room, 0, len(m.rooms))
+    for _, r := range m.rooms {
+        out = append(out, r)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+    return out
+}
+
+// Shutdown 停止全部房间并等待进行中的 Tick 结束；ctx 到期时返回其错误（停滞的房间可能仍未退出）
+func (m *RoomManager) Shutdown(ctx context.Context) error {
+    m.mu.Lock()
+    m.closing = true
+    m.mu.Unlock()
+    rooms := m.Rooms()
+    stopped := make(chan struct{})
+    go func() {
+        var wg sync.WaitGroup
+        for _, r := range rooms {
+            wg.Add(1)
+            go func(r *Room) {
+                defer wg.Done()
+                r.Stop("server shutdown")
+            }(r)
+        }
+        wg.Wait()
+        close(stopped)
+    }()
+    select {
+    case <-stopped:
+        Log.Infof("rooms stopped: count=%d", len(rooms))
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// removeRoom 将关闭的房间移出管理器；faultReason 非空时记录为故障关闭
+func (m *RoomManager) removeRoom(r *Room, faultReason string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if cur, ok := m.rooms[r.ID]; ok && cur == r {
+        delete(m.rooms, r.ID)
+    }
+    if faultReason != "" {
+        m.closedFaulted[r.ID] = faultReason
+    }
+}
@@ -0,0 +1,463 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Phase 对局阶段
+type Phase string
+
+const (
+	PhaseWaiting   Phase = "waiting"   // 等待玩家达到最少人数
+	PhaseReady     Phase = "ready"     // 准备确认：全部玩家发送 ready 后开始倒计时
+	PhaseCountdown Phase = "countdown" // 开局倒计时
+	PhasePlaying   Phase = "playing"   // 对局进行中（仅此阶段应用移动输入）
+	PhaseResults   Phase = "results"   // 结算展示
+)
+
+// 结算后的去向
+const (
+	AfterResultsReset = "reset" // 回到等待阶段，开始下一局
+	AfterResultsClose = "close" // 通知玩家并关闭房间
+)
+
+// 对局结束原因
+const (
+	EndTimeLimit = "time_limit" // 到达时限
+	EndAbandoned = "abandoned"  // 所有玩家离开
+)
+
+// MatchConfig 对局流程参数；未启用时房间始终处于 playing，与早期行为一致
+type MatchConfig struct {
+	Enabled      bool   `json:"enabled"`
+	MinPlayers   int    `json:"minPlayers"`   // 开局最少人数，人数不足时倒计时中止
+	ReadyCheck   bool   `json:"readyCheck"`   // 是否需要全部玩家确认 ready
+	CountdownMs  int    `json:"countdownMs"`  // 开局倒计时
+	TimeLimitMs  int    `json:"timeLimitMs"`  // 对局时限，0 表示不限时（由玩法判定胜负）
+	ResultsMs    int    `json:"resultsMs"`    // 结算展示时长
+	AfterResults string `json:"afterResults"` // reset | close
+	Rated        bool   `json:"rated"`        // 结算时更新参与者评分（所有玩家离开的对局不计分）
+}
+
+// Validate 检查对局参数
+func (c MatchConfig) Validate() error {
+	if c.MinPlayers < 1 {
+		return fmt.Errorf("minPlayers must be at least 1")
+	}
+	if c.CountdownMs < 0 || c.TimeLimitMs < 0 || c.ResultsMs < 0 {
+		return fmt.Errorf("durations must be non-negative")
+	}
+	if c.AfterResults != AfterResultsReset && c.AfterResults != AfterResultsClose {
+		return fmt.Errorf("unknown afterResults: %q", c.AfterResults)
+	}
+	return nil
+}
+
+// Standing 单个玩家的对局名次
+type Standing struct {
+	ID    PlayerID     `json:"id"`
+	Name  string       `json:"name,omitempty"` // 显示名
+	Team  string       `json:"team,omitempty"`
+	Score int          `json:"score"`
+	Rank  int          `json:"rank"`           // 1 为第一名，同分同名次
+	Left  bool         `json:"left,omitempty"` // 对局结束前已离开
+	Stats *PlayerStats `json:"stats,omitempty"`
+	// 计分对局结算后的评分与本局变化
+	Rating      float64 `json:"rating,omitempty"`
+	RatingDelta float64 `json:"ratingDelta,omitempty"`
+}
+
+// MatchResult 一局的结算结果
+type MatchResult struct {
+	ID        string         `json:"id"` // 全局唯一：房间、开局时间与序号
+	Room      string         `json:"room"`
+	Match     int64          `json:"match"` // 房间内对局序号
+	Mode      string         `json:"mode"`
+	Reason    string         `json:"reason"`
+	StartTick int64          `json:"startTick"`
+	EndTick   int64          `json:"endTick"`
+	StartedAt time.Time      `json:"startedAt"`
+	EndedAt   time.Time      `json:"endedAt"`
+	Players   []Standing     `json:"players"`
+	Teams     []TeamStanding `json:"teams,omitempty"` // 有队伍时按队伍分数排名
+	Rated     bool           `json:"rated,omitempty"` // 是否已更新评分
+}
+
+// matchState 对局状态机（仅 Tick 线程访问）
+type matchState struct {
+	cfg    MatchConfig
+	phase  Phase
+	endsAt int64 // 当前阶段结束的 Tick，0 表示不限时
+	active bool  // 是否有进行中的对局（未启用对局流程时为 false）
+
+	ready        map[PlayerID]bool
+	participants map[PlayerID]bool // 本局参与者 → 是否已离开
+	seq          int64
+	startTick    int64
+	startedAt    time.Time
+	last         *MatchResult
+}
+
+// phaseMessage 阶段变化通知（玩家与观察者）
+type phaseMessage struct {
+	Type        string     `json:"type"`
+	Phase       Phase      `json:"phase"`
+	Tick        int64      `json:"tick"`
+	Match       int64      `json:"match"`
+	RemainingMs int64      `json:"remainingMs,omitempty"`
+	MinPlayers  int        `json:"minPlayers,omitempty"`
+	Ready       []PlayerID `json:"ready,omitempty"`
+}
+
+// initMatch 房间创建时进入初始阶段
+func (r *Room) initMatch() {
+	r.match.ready = make(map[PlayerID]bool)
+	r.match.participants = make(map[PlayerID]bool)
+	if r.match.cfg.Enabled {
+		r.match.phase = PhaseWaiting
+	} else {
+		r.match.phase = PhasePlaying
+	}
+}
+
+// Phase 当前对局阶段
+func (r *Room) Phase() Phase { return r.match.phase }
+
+// acceptsMoves 当前阶段是否应用移动输入
+func (r *Room) acceptsMoves() bool { return r.match.phase == PhasePlaying }
+
+// setPhase 切换阶段并通知；d > 0 时设置阶段时长
+func (r *Room) setPhase(ph Phase, d time.Duration) {
+	r.match.phase = ph
+	r.match.endsAt = 0
+	if d > 0 {
+		r.match.endsAt = r.tickSeq + int64(r.ticksFor(d))
+	}
+	Log.Infof("match phase: room=%s match=%d phase=%s", r.ID, r.match.seq, ph)
+	r.broadcastPhase()
+}
+
+// advanceMatch 每帧推进对局状态机（Tick 线程，在玩法 OnTick 之前）
+func (r *Room) advanceMatch() {
+	m := &r.match
+	if !m.cfg.Enabled {
+		if m.phase != PhasePlaying || m.active {
+			// 运行期关闭对局流程：放弃进行中的对局，恢复自由模式
+			m.active = false
+			clear(m.ready)
+			r.setPhase(PhasePlaying, 0)
+		}
+		return
+	}
+	if m.phase == PhasePlaying && !m.active {
+		// 运行期开启对局流程
+		r.setPhase(PhaseWaiting, 0)
+		return
+	}
+	n := len(r.Players)
+	expired := m.endsAt > 0 && r.tickSeq >= m.endsAt
+	switch m.phase {
+	case PhaseWaiting:
+		if n >= m.cfg.MinPlayers {
+			if m.cfg.ReadyCheck {
+				r.setPhase(PhaseReady, 0)
+				if r.allReady() {
+					r.startCountdown()
+				}
+			} else {
+				r.startCountdown()
+			}
+		}
+	case PhaseReady:
+		if n < m.cfg.MinPlayers {
+			r.setPhase(PhaseWaiting, 0)
+		} else if r.allReady() {
+			r.startCountdown()
+		}
+	case PhaseCountdown:
+		if n < m.cfg.MinPlayers {
+			Log.Infof("countdown aborted: room=%s players=%d min=%d", r.ID, n, m.cfg.MinPlayers)
+			r.setPhase(PhaseWaiting, 0)
+		} else if expired || m.cfg.CountdownMs == 0 {
+			r.startMatch()
+		}
+	case PhasePlaying:
+		if n == 0 {
+			r.EndMatch(EndAbandoned)
+		} else if expired {
+			r.EndMatch(EndTimeLimit)
+		}
+	case PhaseResults:
+		if expired || m.cfg.ResultsMs == 0 {
+			if m.cfg.AfterResults == AfterResultsClose {
+				r.closeAfterMatch()
+				return
+			}
+			clear(m.ready)
+			r.setPhase(PhaseWaiting, 0)
+		}
+	}
+}
+
+func (r *Room) allReady() bool {
+	for id := range r.Players {
+		if !r.match.ready[id] {
+			return false
+		}
+	}
+	return len(r.Players) > 0
+}
+
+func (r *Room) startCountdown() {
+	r.setPhase(PhaseCountdown, time.Duration(r.match.cfg.CountdownMs)*time.Millisecond)
+}
+
+// startMatch 开局：登记参与者，由玩法重置世界
+func (r *Room) startMatch() {
+	m := &r.match
+	m.seq++
+	m.active = true
+	m.startTick = r.tickSeq
+	m.startedAt = time.Now()
+	clear(m.participants)
+	for id := range r.Players {
+		m.participants[id] = false
+	}
+	r.resetTeamScores()
+	r.resetStats()
+	r.mode.OnMatchStart(r)
+	r.setPhase(PhasePlaying, time.Duration(m.cfg.TimeLimitMs)*time.Millisecond)
+}
+
+// EndMatch 结束进行中的对局并进入结算（玩法判定胜负后调用）；非对局中调用无效
+func (r *Room) EndMatch(reason string) {
+	m := &r.match
+	if !m.active || m.phase != PhasePlaying {
+		return
+	}
+	res := r.finishMatch(reason)
+	r.broadcastControl("match_result", struct {
+		Type string `json:"type"`
+		*MatchResult
+	}{"match_result", res})
+	r.setPhase(PhaseResults, time.Duration(m.cfg.ResultsMs)*time.Millisecond)
+}
+
+// finishMatch 通知玩法结束并生成结算结果
+func (r *Room) finishMatch(reason string) *MatchResult {
+	m := &r.match
+	m.active = false
+	r.endMode()
+	res := &MatchResult{
+		ID:        r.ID + "-" + strconv.FormatInt(m.startedAt.Unix(), 10) + "-" + strconv.FormatInt(m.seq, 10),
+		Room:      r.ID,
+		Match:     m.seq,
+		Mode:      r.mode.Name(),
+		Reason:    reason,
+		StartTick: m.startTick,
+		EndTick:   r.tickSeq,
+		StartedAt: m.startedAt,
+		EndedAt:   time.Now(),
+		Players:   r.standings(),
+		Teams:     r.teamStandings(),
+	}
+	if m.cfg.Rated && reason != EndAbandoned {
+		rateMatch(res)
+	}
+	m.last = res
+	recordMatch(res)
+	recordLifetime(res)
+	Log.Infof("match ended: room=%s match=%d reason=%s players=%d", r.ID, res.Match, reason, len(res.Players))
+	return res
+}
+
+// standings 合并玩法给出的分数与参与者名单（中途离开者同样计入）及本局统计，并计算名次
+func (r *Room) standings() []Standing {
+	out := r.mode.Standings(r)
+	seen := make(map[PlayerID]bool, len(out))
+	for i := range out {
+		seen[out[i].ID] = true
+		out[i].Left = r.match.participants[out[i].ID]
+	}
+	for id, left := range r.match.participants {
+		if !seen[id] {
+			out = append(out, Standing{ID: id, Left: left})
+		}
+	}
+	for i := range out {
+		if out[i].Team == "" {
+			out[i].Team = r.teamOf[out[i].ID]
+		}
+		if p, ok := r.Players[out[i].ID]; ok {
+			out[i].Name = p.Name
+		} else if prof, ok := GetPlayerStore().Get(out[i].ID); ok {
+			out[i].Name = prof.DisplayName
+		}
+		if s, ok := r.stats[out[i].ID]; ok && out[i].Stats == nil {
+			out[i].Stats = s.clone()
+		}
+	}
+	rankStandings(out)
+	return out
+}
+
+// rankStandings 按分数降序排名；玩法已给出名次时保留
+func rankStandings(s []Standing) {
+	sort.SliceStable(s, func(i, j int) bool {
+		if s[i].Rank != s[j].Rank && s[i].Rank > 0 && s[j].Rank > 0 {
+			return s[i].Rank < s[j].Rank
+		}
+		if s[i].Score != s[j].Score {
+			return s[i].Score > s[j].Score
+		}
+		return s[i].ID < s[j].ID
+	})
+	for i := range s {
+		if s[i].Rank > 0 {
+			continue
+		}
+		if i > 0 && s[i].Score == s[i-1].Score {
+			s[i].Rank = s[i-1].Rank
+		} else {
+			s[i].Rank = i + 1
+		}
+	}
+}
+
+// matchJoin 玩家加入：对局中加入者计为参与者
+func (r *Room) matchJoin(id PlayerID) {
+	if r.match.active {
+		r.match.participants[id] = false
+	}
+}
+
+// matchLeave 玩家离开：清除准备状态，对局中记为离开
+func (r *Room) matchLeave(id PlayerID) {
+	if r.match.ready[id] {
+		delete(r.match.ready, id)
+		if r.match.phase == PhaseReady {
+			r.broadcastPhase()
+		}
+	}
+	if _, ok := r.match.participants[id]; ok && r.match.active {
+		r.match.participants[id] = true
+	}
+}
+
+// readyMessage 客户端 ready 消息，Ready 缺省为 true
+type readyMessage struct {
+	Ready *bool `json:"ready"`
+}
+
+func init() {
+	RegisterMessage("ready", JSONHandler(OnTick, func(ctx *MessageContext, m *readyMessage) error {
+		ctx.Room.SetReady(ctx.Player, m.Ready == nil || *m.Ready)
+		return nil
+	}).Limit(5, 5))
+}
+
+// SetReady 玩家确认/取消准备（客户端 ready 消息）；仅在等待与准备阶段有效
+func (r *Room) SetReady(id PlayerID, ready bool) {
+	ph := r.match.phase
+	if ph != PhaseWaiting && ph != PhaseReady {
+		return
+	}
+	if _, ok := r.Players[id]; !ok || r.match.ready[id] == ready {
+		return
+	}
+	if ready {
+		r.match.ready[id] = true
+	} else {
+		delete(r.match.ready, id)
+	}
+	Log.Infof("player ready: room=%s player=%s ready=%v", r.ID, id, ready)
+	r.broadcastPhase()
+}
+
+// phaseMessage 当前阶段的通知消息
+func (r *Room) phaseMessage() phaseMessage {
+	m := &r.match
+	msg := phaseMessage{Type: "phase", Phase: m.phase, Tick: r.tickSeq, Match: m.seq}
+	if m.endsAt > r.tickSeq {
+		msg.RemainingMs = (m.endsAt - r.tickSeq) * r.TickInterval().Milliseconds()
+	}
+	if m.phase == PhaseWaiting || m.phase == PhaseReady {
+		msg.MinPlayers = m.cfg.MinPlayers
+		for id := range m.ready {
+			msg.Ready = append(msg.Ready, id)
+		}
+		sort.Slice(msg.Ready, func(i, j int) bool { return msg.Ready[i] < msg.Ready[j] })
+	}
+	return msg
+}
+
+func (r *Room) broadcastPhase() {
+	if !r.match.cfg.Enabled && r.match.seq == 0 {
+		return // 未启用对局流程：不发送阶段消息
+	}
+	r.broadcastControl("phase", r.phaseMessage())
+}
+
+// sendPhaseTo 向新加入的玩家补发当前阶段
+func (r *Room) sendPhaseTo(p *Player) {
+	if !r.match.cfg.Enabled {
+		return
+	}
+	f := controlFrame(r.phaseMessage())
+	r.send(p, f, false)
+	f.Release()
+}
+
+// broadcastControl 以可靠消息通知全部玩家，并写入事件流
+func (r *Room) broadcastControl(typ string, v any) {
+	f := controlFrame(v)
+	for _, p := range r.Players {
+		r.send(p, f, false)
+	}
+	r.publish(typ, f)
+	f.Release()
+}
+
+// controlFrame 编码低频控制消息（可靠投递）
+func controlFrame(v any) *Frame {
+	b, _ := json.Marshal(v)
+	f := frameFromBytes(b)
+	f.reliable = true
+	return f
+}
+
+// LastMatch 最近一局的结算结果（Tick 线程读取）
+func (r *Room) LastMatch() *MatchResult { return r.match.last }
+
+// closeAfterMatch 结算后关闭房间
+func (r *Room) closeAfterMatch() {
+	Log.Infof("room closed after match: room=%s match=%d", r.ID, r.match.seq)
+	r.notifyClosed("match over")
+	GetRoomManager().removeRoom(r, "")
+	r.Close()
+}
+
+// serveMatch 输出当前阶段与最近一局结算
+func serveMatch(w http.ResponseWriter, room *Room) {
+	var cur phaseMessage
+	var last *MatchResult
+	var enabled bool
+	if !room.Query(func() {
+		cur, last, enabled = room.phaseMessage(), room.match.last, room.match.cfg.Enabled
+	}) {
+		http.Error(w, "room closed", http.StatusGone)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"room":    room.ID,
+		"enabled": enabled,
+		"phase":   cur,
+		"last":    last,
+	})
+}
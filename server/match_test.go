@@ -0,0 +1,142 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// matchRoom 10 TPS、启用对局流程的测试房间（100ms = 1 Tick）
+func matchRoom(t *testing.T, cfg MatchConfig) *Room {
+	t.Helper()
+	cfg.Enabled = true
+	if cfg.AfterResults == "" {
+		cfg.AfterResults = AfterResultsReset
+	}
+	return testRoom(t, func(p *RoomProfile) {
+		p.TicksPerSecond = 10
+		p.Match = cfg
+	})
+}
+
+// tickUntil 推进房间直到进入指定阶段，最多 n 帧
+func tickUntil(t *testing.T, r *Room, ph Phase, n int) int {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		r.runTick(time.Now())
+		if r.Phase() == ph {
+			return i
+		}
+	}
+	t.Fatalf("phase = %s after %d ticks, want %s", r.Phase(), n, ph)
+	return 0
+}
+
+func TestMatchLifecycle(t *testing.T) {
+	r := matchRoom(t, MatchConfig{MinPlayers: 2, ReadyCheck: true, CountdownMs: 300, TimeLimitMs: 500, ResultsMs: 200})
+	ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	if r.Phase() != PhaseWaiting {
+		t.Fatalf("phase with one player = %s, want waiting", r.Phase())
+	}
+	ConnectMem(r, "bob", 256)
+	tickUntil(t, r, PhaseReady, 1)
+
+	r.SetReady("alice", true)
+	r.runTick(time.Now())
+	if r.Phase() != PhaseReady {
+		t.Fatalf("phase with one ready = %s, want ready", r.Phase())
+	}
+	r.SetReady("bob", true)
+	tickUntil(t, r, PhaseCountdown, 1)
+	if n := tickUntil(t, r, PhasePlaying, 5); n != 3 {
+		t.Fatalf("countdown lasted %d ticks, want 3", n)
+	}
+	if !r.match.active || r.match.seq != 1 || len(r.match.participants) != 2 {
+		t.Fatalf("match not started: %+v", r.match)
+	}
+
+	if n := tickUntil(t, r, PhaseResults, 10); n != 5 {
+		t.Fatalf("match lasted %d ticks, want 5", n)
+	}
+	if res := r.match.last; res == nil || res.Reason != EndTimeLimit || len(res.Players) != 2 {
+		t.Fatalf("result = %+v", res)
+	}
+	tickUntil(t, r, PhaseWaiting, 3)
+	if len(r.match.ready) != 0 {
+		t.Fatalf("ready flags survived reset: %v", r.match.ready)
+	}
+	// 下一局需要重新准备
+	tickUntil(t, r, PhaseReady, 1)
+}
+
+func TestMatchCountdownAbortsWhenPlayerLeaves(t *testing.T) {
+	r := matchRoom(t, MatchConfig{MinPlayers: 2, CountdownMs: 1000, ResultsMs: 1000})
+	ConnectMem(r, "alice", 256)
+	bob := ConnectMem(r, "bob", 256)
+	tickUntil(t, r, PhaseCountdown, 2)
+
+	bob.Close()
+	waitLeft(t, r, "bob")
+	if r.Phase() != PhaseWaiting {
+		t.Fatalf("phase after leave = %s, want waiting", r.Phase())
+	}
+	if r.match.seq != 0 {
+		t.Fatal("match started without enough players")
+	}
+}
+
+func TestMatchCountdownKeepsDurationOnTickRateChange(t *testing.T) {
+	r := matchRoom(t, MatchConfig{MinPlayers: 1, CountdownMs: 1000, ResultsMs: 1000})
+	ConnectMem(r, "alice", 256)
+	tickUntil(t, r, PhaseCountdown, 2)
+	r.runTick(time.Now())
+	before := r.phaseMessage().RemainingMs
+
+	// 10 → 20 TPS：剩余帧数加倍，剩余时长不变
+	r.setTickRate(20)
+	if got := r.phaseMessage().RemainingMs; got != before {
+		t.Fatalf("remaining after tick rate change = %dms, want %dms", got, before)
+	}
+	if n := tickUntil(t, r, PhasePlaying, 40); n != int(before/50) {
+		t.Fatalf("countdown finished after %d ticks at 20 TPS, want %d", n, before/50)
+	}
+}
+
+func TestMatchTieSharesRank(t *testing.T) {
+	r := matchRoom(t, MatchConfig{MinPlayers: 2, ResultsMs: 1000})
+	ConnectMem(r, "alice", 256)
+	ConnectMem(r, "bob", 256)
+	ConnectMem(r, "carol", 256)
+	tickUntil(t, r, PhasePlaying, 3)
+
+	r.EndMatch(EndTimeLimit)
+	res := r.match.last
+	if r.Phase() != PhaseResults || res == nil {
+		t.Fatalf("phase = %s result = %+v", r.Phase(), res)
+	}
+	for _, s := range res.Players {
+		if s.Rank != 1 {
+			t.Fatalf("tied standings = %+v, want everyone ranked 1", res.Players)
+		}
+	}
+	// 结算阶段再次结束无效
+	r.EndMatch(EndScoreLimit)
+	if r.match.last != res {
+		t.Fatal("EndMatch outside playing produced a second result")
+	}
+}
+
+func TestMatchAbandonedWhenEveryoneLeaves(t *testing.T) {
+	r := matchRoom(t, MatchConfig{MinPlayers: 1, ResultsMs: 1000})
+	alice := ConnectMem(r, "alice", 256)
+	tickUntil(t, r, PhasePlaying, 3)
+
+	alice.Close()
+	waitLeft(t, r, "alice")
+	if r.Phase() != PhaseResults {
+		tickUntil(t, r, PhaseResults, 2)
+	}
+	if res := r.match.last; res.Reason != EndAbandoned || len(res.Players) != 1 || !res.Players[0].Left {
+		t.Fatalf("result = %+v, want abandoned with alice marked left", res)
+	}
+}
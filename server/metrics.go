@@ -1,7 +1,9 @@
 package server
 
 import (
+    "runtime/metrics"
     "sync/atomic"
+    "time"
 )
 
 // RoomMetrics 记录房间运行期的关键指标（用于监控与调试）
@@ -11,18 +13,68 @@ type RoomMetrics struct {
     RateLimited       int64 // 因同帧限流被拒绝的输入数
     OldSeqIgnored     int64 // 因旧序列被忽略的输入数
     DropsSimulated    int64 // 因模拟丢包被丢弃的输入数
+    OutDropsSimulated int64 // 因模拟丢包被丢弃的出站消息数
+    DupsSimulated     int64 // 模拟重复的消息数（双向）
     ChanFullDiscarded int64 // 因通道满被丢弃的输入数
     TotalTickNs       int64 // Tick 累计耗时（纳秒）
+    Players           int64 // 当前玩家数（Tick 线程写入）
+    Watchers          int64 // 当前只读观察者数（SSE）
+    SnapshotFallbacks int64 // 连接因发送队列溢出降级为仅全量的次数
+    SlowEvicted       int64 // 因慢消费者策略被断开的连接数
+    Faults            int64 // Tick 内 panic 次数
+    Overruns          int64 // 执行耗时超过 Tick 间隔的帧数
+    CatchUpTicks      int64 // 为追赶墙钟而补跑的帧数
+    SkippedTicks      int64 // 因落后过多而放弃的帧数（游戏时间与墙钟的偏差）
+    TickAllocs        int64 // Tick 期间的堆分配对象数（进程级采样，多房间并发时为近似值）
+
+    TickDuration *Histogram // 单 Tick 耗时（秒）
+    TickLateness *Histogram // Tick 实际开始相对计划时间的延迟（秒）
+    PayloadBytes *Histogram // 广播负载大小（字节）
+    // 分阶段耗时（秒）：输入处理 / 世界更新 / 广播
+    PhaseInputs    *Histogram
+    PhaseUpdate    *Histogram
+    PhaseBroadcast *Histogram
+}
+
+// NewRoomMetrics 创建带直方图的房间指标
+func NewRoomMetrics() *RoomMetrics {
+    return &RoomMetrics{
+        TickDuration: NewHistogram(durationBuckets...),
+        TickLateness: NewHistogram(durationBuckets...),
+        PayloadBytes: NewHistogram(bytesBuckets...),
+        PhaseInputs:    NewHistogram(durationBuckets...),
+        PhaseUpdate:    NewHistogram(durationBuckets...),
+        PhaseBroadcast: NewHistogram(durationBuckets...),
+    }
 }
 
 func (m *RoomMetrics) IncAccepted() { atomic.AddInt64(&m.InputsAccepted, 1) }
 func (m *RoomMetrics) IncRateLimited() { atomic.AddInt64(&m.RateLimited, 1) }
 func (m *RoomMetrics) IncOldSeqIgnored() { atomic.AddInt64(&m.OldSeqIgnored, 1) }
 func (m *RoomMetrics) IncDropsSimulated() { atomic.AddInt64(&m.DropsSimulated, 1) }
+func (m *RoomMetrics) IncOutboundDropsSimulated() { atomic.AddInt64(&m.OutDropsSimulated, 1) }
+func (m *RoomMetrics) IncDuplicatesSimulated() { atomic.AddInt64(&m.DupsSimulated, 1) }
 func (m *RoomMetrics) IncChanFullDiscarded() { atomic.AddInt64(&m.ChanFullDiscarded, 1) }
+func (m *RoomMetrics) IncSnapshotFallback() { atomic.AddInt64(&m.SnapshotFallbacks, 1) }
+func (m *RoomMetrics) IncSlowConsumerEvicted() { atomic.AddInt64(&m.SlowEvicted, 1) }
+func (m *RoomMetrics) IncFault() { atomic.AddInt64(&m.Faults, 1) }
+func (m *RoomMetrics) IncOverrun() { atomic.AddInt64(&m.Overruns, 1) }
+func (m *RoomMetrics) AddCatchUp(n int) { atomic.AddInt64(&m.CatchUpTicks, int64(n)) }
+func (m *RoomMetrics) AddSkipped(n int) { atomic.AddInt64(&m.SkippedTicks, int64(n)) }
+func (m *RoomMetrics) ObservePhases(inputs, update, broadcast time.Duration) {
+    m.PhaseInputs.Observe(inputs.Seconds())
+    m.PhaseUpdate.Observe(update.Seconds())
+    m.PhaseBroadcast.Observe(broadcast.Seconds())
+}
+func (m *RoomMetrics) AddTickAllocs(n uint64) { atomic.AddInt64(&m.TickAllocs, int64(n)) }
+func (m *RoomMetrics) SetPlayers(n int) { atomic.StoreInt64(&m.Players, int64(n)) }
+func (m *RoomMetrics) SetWatchers(n int) { atomic.StoreInt64(&m.Watchers, int64(n)) }
+func (m *RoomMetrics) ObservePayload(n int) { m.PayloadBytes.Observe(float64(n)) }
+func (m *RoomMetrics) ObserveLateness(ns int64) { m.TickLateness.Observe(float64(ns) / 1e9) }
 func (m *RoomMetrics) AddTick(ns int64) {
     atomic.AddInt64(&m.TickCount, 1)
     atomic.AddInt64(&m.TotalTickNs, ns)
+    m.TickDuration.Observe(float64(ns) / 1e9)
 }
 
 // Snapshot 返回只读副本，便于 HTTP 输出
@@ -39,8 +91,35 @@ func (m *RoomMetrics) Snapshot() map[string]any {
         "rate_limited":        atomic.LoadInt64(&m.RateLimited),
         "old_seq_ignored":     atomic.LoadInt64(&m.OldSeqIgnored),
         "drops_simulated":     atomic.LoadInt64(&m.DropsSimulated),
+        "out_drops_simulated": atomic.LoadInt64(&m.OutDropsSimulated),
+        "dups_simulated":      atomic.LoadInt64(&m.DupsSimulated),
         "chan_full_discarded": atomic.LoadInt64(&m.ChanFullDiscarded),
         "avg_tick_ms":         avgMs,
+        "players":             atomic.LoadInt64(&m.Players),
+        "watchers":            atomic.LoadInt64(&m.Watchers),
+        "snapshot_fallbacks":  atomic.LoadInt64(&m.SnapshotFallbacks),
+        "slow_evicted":        atomic.LoadInt64(&m.SlowEvicted),
+        "faults":              atomic.LoadInt64(&m.Faults),
+        "overruns":            atomic.LoadInt64(&m.Overruns),
+        "catch_up_ticks":      atomic.LoadInt64(&m.CatchUpTicks),
+        "skipped_ticks":       atomic.LoadInt64(&m.SkippedTicks),
+        "tick_allocs":         atomic.LoadInt64(&m.TickAllocs),
     }
 }
 
+
+// allocSampler 读取进程累计堆分配对象数（runtime/metrics，无 STW，复用样本避免分配）
+type allocSampler struct {
+    samples [1]metrics.Sample
+}
+
+func (a *allocSampler) read() uint64 {
+    if a.samples[0].Name == "" {
+        a.samples[0].Name = "/gc/heap/allocs:objects"
+    }
+    metrics.Read(a.samples[:])
+    if a.samples[0].Value.Kind() != metrics.KindUint64 {
+        return 0
+    }
+    return a.samples[0].Value.Uint64()
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// HandleMetrics 以 Prometheus 文本格式输出全部房间与连接指标
+// GET /metrics
+func HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	rooms := GetRoomManager().Rooms()
+
+	// 按房间汇总连接数与发送队列深度
+	type connInfo struct {
+		transport, room string
+		player          PlayerID
+		s               monitored
+	}
+	var conns []connInfo
+	queueDepth := make(map[string]int, len(rooms))
+	activeSessions.Range(func(k, _ any) bool {
+		s := k.(monitored)
+		t, room, pid := s.labels()
+		conns = append(conns, connInfo{t, room, pid, s})
+		queueDepth[room] += s.QueueDepth()
+		return true
+	})
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].room != conns[j].room {
+			return conns[i].room < conns[j].room
+		}
+		return conns[i].player < conns[j].player
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p := &promWriter{w: w}
+
+	p.family("miniarena_rooms", "gauge", "Number of rooms.")
+	p.sample("miniarena_rooms", float64(len(rooms)))
+	p.family("miniarena_connections", "gauge", "Number of open client connections.")
+	p.sample("miniarena_connections", float64(len(conns)))
+
+	if pool := getTickPool(); pool != nil {
+		p.family("miniarena_scheduler_workers", "gauge", "Worker goroutines in the tick pool.")
+		p.sample("miniarena_scheduler_workers", float64(pool.workers))
+		p.family("miniarena_scheduler_rooms", "gauge", "Rooms scheduled on the tick pool.")
+		p.sample("miniarena_scheduler_rooms", float64(atomic.LoadInt64(&pool.rooms)))
+		p.family("miniarena_scheduler_lag_seconds", "histogram", "Delay between a room tick's due time and a worker picking it up.")
+		p.histogram("miniarena_scheduler_lag_seconds", pool.Lag)
+	}
+
+	gauges := []struct {
+		name, help string
+		value      func(*Room) float64
+	}{
+		{"miniarena_room_players", "Players currently in the room.", func(rm *Room) float64 { return float64(atomic.LoadInt64(&rm.metrics.Players)) }},
+		{"miniarena_room_watchers", "Read-only event stream subscribers.", func(rm *Room) float64 { return float64(atomic.LoadInt64(&rm.metrics.Watchers)) }},
+		{"miniarena_room_tick_rate", "Configured ticks per second.", func(rm *Room) float64 { return float64(rm.TickRate()) }},
+		{"miniarena_room_stalled", "1 if the watchdog considers the room's tick loop stalled.", func(rm *Room) float64 {
+			if rm.health.stalled.Load() {
+				return 1
+			}
+			return 0
+		}},
+		{"miniarena_room_send_queue_depth", "Messages waiting in the room's client send queues.", func(rm *Room) float64 { return float64(queueDepth[rm.ID]) }},
+	}
+	for _, g := range gauges {
+		p.family(g.name, "gauge", g.help)
+		for _, rm := range rooms {
+			p.sample(g.name, g.value(rm), "room", rm.ID)
+		}
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(*RoomMetrics) float64
+	}{
+		{"miniarena_room_ticks_total", "Ticks executed.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.TickCount)) }},
+		{"miniarena_room_tick_seconds_total", "Total time spent in ticks.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.TotalTickNs)) / 1e9 }},
+		{"miniarena_room_inputs_accepted_total", "Inputs applied to the world.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.InputsAccepted)) }},
+		{"miniarena_room_inputs_rate_limited_total", "Inputs rejected by the per-tick limit.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.RateLimited)) }},
+		{"miniarena_room_inputs_old_seq_ignored_total", "Inputs ignored because of an old sequence number.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.OldSeqIgnored)) }},
+		{"miniarena_room_inputs_dropped_simulated_total", "Inputs dropped by network simulation.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.DropsSimulated)) }},
+		{"miniarena_room_outbound_dropped_simulated_total", "Outbound messages dropped by network simulation.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.OutDropsSimulated)) }},
+		{"miniarena_room_duplicated_simulated_total", "Messages duplicated by network simulation (both directions).", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.DupsSimulated)) }},
+		{"miniarena_room_inputs_chan_full_discarded_total", "Inputs discarded because the input queue was full.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.ChanFullDiscarded)) }},
+		{"miniarena_room_tick_panics_total", "Panics recovered inside the tick loop.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.Faults)) }},
+		{"miniarena_room_tick_overruns_total", "Ticks whose execution took longer than the tick interval.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.Overruns)) }},
+		{"miniarena_room_catch_up_ticks_total", "Extra ticks run to catch up with wall time.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.CatchUpTicks)) }},
+		{"miniarena_room_skipped_ticks_total", "Ticks dropped because the room fell too far behind wall time.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.SkippedTicks)) }},
+		{"miniarena_room_tick_heap_allocs_total", "Heap objects allocated during ticks (process-wide sample, approximate when rooms tick concurrently).", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.TickAllocs)) }},
+		{"miniarena_room_snapshot_fallbacks_total", "Connections degraded to full snapshots after send queue overflow.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.SnapshotFallbacks)) }},
+		{"miniarena_room_slow_consumer_evictions_total", "Connections closed by the slow-consumer policy.", func(m *RoomMetrics) float64 { return float64(atomic.LoadInt64(&m.SlowEvicted)) }},
+	}
+	for _, c := range counters {
+		p.family(c.name, "counter", c.help)
+		for _, rm := range rooms {
+			p.sample(c.name, c.value(rm.metrics), "room", rm.ID)
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		value      func(*RoomMetrics) *Histogram
+	}{
+		{"miniarena_room_tick_duration_seconds", "Tick execution time.", func(m *RoomMetrics) *Histogram { return m.TickDuration }},
+		{"miniarena_room_tick_lateness_seconds", "Delay between a tick's scheduled and actual start.", func(m *RoomMetrics) *Histogram { return m.TickLateness }},
+		{"miniarena_room_broadcast_payload_bytes", "Size of broadcast payloads.", func(m *RoomMetrics) *Histogram { return m.PayloadBytes }},
+	}
+	for _, h := range histograms {
+		p.family(h.name, "histogram", h.help)
+		for _, rm := range rooms {
+			p.histogram(h.name, h.value(rm.metrics), "room", rm.ID)
+		}
+	}
+	const phaseName = "miniarena_room_tick_phase_seconds"
+	p.family(phaseName, "histogram", "Time spent in each tick phase.")
+	for _, rm := range rooms {
+		p.histogram(phaseName, rm.metrics.PhaseInputs, "room", rm.ID, "phase", "inputs")
+		p.histogram(phaseName, rm.metrics.PhaseUpdate, "room", rm.ID, "phase", "update")
+		p.histogram(phaseName, rm.metrics.PhaseBroadcast, "room", rm.ID, "phase", "broadcast")
+	}
+
+	connMetrics := []struct {
+		name, typ, help string
+		value           func(monitored) float64
+	}{
+		{"miniarena_conn_bytes_sent_total", "counter", "Bytes written to the client.", func(s monitored) float64 { return float64(atomic.LoadInt64(&s.stats().BytesSent)) }},
+		{"miniarena_conn_messages_sent_total", "counter", "Messages written to the client.", func(s monitored) float64 { return float64(atomic.LoadInt64(&s.stats().MessagesSent)) }},
+		{"miniarena_conn_dropped_total", "counter", "Messages dropped because the send queue was full.", func(s monitored) float64 { return float64(atomic.LoadInt64(&s.stats().Dropped)) }},
+		{"miniarena_conn_send_queue_depth", "gauge", "Messages waiting in the client's send queue.", func(s monitored) float64 { return float64(s.QueueDepth()) }},
+	}
+	for _, cm := range connMetrics {
+		p.family(cm.name, cm.typ, cm.help)
+		for _, c := range conns {
+			p.sample(cm.name, cm.value(c.s), "room", c.room, "player", string(c.player), "transport", c.transport)
+		}
+	}
+
+	if p.err != nil {
+		Log.Debugf("metrics write: %v", p.err)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// GameMode 房间玩法规则
+//
+// 房间负责连接、输入去重与限流、广播与故障恢复，玩法只决定“世界如何变化”。
+// 所有钩子都在房间的 Tick 线程中调用，玩法内部无需加锁；每个房间持有独立的玩法实例
+type GameMode interface {
+	// Name 玩法名称（与注册名一致）
+	Name() string
+	// OnRoomStart 房间创建后、开始 Tick 前调用一次
+	OnRoomStart(r *Room)
+	// OnJoin 玩家加入（含重连）时调用，负责设置初始位置；last 为该玩家离开前的最近状态（首次加入为 nil）
+	OnJoin(r *Room, p *Player, last *PlayerState)
+	// OnLeave 玩家离开时调用（仍在 Players 中）
+	OnLeave(r *Room, p *Player)
+	// OnInput 应用一条已通过去重与限流的输入
+	OnInput(r *Room, p *Player, in Input)
+	// OnTick 对局进行中每帧处理完输入后调用，推进计时器、碰撞等持续状态
+	OnTick(r *Room)
+	// OnMatchStart 进入 playing 阶段时调用，重置世界与分数
+	OnMatchStart(r *Room)
+	// OnEnd 对局结束（进入结算）或房间在对局中关闭时调用
+	OnEnd(r *Room)
+	// Standings 当前各玩家分数，用于结算；Rank 为 0 时由房间按分数排名
+	Standings(r *Room) []Standing
+	// BuildPlayerView 编码一个玩家在 state / delta 中的 JSON 对象（追加到 b）
+	BuildPlayerView(r *Room, p *Player, b []byte) []byte
+	// BuildWorldView 向 state / delta 追加玩法自己的世界对象（以 ,"key":value 形式）；
+	// full 为 false 时是增量，仅在有变化时输出
+	BuildWorldView(r *Room, b []byte, full bool) []byte
+	// SnapshotState 复制玩法的可变状态，随房间快照保存用于故障恢复；返回值不得与玩法共享可变数据
+	SnapshotState(r *Room) any
+	// RestoreState Tick panic 后从 SnapshotState 的返回值恢复；同一份快照可能被多次恢复，不得修改它
+	RestoreState(r *Room, s any)
+}
+
+// ModeFactory 按房间配置创建玩法实例，玩法参数位于 p.ModeConfig；配置非法时返回错误（配置校验阶段即会调用）
+type ModeFactory func(p RoomProfile) (GameMode, error)
+
+// DefaultMode 默认玩法：自由移动
+const DefaultMode = "free-move"
+
+var (
+	modesMu sync.RWMutex
+	modes   = map[string]ModeFactory{}
+)
+
+func init() {
+	RegisterMode(DefaultMode, func(RoomProfile) (GameMode, error) { return FreeMove{}, nil })
+}
+
+// RegisterMode 注册玩法，通常在 init 中调用；重名时 panic
+func RegisterMode(name string, f ModeFactory) {
+	modesMu.Lock()
+	defer modesMu.Unlock()
+	if _, dup := modes[name]; dup {
+		panic("server: duplicate game mode " + name)
+	}
+	modes[name] = f
+}
+
+// ModeNames 已注册的玩法名称（排序）
+func ModeNames() []string {
+	modesMu.RLock()
+	defer modesMu.RUnlock()
+	out := make([]string, 0, len(modes))
+	for name := range modes {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// newMode 按房间配置创建玩法实例（空名称为默认玩法）
+func newMode(p RoomProfile) (GameMode, error) {
+	name := p.Mode
+	if name == "" {
+		name = DefaultMode
+	}
+	modesMu.RLock()
+	f, ok := modes[name]
+	modesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown mode: %q", name)
+	}
+	m, err := f(p)
+	if err != nil {
+		return nil, fmt.Errorf("mode %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// FreeMove 自由移动：每条输入移动一步，越界裁剪；无胜负与计时
+// 其他玩法可嵌入 FreeMove，只覆盖需要的钩子
+type FreeMove struct{}
+
+func (FreeMove) Name() string           { return DefaultMode }
+func (FreeMove) OnRoomStart(*Room)      {}
+func (FreeMove) OnLeave(*Room, *Player) {}
+func (FreeMove) OnTick(*Room)           {}
+func (FreeMove) OnEnd(*Room)            {}
+
+// SnapshotState 自由移动除玩家位置外无状态
+func (FreeMove) SnapshotState(*Room) any { return nil }
+func (FreeMove) RestoreState(*Room, any) {}
+
+// OnMatchStart 全部玩家回到出生点
+func (FreeMove) OnMatchStart(r *Room) {
+	for _, p := range r.Players {
+		p.X, p.Y = r.SpawnPoint(p)
+	}
+}
+
+// Standings 自由移动无计分，全部玩家并列
+func (FreeMove) Standings(r *Room) []Standing {
+	out := make([]Standing, 0, len(r.Players))
+	for id := range r.Players {
+		out = append(out, Standing{ID: id})
+	}
+	return out
+}
+
+// OnJoin 重连时恢复最近位置，否则放在出生点
+func (FreeMove) OnJoin(r *Room, p *Player, last *PlayerState) {
+	if last != nil {
+		p.X, p.Y = last.X, last.Y
+		return
+	}
+	p.X, p.Y = r.SpawnPoint(p)
+}
+
+func (FreeMove) OnInput(r *Room, p *Player, in Input) {
+	r.MovePlayer(p, in.Command) // 每个输入仅移动一步
+}
+
+func (FreeMove) BuildPlayerView(_ *Room, p *Player, b []byte) []byte {
+	return appendPlayerState(b, p)
+}
+
+func (FreeMove) BuildWorldView(_ *Room, b []byte, _ bool) []byte { return b }
+
+// 以下为供玩法使用的房间只读访问与操作（均需在 Tick 线程中调用）
+
+// Tick 当前服务器 Tick 序号
+func (r *Room) Tick() int64 { return r.tickSeq }
+
+// Bounds 世界尺寸
+func (r *Room) Bounds() (width, height float64) { return r.width, r.height }
+
+// Spawn 配置的出生点
+func (r *Room) Spawn() (x, y float64) { return r.spawnX, r.spawnY }
+
+// MovePlayer 按房间步长移动一步并裁剪到世界边界
+func (r *Room) MovePlayer(p *Player, dir Direction) { r.applyMove(p, dir) }
+
+// Mode 房间当前玩法
+func (r *Room) Mode() GameMode { return r.mode }
+
+// BroadcastEvent 以可靠消息通知全部玩家并写入事件流（v 需包含 "type" 字段）
+func (r *Room) BroadcastEvent(typ string, v any) { r.broadcastControl(typ, v) }
+
+// endMode 对局或房间结束时通知玩法；玩法自身出错不影响结束流程
+func (r *Room) endMode() {
+	defer func() {
+		if v := recover(); v != nil {
+			Log.Errorf("mode end panic: room=%s mode=%s err=%v", r.ID, r.mode.Name(), v)
+		}
+	}()
+	r.mode.OnEnd(r)
+}
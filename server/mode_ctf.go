@@ -0,0 +1,334 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ModeCTF 夺旗玩法名称
+const ModeCTF = "ctf"
+
+// EndCaptureLimit 夺旗达到目标分数
+const EndCaptureLimit = "capture_limit"
+
+func init() {
+	RegisterMode(ModeCTF, newCaptureTheFlag)
+}
+
+// CTFConfig 夺旗参数（房间 modeConfig）
+type CTFConfig struct {
+	// Bases 队伍 → 旗座位置；未配置的队伍取其出生区域中心
+	Bases        map[string]CTFBase `json:"bases"`
+	PickupRadius float64            `json:"pickupRadius"` // 接触判定距离，默认 2
+	ReturnMs     int                `json:"returnMs"`     // 掉落的旗帜无人触碰时自动归位，默认 10000
+	CaptureLimit int                `json:"captureLimit"` // 先达到该分数的队伍获胜，默认 3；0 表示不限
+}
+
+// CTFBase 旗座位置
+type CTFBase struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// 旗帜状态
+const (
+	flagAtBase  = "base"
+	flagCarried = "carried"
+	flagDropped = "dropped"
+)
+
+// ctfFlag 一面队伍旗帜（世界实体）
+type ctfFlag struct {
+	team     string
+	base     CTFBase
+	x, y     float64
+	carrier  PlayerID // 携带者，为空表示未被携带
+	dropped  bool
+	returnAt int64 // 掉落后自动归位的 Tick
+}
+
+func (f *ctfFlag) state() string {
+	switch {
+	case f.carrier != "":
+		return flagCarried
+	case f.dropped:
+		return flagDropped
+	default:
+		return flagAtBase
+	}
+}
+
+// flagEvent 旗帜事件（公开广播）
+type flagEvent struct {
+	Type   string   `json:"type"`
+	Event  string   `json:"event"` // taken | dropped | returned | captured
+	Team   string   `json:"team"`  // 旗帜所属队伍
+	Player PlayerID `json:"player,omitempty"`
+	Tick   int64    `json:"tick"`
+}
+
+// CaptureTheFlag 夺旗：触碰敌方旗帜即拾取，携带回到己方旗座（己方旗帜须在座）得 1 分；
+// 携带者离开或换队时旗帜掉落，己方玩家触碰掉落的旗帜立即归位，否则超时自动归位
+type CaptureTheFlag struct {
+	FreeMove
+	cfg      CTFConfig
+	flags    []*ctfFlag
+	captures map[PlayerID]int
+	dirty    bool // 旗帜有变化，下一次增量携带
+}
+
+func newCaptureTheFlag(p RoomProfile) (GameMode, error) {
+	cfg := CTFConfig{PickupRadius: 2, ReturnMs: 10000, CaptureLimit: 3}
+	if len(p.ModeConfig) > 0 {
+		if err := json.Unmarshal(p.ModeConfig, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.Teams) < 2 {
+		return nil, fmt.Errorf("requires at least 2 teams")
+	}
+	if cfg.PickupRadius <= 0 || cfg.ReturnMs < 0 || cfg.CaptureLimit < 0 {
+		return nil, fmt.Errorf("pickupRadius must be positive, returnMs and captureLimit non-negative")
+	}
+	m := &CaptureTheFlag{cfg: cfg, captures: make(map[PlayerID]int)}
+	for _, t := range p.Teams {
+		base, ok := cfg.Bases[t.ID]
+		if !ok {
+			if t.Spawn == nil {
+				return nil, fmt.Errorf("team %s: no base and no spawn area", t.ID)
+			}
+			base = CTFBase{X: t.Spawn.X + t.Spawn.W/2, Y: t.Spawn.Y + t.Spawn.H/2}
+		}
+		if base.X < 0 || base.Y < 0 || base.X > p.Width || base.Y > p.Height {
+			return nil, fmt.Errorf("team %s: base outside world bounds", t.ID)
+		}
+		m.flags = append(m.flags, &ctfFlag{team: t.ID, base: base, x: base.X, y: base.Y})
+	}
+	for id := range cfg.Bases {
+		if m.flag(id) == nil {
+			return nil, fmt.Errorf("base for unknown team %q", id)
+		}
+	}
+	return m, nil
+}
+
+func (m *CaptureTheFlag) Name() string { return ModeCTF }
+
+func (m *CaptureTheFlag) OnRoomStart(*Room) { m.dirty = true }
+
+// OnMatchStart 玩家回到出生点，旗帜归位，个人得分清零
+func (m *CaptureTheFlag) OnMatchStart(r *Room) {
+	m.FreeMove.OnMatchStart(r)
+	m.resetRound()
+}
+
+func (m *CaptureTheFlag) resetRound() {
+	for _, f := range m.flags {
+		m.returnFlag(f)
+	}
+	clear(m.captures)
+}
+
+// OnLeave 携带者离开时旗帜掉落在其位置
+func (m *CaptureTheFlag) OnLeave(r *Room, p *Player) {
+	for _, f := range m.flags {
+		if f.carrier == p.ID {
+			m.drop(r, f, p.X, p.Y)
+		}
+	}
+}
+
+func (m *CaptureTheFlag) OnTick(r *Room) {
+	// 携带中的旗帜跟随携带者；携带者已换到旗帜所属队伍时掉落
+	for _, f := range m.flags {
+		switch {
+		case f.carrier != "":
+			p, ok := r.Players[f.carrier]
+			if !ok || p.Team == f.team {
+				m.drop(r, f, f.x, f.y)
+				continue
+			}
+			if f.x != p.X || f.y != p.Y {
+				f.x, f.y = p.X, p.Y
+				m.dirty = true
+			}
+		case f.dropped && r.Tick() >= f.returnAt:
+			m.returnFlag(f)
+			m.announce(r, "returned", f, "")
+		}
+	}
+	for _, p := range r.Players {
+		if p.Team == "" {
+			continue
+		}
+		for _, f := range m.flags {
+			if f.carrier != "" || !m.near(p, f.x, f.y) {
+				continue
+			}
+			if f.team == p.Team {
+				if f.dropped {
+					m.returnFlag(f)
+					m.announce(r, "returned", f, p.ID)
+					r.AddStat(p.ID, "returns", 1)
+				}
+			} else if m.carrying(p.ID) == nil {
+				f.carrier, f.dropped = p.ID, false
+				f.x, f.y = p.X, p.Y
+				m.dirty = true
+				m.announce(r, "taken", f, p.ID)
+				r.AddStat(p.ID, "pickups", 1)
+				r.BroadcastTeam(f.team, flagEvent{Type: "flag_alert", Event: "taken", Team: f.team, Player: p.ID, Tick: r.Tick()})
+			}
+		}
+		if m.tryCapture(r, p) {
+			return
+		}
+	}
+}
+
+// tryCapture 携带敌旗回到己方旗座（己方旗帜在座）时得分；达到目标分数时结束对局，返回是否已结束本轮
+func (m *CaptureTheFlag) tryCapture(r *Room, p *Player) bool {
+	enemy := m.carrying(p.ID)
+	own := m.flag(p.Team)
+	if enemy == nil || own == nil || own.state() != flagAtBase || !m.near(p, own.base.X, own.base.Y) {
+		return false
+	}
+	m.returnFlag(enemy)
+	m.captures[p.ID]++
+	r.AddStat(p.ID, "captures", 1)
+	r.AddTeamScore(p.Team, 1)
+	m.announce(r, "captured", enemy, p.ID)
+	Log.Infof("flag captured: room=%s player=%s team=%s flag=%s", r.ID, p.ID, p.Team, enemy.team)
+
+	if t := r.TeamByID(p.Team); m.cfg.CaptureLimit > 0 && t != nil && t.Score >= m.cfg.CaptureLimit {
+		r.EndMatch(EndCaptureLimit)
+		if r.Phase() == PhasePlaying {
+			// 未启用对局流程：直接开始新一轮
+			r.resetTeamScores()
+			m.resetRound()
+		}
+		return true
+	}
+	return false
+}
+
+// ctfSnapshot 故障恢复用的旗帜与个人得分副本
+type ctfSnapshot struct {
+	flags    []ctfFlag
+	captures map[PlayerID]int
+}
+
+func (m *CaptureTheFlag) SnapshotState(*Room) any {
+	s := ctfSnapshot{flags: make([]ctfFlag, len(m.flags)), captures: maps.Clone(m.captures)}
+	for i, f := range m.flags {
+		s.flags[i] = *f
+	}
+	return s
+}
+
+// RestoreState 恢复旗帜与个人得分；携带者已离开的旗帜在下一次 OnTick 掉落
+func (m *CaptureTheFlag) RestoreState(_ *Room, v any) {
+	s, ok := v.(ctfSnapshot)
+	if !ok {
+		return
+	}
+	for i := range m.flags {
+		*m.flags[i] = s.flags[i]
+	}
+	m.captures = maps.Clone(s.captures)
+	m.dirty = true
+}
+
+func (m *CaptureTheFlag) drop(r *Room, f *ctfFlag, x, y float64) {
+	carrier := f.carrier
+	f.carrier, f.dropped = "", true
+	f.x, f.y = x, y
+	f.returnAt = r.Tick() + int64(r.ticksFor(time.Duration(m.cfg.ReturnMs)*time.Millisecond))
+	m.dirty = true
+	m.announce(r, "dropped", f, carrier)
+}
+
+func (m *CaptureTheFlag) returnFlag(f *ctfFlag) {
+	f.carrier, f.dropped = "", false
+	f.x, f.y = f.base.X, f.base.Y
+	m.dirty = true
+}
+
+func (m *CaptureTheFlag) announce(r *Room, event string, f *ctfFlag, by PlayerID) {
+	r.BroadcastEvent("flag", flagEvent{Type: "flag", Event: event, Team: f.team, Player: by, Tick: r.Tick()})
+}
+
+func (m *CaptureTheFlag) flag(team string) *ctfFlag {
+	for _, f := range m.flags {
+		if f.team == team {
+			return f
+		}
+	}
+	return nil
+}
+
+func (m *CaptureTheFlag) carrying(id PlayerID) *ctfFlag {
+	for _, f := range m.flags {
+		if f.carrier == id {
+			return f
+		}
+	}
+	return nil
+}
+
+func (m *CaptureTheFlag) near(p *Player, x, y float64) bool {
+	return math.Hypot(p.X-x, p.Y-y) <= m.cfg.PickupRadius
+}
+
+// Standings 个人得分为夺旗次数
+func (m *CaptureTheFlag) Standings(r *Room) []Standing {
+	out := make([]Standing, 0, len(r.Players))
+	for id := range r.Players {
+		out = append(out, Standing{ID: id, Score: m.captures[id]})
+	}
+	for id, n := range m.captures {
+		if _, ok := r.Players[id]; !ok {
+			out = append(out, Standing{ID: id, Score: n})
+		}
+	}
+	return out
+}
+
+// BuildWorldView 编码 ,"flags":[{"team":..,"x":..,"y":..,"state":..,"carrier":..}]
+func (m *CaptureTheFlag) BuildWorldView(r *Room, b []byte, full bool) []byte {
+	if !full {
+		if !m.dirty {
+			return b
+		}
+		m.dirty = false
+	}
+	b = append(b, `,"flags":[`...)
+	for i, f := range m.flags {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"team":`...)
+		b = appendJSONString(b, f.team)
+		b = append(b, `,"x":`...)
+		b = appendJSONFloat(b, f.x)
+		b = append(b, `,"y":`...)
+		b = appendJSONFloat(b, f.y)
+		b = append(b, `,"state":"`...)
+		b = append(b, f.state()...)
+		b = append(b, '"')
+		if f.carrier != "" {
+			b = append(b, `,"carrier":`...)
+			b = appendJSONString(b, string(f.carrier))
+		}
+		if f.dropped && f.returnAt > r.Tick() {
+			b = append(b, `,"returnMs":`...)
+			b = strconv.AppendInt(b, (f.returnAt-r.Tick())*r.TickInterval().Milliseconds(), 10)
+		}
+		b = append(b, '}')
+	}
+	return append(b, ']')
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCTFCaptureScoresAndReturnsFlag(t *testing.T) {
+	r := ctfRoom(t, nil)
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "blue")
+	r.runTick(time.Now())
+	ctf := r.mode.(*CaptureTheFlag)
+
+	moveTo(r, "alice", 90, 50)
+	if f := ctf.flag("blue"); f.state() != flagCarried || f.carrier != "alice" {
+		t.Fatalf("blue flag = %s carrier=%q, want carried by alice", f.state(), f.carrier)
+	}
+	// 携带中的旗帜跟随携带者
+	moveTo(r, "alice", 50, 50)
+	if f := ctf.flag("blue"); f.x != 50 || f.y != 50 {
+		t.Fatalf("carried flag at (%v,%v), want (50,50)", f.x, f.y)
+	}
+
+	moveTo(r, "alice", 10, 50)
+	if got := r.TeamByID("red").Score; got != 1 {
+		t.Fatalf("red score = %d, want 1", got)
+	}
+	if f := ctf.flag("blue"); f.state() != flagAtBase {
+		t.Fatalf("blue flag after capture = %s, want base", f.state())
+	}
+	if ctf.captures["alice"] != 1 {
+		t.Fatalf("alice captures = %d, want 1", ctf.captures["alice"])
+	}
+}
+
+func TestCTFNoCaptureWhileOwnFlagAway(t *testing.T) {
+	r := ctfRoom(t, nil)
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "blue")
+	r.runTick(time.Now())
+	ctf := r.mode.(*CaptureTheFlag)
+
+	moveTo(r, "bob", 10, 50)   // bob 拿走红旗
+	moveTo(r, "alice", 90, 50) // alice 拿走蓝旗
+	r.Players["bob"].X, r.Players["bob"].Y = 50, 90
+	moveTo(r, "alice", 10, 50)
+	if got := r.TeamByID("red").Score; got != 0 {
+		t.Fatalf("red scored with its own flag away: %d", got)
+	}
+	if f := ctf.flag("blue"); f.carrier != "alice" {
+		t.Fatalf("blue flag carrier = %q, want alice", f.carrier)
+	}
+}
+
+func TestCTFDroppedFlagReturns(t *testing.T) {
+	r := ctfRoom(t, func(p *RoomProfile) { p.TicksPerSecond = 10 })
+	joinTeam(r, "alice", "red")
+	bob := joinTeam(r, "bob", "blue")
+	carol := joinTeam(r, "carol", "blue")
+	r.runTick(time.Now())
+	ctf := r.mode.(*CaptureTheFlag)
+
+	// 携带者离开：旗帜掉落在其位置
+	moveTo(r, "bob", 10, 50)
+	moveTo(r, "bob", 30, 50)
+	bob.Close()
+	waitLeft(t, r, "bob")
+	f := ctf.flag("red")
+	if f.state() != flagDropped || f.x != 30 {
+		t.Fatalf("red flag = %s at x=%v, want dropped at 30", f.state(), f.x)
+	}
+
+	// 己方玩家触碰掉落的旗帜立即归位
+	moveTo(r, "alice", 30, 50)
+	if f.state() != flagAtBase {
+		t.Fatalf("red flag after touch = %s, want base", f.state())
+	}
+	if r.stats["alice"].Mode["returns"] != 1 {
+		t.Fatalf("alice returns = %d, want 1", r.stats["alice"].Mode["returns"])
+	}
+
+	// 无人触碰时超时自动归位
+	r.Players["alice"].X, r.Players["alice"].Y = 50, 10
+	moveTo(r, "carol", 10, 50)
+	carol.Close()
+	waitLeft(t, r, "carol")
+	if f.state() != flagDropped {
+		t.Fatalf("red flag = %s, want dropped", f.state())
+	}
+	for r.Tick() < f.returnAt {
+		r.runTick(time.Now())
+	}
+	if f.state() != flagAtBase {
+		t.Fatalf("red flag after returnMs = %s, want base", f.state())
+	}
+}
+
+func TestCTFCaptureLimitEndsMatch(t *testing.T) {
+	r := ctfRoom(t, func(p *RoomProfile) {
+		p.Match = MatchConfig{Enabled: true, MinPlayers: 2, ResultsMs: 60000}
+	})
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "blue")
+	for i := 0; i < 3 && r.Phase() != PhasePlaying; i++ {
+		r.runTick(time.Now())
+	}
+	for i := 0; i < 3; i++ {
+		moveTo(r, "alice", 90, 50)
+		moveTo(r, "alice", 10, 50)
+	}
+	if r.Phase() != PhaseResults {
+		t.Fatalf("phase = %s, want results", r.Phase())
+	}
+	res := r.match.last
+	if res == nil || res.Reason != EndCaptureLimit || res.Teams[0].ID != "red" || res.Teams[0].Rank != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+// waitLeft 推进 Tick 直到会话关闭的玩家被移出房间
+func waitLeft(t *testing.T, r *Room, id PlayerID) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for r.Players[id] != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s not removed after session close", id)
+		}
+		time.Sleep(time.Millisecond)
+		r.runTick(time.Now())
+	}
+}
@@ -0,0 +1,379 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ModeKOTH 占山为王玩法名称
+const ModeKOTH = "koth"
+
+// EndScoreLimit 达到目标分数
+const EndScoreLimit = "score_limit"
+
+func init() {
+	RegisterMode(ModeKOTH, newKingOfTheHill)
+}
+
+// KOTHConfig 占山为王参数（房间 modeConfig）
+type KOTHConfig struct {
+	Zones       []KOTHZone `json:"zones"`
+	ActiveZones int        `json:"activeZones"` // 同时开放的区域数，默认 1
+	RotateMs    int        `json:"rotateMs"`    // 开放区域轮换间隔，默认 30000；0 表示不轮换
+	Points      int        `json:"points"`      // 独占区域每 Tick 得分，默认 1
+	ScoreLimit  int        `json:"scoreLimit"`  // 先达到该分数的玩家（有队伍时为队伍）获胜；0 表示不限
+}
+
+// KOTHZone 命名的矩形得分区域
+type KOTHZone struct {
+	Name string `json:"name"`
+	Rect
+}
+
+// koth 区域状态
+const (
+	zoneInactive  = "inactive"
+	zoneNeutral   = "neutral"
+	zoneHeld      = "held"
+	zoneContested = "contested"
+)
+
+// kothZone 区域的运行期状态
+type kothZone struct {
+	KOTHZone
+	active    bool
+	owner     string // 独占者：有队伍时为队伍 ID，否则为玩家 ID
+	contested bool
+}
+
+func (z *kothZone) state() string {
+	switch {
+	case !z.active:
+		return zoneInactive
+	case z.contested:
+		return zoneContested
+	case z.owner != "":
+		return zoneHeld
+	default:
+		return zoneNeutral
+	}
+}
+
+// zoneEvent 区域事件（公开广播）
+type zoneEvent struct {
+	Type  string   `json:"type"`
+	Event string   `json:"event"` // rotated | captured | contested | lost
+	Zone  string   `json:"zone,omitempty"`
+	Owner string   `json:"owner,omitempty"`
+	Zones []string `json:"zones,omitempty"` // rotated：当前开放的区域
+	Tick  int64    `json:"tick"`
+}
+
+// KingOfTheHill 占山为王：开放区域内只有一名玩家（有队伍时为一支队伍）时每 Tick 得分，
+// 多方同时在区域内为争夺状态，不计分；开放区域按计时器轮换
+type KingOfTheHill struct {
+	FreeMove
+	cfg      KOTHConfig
+	zones    []*kothZone
+	next     int   // 下一次轮换开放的第一个区域下标
+	rotateAt int64 // 下一次轮换的 Tick，0 表示不轮换
+	scores   map[PlayerID]int
+	ids      []PlayerID // scores 的有序键，仅在出现新的得分者或清零时重建
+	changed  []PlayerID // 自上次增量以来得分变化的玩家，编码后复用
+	dirty    bool       // 区域有变化，下一次增量携带
+}
+
+func newKingOfTheHill(p RoomProfile) (GameMode, error) {
+	cfg := KOTHConfig{ActiveZones: 1, RotateMs: 30000, Points: 1}
+	if len(p.ModeConfig) > 0 {
+		if err := json.Unmarshal(p.ModeConfig, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Zones) == 0 {
+		return nil, fmt.Errorf("at least one zone is required")
+	}
+	if cfg.ActiveZones < 1 || cfg.ActiveZones > len(cfg.Zones) {
+		return nil, fmt.Errorf("activeZones must be between 1 and %d", len(cfg.Zones))
+	}
+	if cfg.Points <= 0 || cfg.RotateMs < 0 || cfg.ScoreLimit < 0 {
+		return nil, fmt.Errorf("points must be positive, rotateMs and scoreLimit non-negative")
+	}
+	m := &KingOfTheHill{cfg: cfg, scores: make(map[PlayerID]int)}
+	seen := make(map[string]bool, len(cfg.Zones))
+	for _, z := range cfg.Zones {
+		if z.Name == "" {
+			return nil, fmt.Errorf("zone name is required")
+		}
+		if seen[z.Name] {
+			return nil, fmt.Errorf("duplicate zone: %q", z.Name)
+		}
+		seen[z.Name] = true
+		if err := z.Rect.validate(p.Width, p.Height); err != nil {
+			return nil, fmt.Errorf("zone %s: %w", z.Name, err)
+		}
+		m.zones = append(m.zones, &kothZone{KOTHZone: z})
+	}
+	return m, nil
+}
+
+func (m *KingOfTheHill) Name() string { return ModeKOTH }
+
+// OnRoomStart 未启用对局流程时房间创建即开始计分
+func (m *KingOfTheHill) OnRoomStart(r *Room) { m.resetRound(r) }
+
+// OnMatchStart 玩家回到出生点，区域从第一组开始开放，个人得分清零
+func (m *KingOfTheHill) OnMatchStart(r *Room) {
+	m.FreeMove.OnMatchStart(r)
+	m.resetRound(r)
+}
+
+func (m *KingOfTheHill) resetRound(r *Room) {
+	clear(m.scores)
+	m.ids, m.changed = m.ids[:0], m.changed[:0]
+	m.next = 0
+	m.rotate(r)
+}
+
+// rotate 开放下一组区域并重新计时
+func (m *KingOfTheHill) rotate(r *Room) {
+	for _, z := range m.zones {
+		z.active, z.owner, z.contested = false, "", false
+	}
+	for i := 0; i < m.cfg.ActiveZones; i++ {
+		m.zones[(m.next+i)%len(m.zones)].active = true
+	}
+	m.next = (m.next + m.cfg.ActiveZones) % len(m.zones)
+	m.rotateAt = 0
+	if m.cfg.RotateMs > 0 && len(m.zones) > m.cfg.ActiveZones {
+		m.rotateAt = r.Tick() + int64(r.ticksFor(time.Duration(m.cfg.RotateMs)*time.Millisecond))
+	}
+	m.dirty = true
+}
+
+// side 玩家的计分方：有队伍时为队伍，否则为玩家本人
+func (m *KingOfTheHill) side(r *Room, p *Player) string {
+	if len(r.Teams()) > 0 && p.Team != "" {
+		return p.Team
+	}
+	return string(p.ID)
+}
+
+func (m *KingOfTheHill) OnTick(r *Room) {
+	if m.rotateAt > 0 && r.Tick() >= m.rotateAt {
+		m.rotate(r)
+		m.announce(r, zoneEvent{Event: "rotated", Zones: m.activeNames()})
+	}
+	teams := len(r.Teams()) > 0
+	for _, z := range m.zones {
+		if !z.active {
+			continue
+		}
+		owner, contested := "", false
+		var holders []*Player
+		for _, p := range r.Players {
+			if !z.Contains(p.X, p.Y) {
+				continue
+			}
+			s := m.side(r, p)
+			if owner != "" && owner != s {
+				contested = true
+			}
+			owner = s
+			holders = append(holders, p)
+		}
+		if contested {
+			owner = ""
+		}
+		if owner != z.owner || contested != z.contested {
+			prev := z.owner
+			z.owner, z.contested = owner, contested
+			m.dirty = true
+			switch {
+			case owner != "":
+				m.announce(r, zoneEvent{Event: "captured", Zone: z.Name, Owner: owner})
+			case contested:
+				m.announce(r, zoneEvent{Event: "contested", Zone: z.Name, Owner: prev})
+			default:
+				m.announce(r, zoneEvent{Event: "lost", Zone: z.Name, Owner: prev})
+			}
+		}
+		if owner == "" {
+			continue
+		}
+		// 独占：区域内每名玩家记个人得分，有队伍时队伍得分
+		for _, p := range holders {
+			m.addScore(p.ID)
+			r.AddStat(p.ID, "holdTicks", 1)
+		}
+		if teams {
+			r.AddTeamScore(owner, m.cfg.Points)
+		}
+		if m.reachedLimit(r, owner) {
+			Log.Infof("score limit reached: room=%s zone=%s owner=%s", r.ID, z.Name, owner)
+			r.EndMatch(EndScoreLimit)
+			if r.Phase() == PhasePlaying {
+				// 未启用对局流程：直接开始新一轮
+				r.resetTeamScores()
+				m.resetRound(r)
+			}
+			return
+		}
+	}
+}
+
+// addScore 独占得分并记录到下一次增量
+func (m *KingOfTheHill) addScore(id PlayerID) {
+	if _, ok := m.scores[id]; !ok {
+		i, _ := slices.BinarySearch(m.ids, id)
+		m.ids = slices.Insert(m.ids, i, id)
+	}
+	m.scores[id] += m.cfg.Points
+	m.changed = append(m.changed, id)
+}
+
+func (m *KingOfTheHill) reachedLimit(r *Room, owner string) bool {
+	if m.cfg.ScoreLimit == 0 {
+		return false
+	}
+	if t := r.TeamByID(owner); t != nil {
+		return t.Score >= m.cfg.ScoreLimit
+	}
+	return m.scores[PlayerID(owner)] >= m.cfg.ScoreLimit
+}
+
+func (m *KingOfTheHill) activeNames() []string {
+	var out []string
+	for _, z := range m.zones {
+		if z.active {
+			out = append(out, z.Name)
+		}
+	}
+	return out
+}
+
+// kothSnapshot 故障恢复用的区域、轮换计时与个人得分副本
+type kothSnapshot struct {
+	zones    []kothZone
+	next     int
+	rotateAt int64
+	scores   map[PlayerID]int
+}
+
+func (m *KingOfTheHill) SnapshotState(*Room) any {
+	s := kothSnapshot{zones: make([]kothZone, len(m.zones)), next: m.next, rotateAt: m.rotateAt, scores: maps.Clone(m.scores)}
+	for i, z := range m.zones {
+		s.zones[i] = *z
+	}
+	return s
+}
+
+func (m *KingOfTheHill) RestoreState(_ *Room, v any) {
+	s, ok := v.(kothSnapshot)
+	if !ok {
+		return
+	}
+	for i := range m.zones {
+		*m.zones[i] = s.zones[i]
+	}
+	m.next, m.rotateAt = s.next, s.rotateAt
+	m.scores = maps.Clone(s.scores)
+	m.ids = m.ids[:0]
+	for id := range m.scores {
+		m.ids = append(m.ids, id)
+	}
+	slices.Sort(m.ids)
+	m.changed = m.changed[:0]
+	m.dirty = true
+}
+
+func (m *KingOfTheHill) announce(r *Room, ev zoneEvent) {
+	ev.Type, ev.Tick = "zone", r.Tick()
+	r.BroadcastEvent("zone", ev)
+}
+
+// Standings 个人得分为独占区域累计的分数
+func (m *KingOfTheHill) Standings(r *Room) []Standing {
+	out := make([]Standing, 0, len(r.Players))
+	for id := range r.Players {
+		out = append(out, Standing{ID: id, Score: m.scores[id]})
+	}
+	for id, n := range m.scores {
+		if _, ok := r.Players[id]; !ok {
+			out = append(out, Standing{ID: id, Score: n})
+		}
+	}
+	return out
+}
+
+// BuildWorldView 编码 ,"zones":[{"name":..,"x":..,"y":..,"w":..,"h":..,"state":..,"owner":..}],"rotateTick":..,"scores":{..}；
+// 增量只在区域变化时携带 zones 与全部得分（轮换清零、故障回滚均属此类），否则 scores 只含得分变化的玩家
+func (m *KingOfTheHill) BuildWorldView(r *Room, b []byte, full bool) []byte {
+	if full || m.dirty {
+		b = m.appendZones(b)
+		b = m.appendScores(b, m.ids)
+		if !full {
+			m.dirty = false
+			m.changed = m.changed[:0]
+		}
+		return b
+	}
+	if len(m.changed) == 0 {
+		return b
+	}
+	slices.Sort(m.changed)
+	m.changed = slices.Compact(m.changed)
+	b = m.appendScores(b, m.changed)
+	m.changed = m.changed[:0]
+	return b
+}
+
+func (m *KingOfTheHill) appendZones(b []byte) []byte {
+	b = append(b, `,"zones":[`...)
+	for i, z := range m.zones {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"name":`...)
+		b = appendJSONString(b, z.Name)
+		b = append(b, `,"x":`...)
+		b = appendJSONFloat(b, z.X)
+		b = append(b, `,"y":`...)
+		b = appendJSONFloat(b, z.Y)
+		b = append(b, `,"w":`...)
+		b = appendJSONFloat(b, z.W)
+		b = append(b, `,"h":`...)
+		b = appendJSONFloat(b, z.H)
+		b = append(b, `,"state":"`...)
+		b = append(b, z.state()...)
+		b = append(b, '"')
+		if z.owner != "" {
+			b = append(b, `,"owner":`...)
+			b = appendJSONString(b, z.owner)
+		}
+		b = append(b, '}')
+	}
+	b = append(b, ']')
+	if m.rotateAt > 0 {
+		b = append(b, `,"rotateTick":`...)
+		b = strconv.AppendInt(b, m.rotateAt, 10)
+	}
+	return b
+}
+
+func (m *KingOfTheHill) appendScores(b []byte, ids []PlayerID) []byte {
+	b = append(b, `,"scores":{`...)
+	for i, id := range ids {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, string(id))
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(m.scores[id]), 10)
+	}
+	return append(b, '}')
+}
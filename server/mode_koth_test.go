@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// kothDelta 会话收到的最后一条 delta 中的区域与得分
+func kothDelta(t *testing.T, s *MemSession) (zones json.RawMessage, scores map[string]int) {
+	t.Helper()
+	found := false
+	for {
+		select {
+		case b := <-s.Recv():
+			var m struct {
+				Type   string          `json:"type"`
+				Zones  json.RawMessage `json:"zones"`
+				Scores map[string]int  `json:"scores"`
+			}
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("invalid message %s: %v", b, err)
+			}
+			if m.Type == "delta" {
+				zones, scores, found = m.Zones, m.Scores, true
+			}
+		default:
+			if !found {
+				t.Fatal("no delta received")
+			}
+			return zones, scores
+		}
+	}
+}
+
+// kothRoom 两个区域 hill-a (20,20,10,10) 与 hill-b (70,70,10,10) 的占山为王测试房间
+func kothRoom(t *testing.T, modeConfig string, edit func(p *RoomProfile)) *Room {
+	t.Helper()
+	return testRoom(t, func(p *RoomProfile) {
+		p.Width, p.Height = 100, 100
+		p.TicksPerSecond = 10
+		p.Mode = ModeKOTH
+		p.ModeConfig = json.RawMessage(modeConfig)
+		if edit != nil {
+			edit(p)
+		}
+	})
+}
+
+const kothZones = `"zones":[{"name":"hill-a","x":20,"y":20,"w":10,"h":10},{"name":"hill-b","x":70,"y":70,"w":10,"h":10}]`
+
+func TestKOTHHoldScoresAndContestBlocks(t *testing.T) {
+	r := kothRoom(t, `{`+kothZones+`,"rotateMs":0,"points":2}`, nil)
+	ConnectMem(r, "alice", 256)
+	ConnectMem(r, "bob", 256)
+	r.runTick(time.Now())
+	koth := r.mode.(*KingOfTheHill)
+	r.Players["bob"].X, r.Players["bob"].Y = 50, 50
+
+	moveTo(r, "alice", 25, 25)
+	moveTo(r, "alice", 25, 25)
+	if got := koth.scores["alice"]; got != 4 {
+		t.Fatalf("alice score = %d, want 4 after two held ticks", got)
+	}
+	if z := koth.zones[0]; z.state() != zoneHeld || z.owner != "alice" {
+		t.Fatalf("hill-a = %s owner=%q, want held by alice", z.state(), z.owner)
+	}
+
+	moveTo(r, "bob", 26, 26)
+	if z := koth.zones[0]; z.state() != zoneContested {
+		t.Fatalf("hill-a = %s, want contested", z.state())
+	}
+	if koth.scores["alice"] != 4 || koth.scores["bob"] != 0 {
+		t.Fatalf("scores while contested = %v", koth.scores)
+	}
+
+	// 未开放的区域不计分
+	moveTo(r, "bob", 75, 75)
+	if koth.scores["bob"] != 0 || koth.zones[1].state() != zoneInactive {
+		t.Fatalf("inactive hill-b scored: scores=%v state=%s", koth.scores, koth.zones[1].state())
+	}
+}
+
+func TestKOTHTeamsScoreTogether(t *testing.T) {
+	r := kothRoom(t, `{`+kothZones+`,"rotateMs":0}`, func(p *RoomProfile) {
+		p.Teams = []TeamConfig{{ID: "red"}, {ID: "blue"}}
+	})
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "blue")
+	joinTeam(r, "carol", "red")
+	joinTeam(r, "dave", "blue")
+	r.runTick(time.Now())
+	koth := r.mode.(*KingOfTheHill)
+	if r.Players["carol"].Team != "red" {
+		t.Fatalf("carol team = %q, want red", r.Players["carol"].Team)
+	}
+	r.Players["bob"].X, r.Players["bob"].Y = 50, 50
+	r.Players["dave"].X, r.Players["dave"].Y = 50, 50
+
+	r.Players["carol"].X, r.Players["carol"].Y = 21, 21
+	moveTo(r, "alice", 25, 25)
+	if z := koth.zones[0]; z.owner != "red" {
+		t.Fatalf("hill-a owner = %q, want red", z.owner)
+	}
+	if got := r.TeamByID("red").Score; got != 1 {
+		t.Fatalf("red score = %d, want 1 per tick", got)
+	}
+	if koth.scores["alice"] != 1 || koth.scores["carol"] != 1 {
+		t.Fatalf("holder scores = %v, want 1 each", koth.scores)
+	}
+}
+
+func TestKOTHRotatesZones(t *testing.T) {
+	r := kothRoom(t, `{`+kothZones+`,"rotateMs":1000}`, nil)
+	ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	koth := r.mode.(*KingOfTheHill)
+	if !koth.zones[0].active || koth.zones[1].active {
+		t.Fatal("hill-a should open first")
+	}
+	at := koth.rotateAt
+	if want := r.Tick() - 1 + 10; at != want {
+		t.Fatalf("rotateAt = %d, want %d (1s at 10 TPS)", at, want)
+	}
+	for r.Tick() < at {
+		r.runTick(time.Now())
+	}
+	if koth.zones[0].active || !koth.zones[1].active {
+		t.Fatal("hill-b should open after rotation")
+	}
+}
+
+func TestKOTHScoreLimitEndsMatch(t *testing.T) {
+	r := kothRoom(t, `{`+kothZones+`,"rotateMs":0,"scoreLimit":3}`, func(p *RoomProfile) {
+		p.Match = MatchConfig{Enabled: true, MinPlayers: 1, ResultsMs: 60000}
+	})
+	ConnectMem(r, "alice", 256)
+	for i := 0; i < 3 && r.Phase() != PhasePlaying; i++ {
+		r.runTick(time.Now())
+	}
+	for i := 0; i < 3; i++ {
+		moveTo(r, "alice", 25, 25)
+	}
+	if r.Phase() != PhaseResults {
+		t.Fatalf("phase = %s, want results", r.Phase())
+	}
+	if res := r.match.last; res == nil || res.Reason != EndScoreLimit || res.Players[0].Score != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestKOTHDeltaCarriesOnlyChanges(t *testing.T) {
+	r := kothRoom(t, `{`+kothZones+`,"rotateMs":0}`, nil)
+	s := ConnectMem(r, "alice", 256)
+	ConnectMem(r, "bob", 256)
+	r.runTick(time.Now())
+	r.Players["alice"].X, r.Players["alice"].Y = 50, 50
+	moveTo(r, "bob", 25, 25)
+	if zones, scores := kothDelta(t, s); zones == nil || scores["bob"] != 1 {
+		t.Fatalf("capture delta: zones=%s scores=%v, want zones and bob=1", zones, scores)
+	}
+
+	// 持续独占：区域不变，只携带变化的得分
+	moveTo(r, "bob", 25, 25)
+	if zones, scores := kothDelta(t, s); zones != nil || len(scores) != 1 || scores["bob"] != 2 {
+		t.Fatalf("held delta: zones=%s scores=%v, want only bob=2", zones, scores)
+	}
+
+	moveTo(r, "bob", 50, 50)
+	if zones, _ := kothDelta(t, s); zones == nil {
+		t.Fatal("lost zone not reported")
+	}
+	moveTo(r, "bob", 50, 50)
+	if zones, scores := kothDelta(t, s); zones != nil || scores != nil {
+		t.Fatalf("idle delta: zones=%s scores=%v, want neither", zones, scores)
+	}
+}
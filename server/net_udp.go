@@ -0,0 +1,597 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDP 传输：面向低延迟原生客户端，与 /ws 并存，输入走同一 Room.OnInput 路径
+//
+// 数据包格式：type(1) | token(8, 大端) | seq(4, 大端) | payload
+//   - hello      客户端 → 服务端，token=0，payload 为 {"room":"..","player":"..","team":".."}（team 可选）；未收到 welcome 时客户端重发
+//   - welcome    服务端 → 客户端，token 为会话令牌，此后所有数据包都需携带
+//   - unreliable 不可靠有序通道：state / delta 状态流，seq 递增，接收方丢弃过期包
+//   - reliable   可靠有序通道：快照、关闭通知等，接收方按 seq 顺序交付并回 ack，发送方超时重传
+//   - ack        seq 为已按序收到的最大可靠序号（累计确认）
+//   - ping       保活；服务端原样回复
+//   - bye        任一方断开
+//   - state_ack  客户端 → 服务端，seq 为已按序应用的最大状态流序号；payload 为 0x01 时表示发现缺口，请求全量 state
+//
+// 状态流增量以上一 Tick 的广播为基线：客户端确认过状态流后才开始接收 delta；
+// 发现序号缺口、服务端发送队列溢出或确认落后超过 udpStateAckWindow 时改发全量 state，直到客户端重新对齐
+//
+// 客户端 → 服务端的 payload 与 WebSocket 文本消息相同（如 {"type":"move","command":"up","seq":1}）
+const (
+	udpHello      byte = 1
+	udpWelcome    byte = 2
+	udpUnreliable byte = 3
+	udpReliable   byte = 4
+	udpAck        byte = 5
+	udpPing       byte = 6
+	udpBye        byte = 7
+	udpStateAck   byte = 8
+)
+
+const (
+	udpHeaderLen      = 13
+	udpMaxPacket      = 64 * 1024
+	udpResendInterval = 100 * time.Millisecond
+	udpMaxResends     = 20               // 单个可靠包重传上限，超出视为对端失联
+	udpIdleTimeout    = 10 * time.Second // 超时未收到任何数据包即断开
+	udpStateAckWindow = 64               // 状态流已发送但未确认的包数上限，超出改发全量
+)
+
+// UDPServer UDP 监听与会话表
+type UDPServer struct {
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	sessions map[uint64]*udpSession
+	closed   chan struct{}
+}
+
+// ListenUDP 在 addr 上监听 UDP
+func ListenUDP(addr string) (*UDPServer, error) {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", ua)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPServer{
+		conn:     conn,
+		sessions: make(map[uint64]*udpSession),
+		closed:   make(chan struct{}),
+	}, nil
+}
+
+// Addr 实际监听地址
+func (s *UDPServer) Addr() net.Addr { return s.conn.LocalAddr() }
+
+// Serve 读循环（阻塞），直到 Close
+func (s *UDPServer) Serve() error {
+	go s.sweep()
+	buf := make([]byte, udpMaxPacket)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.closed:
+				return nil
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if n < udpHeaderLen {
+			continue
+		}
+		s.handle(buf[:n], addr)
+	}
+}
+
+// Close 断开全部会话并停止监听
+func (s *UDPServer) Close() error {
+	close(s.closed)
+	s.mu.Lock()
+	all := make([]*udpSession, 0, len(s.sessions))
+	for _, us := range s.sessions {
+		all = append(all, us)
+	}
+	s.mu.Unlock()
+	for _, us := range all {
+		us.terminate("server shutdown")
+	}
+	return s.conn.Close()
+}
+
+func (s *UDPServer) handle(pkt []byte, addr *net.UDPAddr) {
+	typ := pkt[0]
+	token := binary.BigEndian.Uint64(pkt[1:9])
+	seq := binary.BigEndian.Uint32(pkt[9:13])
+	payload := pkt[udpHeaderLen:]
+
+	if typ == udpHello {
+		s.hello(payload, addr)
+		return
+	}
+	s.mu.Lock()
+	us := s.sessions[token]
+	s.mu.Unlock()
+	if us == nil {
+		// 未知令牌（会话已过期）：通知客户端重新握手
+		s.write(addr, udpBye, token, 0, nil)
+		return
+	}
+	us.touch(addr)
+	switch typ {
+	case udpUnreliable:
+		us.deliver(payload)
+	case udpReliable:
+		us.receiveReliable(seq, payload)
+	case udpAck:
+		us.acked(seq)
+	case udpStateAck:
+		us.stateAcked(seq, len(payload) > 0 && payload[0] == 1)
+	case udpPing:
+		s.write(addr, udpPing, token, seq, nil)
+	case udpBye:
+		us.terminate("client bye")
+	}
+}
+
+// hello 握手：同一地址与玩家的重复 hello 复用已有会话（welcome 可能丢失）
+func (s *UDPServer) hello(payload []byte, addr *net.UDPAddr) {
+	var req struct {
+		Room   string `json:"room"`
+		Player string `json:"player"`
+		Team   string `json:"team"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil || req.Player == "" {
+		Log.Debugf("udp hello rejected: addr=%s err=%v", addr, err)
+		return
+	}
+	if req.Room == "" {
+		req.Room = "room-1"
+	}
+	s.mu.Lock()
+	var us *udpSession
+	for _, cand := range s.sessions {
+		if cand.playerID == PlayerID(req.Player) && cand.roomID == req.Room && cand.peer().String() == addr.String() {
+			us = cand
+			break
+		}
+	}
+	if us == nil {
+		room := GetRoomManager().GetOrCreateRoom(req.Room)
+		us = newUDPSession(s, room, PlayerID(req.Player), addr)
+		s.sessions[us.token] = us
+		s.mu.Unlock()
+		registerSession(us)
+		go us.writeLoop()
+		room.Attach(us.playerID, us, JoinRequest{Team: req.Team})
+		Log.Infof("udp session opened: room=%s player=%s addr=%s", us.roomID, us.playerID, addr)
+	} else {
+		s.mu.Unlock()
+	}
+	welcome, _ := json.Marshal(map[string]string{"room": us.roomID, "player": string(us.playerID)})
+	s.write(addr, udpWelcome, us.token, 0, welcome)
+}
+
+// sweep 定期清理超时未活动的会话
+func (s *UDPServer) sweep() {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return
+		case now := <-t.C:
+			s.mu.Lock()
+			var idle []*udpSession
+			for _, us := range s.sessions {
+				if now.Sub(time.Unix(0, atomic.LoadInt64(&us.lastSeen))) > udpIdleTimeout {
+					idle = append(idle, us)
+				}
+			}
+			s.mu.Unlock()
+			for _, us := range idle {
+				us.terminate("idle timeout")
+			}
+		}
+	}
+}
+
+func (s *UDPServer) remove(token uint64) {
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+}
+
+func (s *UDPServer) write(addr *net.UDPAddr, typ byte, token uint64, seq uint32, payload []byte) {
+	pkt := appendUDPHeader(make([]byte, 0, udpHeaderLen+len(payload)), typ, token, seq)
+	pkt = append(pkt, payload...)
+	_, _ = s.conn.WriteToUDP(pkt, addr)
+}
+
+func appendUDPHeader(dst []byte, typ byte, token uint64, seq uint32) []byte {
+	dst = append(dst, typ)
+	dst = binary.BigEndian.AppendUint64(dst, token)
+	return binary.BigEndian.AppendUint32(dst, seq)
+}
+
+// udpSession 单个 UDP 客户端会话；写协程负责编码、发送与可靠包重传
+type udpSession struct {
+	srv      *UDPServer
+	token    uint64
+	room     *Room
+	roomID   string
+	playerID PlayerID
+
+	addrMu   sync.Mutex
+	addr     *net.UDPAddr // 客户端地址（NAT 重绑定时随数据包更新）
+	lastSeen int64        // UnixNano
+
+	send     chan outbound
+	ctrl     chan outbound // 可靠消息队列：不丢弃，优先写出
+	closed   bool          // 仅由 Tick 线程读写
+	degraded bool          // 发送队列溢出后只发全量（仅由 Tick 线程读写）
+	gone     chan struct{}
+	once     sync.Once
+
+	// 写协程状态
+	streamSeq uint32
+	relSeq    uint32
+	buf       []byte
+
+	// 状态流确认（并发安全）
+	streamSent  atomic.Uint32 // 已写出的最大状态流序号
+	streamAcked atomic.Uint32 // 客户端已按序应用的最大状态流序号
+	ackSeen     atomic.Bool   // 客户端是否确认过状态流（未确认的客户端只接收全量）
+	resync      atomic.Bool   // 客户端报告缺口，等待全量 state
+	lostSeq     atomic.Uint32 // 网络模拟丢弃的状态帧数，写出时跳过对应序号
+
+	// 待确认的可靠包（写协程与读协程共享）
+	pendMu  sync.Mutex
+	pending []udpPending
+
+	// 读协程状态：可靠通道按序交付
+	recvNext uint32
+	ooo      map[uint32][]byte
+	inbound  *inbound
+
+	Stats ConnStats
+}
+
+type udpPending struct {
+	seq     uint32
+	pkt     []byte
+	sentAt  time.Time
+	resends int
+}
+
+func newUDPSession(srv *UDPServer, room *Room, pid PlayerID, addr *net.UDPAddr) *udpSession {
+	var tb [8]byte
+	_, _ = rand.Read(tb[:])
+	return &udpSession{
+		srv:      srv,
+		token:    binary.BigEndian.Uint64(tb[:]) | 1, // 保证非 0
+		room:     room,
+		roomID:   room.ID,
+		playerID: pid,
+		addr:     addr,
+		lastSeen: time.Now().UnixNano(),
+		send:     make(chan outbound, CurrentConfig().Server.SendQueueSize),
+		ctrl:     make(chan outbound, controlQueueSize),
+		gone:     make(chan struct{}),
+		recvNext: 1,
+		inbound:  newInbound(room, pid),
+		ooo:      make(map[uint32][]byte),
+	}
+}
+
+func (us *udpSession) touch(addr *net.UDPAddr) {
+	atomic.StoreInt64(&us.lastSeen, time.Now().UnixNano())
+	us.addrMu.Lock()
+	if us.addr.String() != addr.String() {
+		us.addr = addr
+	}
+	us.addrMu.Unlock()
+}
+
+func (us *udpSession) peer() *net.UDPAddr {
+	us.addrMu.Lock()
+	defer us.addrMu.Unlock()
+	return us.addr
+}
+
+// deliver 客户端消息注入房间（与 WebSocket 相同的消息路由）
+func (us *udpSession) deliver(payload []byte) {
+	us.inbound.dispatch(payload)
+}
+
+// receiveReliable 按序交付可靠消息并回复累计确认；重复包同样回 ack
+func (us *udpSession) receiveReliable(seq uint32, payload []byte) {
+	if seq >= us.recvNext && len(us.ooo) < 256 {
+		if seq == us.recvNext {
+			us.deliver(payload)
+			us.recvNext++
+			for {
+				p, ok := us.ooo[us.recvNext]
+				if !ok {
+					break
+				}
+				delete(us.ooo, us.recvNext)
+				us.deliver(p)
+				us.recvNext++
+			}
+		} else {
+			us.ooo[seq] = append([]byte(nil), payload...)
+		}
+	}
+	us.srv.write(us.peer(), udpAck, us.token, us.recvNext-1, nil)
+}
+
+// acked 移除已被累计确认的可靠包
+func (us *udpSession) acked(seq uint32) {
+	us.pendMu.Lock()
+	n := 0
+	for n < len(us.pending) && us.pending[n].seq <= seq {
+		n++
+	}
+	us.pending = us.pending[n:]
+	us.pendMu.Unlock()
+}
+
+// stateAcked 记录客户端的状态流确认（读协程）；gap 表示客户端发现缺口，需要全量 state 重新对齐
+func (us *udpSession) stateAcked(seq uint32, gap bool) {
+	// 乱序到达的旧确认不回退
+	if !us.ackSeen.Load() || int32(seq-us.streamAcked.Load()) > 0 {
+		us.streamAcked.Store(seq)
+	}
+	us.ackSeen.Store(true)
+	if gap && !us.resync.Swap(true) {
+		Log.Debugf("udp state gap reported: room=%s player=%s acked=%d", us.roomID, us.playerID, seq)
+	}
+}
+
+// Send 将要发送的消息压入队列（非阻塞，满则丢弃并进入降级）；可靠消息进入独立队列
+func (us *udpSession) Send(f *Frame, ack int64) bool {
+	if us.closed {
+		return false
+	}
+	f.Retain()
+	if f.Reliable() {
+		return us.sendControl(outbound{frame: f, ack: ack})
+	}
+	select {
+	case us.send <- outbound{frame: f, ack: ack}:
+		return true
+	default:
+		// 丢弃该消息（防止阻塞 Tick）；增量基线已断，后续改发全量
+		f.Release()
+		atomic.AddInt64(&us.Stats.Dropped, 1)
+		if !us.degraded {
+			us.degraded = true
+			us.room.metrics.IncSnapshotFallback()
+			Log.Warnf("udp send queue full, fallback to snapshots: room=%s player=%s depth=%d", us.roomID, us.playerID, us.QueueDepth())
+		}
+		return false
+	}
+}
+
+// sendControl 可靠消息不因状态流拥塞而丢弃；可靠队列满时断开会话，由客户端重连后取快照
+func (us *udpSession) sendControl(out outbound) bool {
+	select {
+	case us.ctrl <- out:
+		return true
+	default:
+		out.frame.Release()
+		atomic.AddInt64(&us.Stats.Dropped, 1)
+		Log.Warnf("udp control queue full, close: room=%s player=%s depth=%d", us.roomID, us.playerID, len(us.ctrl))
+		us.terminate("control queue overflow")
+		return false
+	}
+}
+
+// SendFull 发送全量消息；降级会话需等队列回落到低水位，不可靠通道上的全量送出后恢复增量
+func (us *udpSession) SendFull(f *Frame, ack int64) bool {
+	if us.degraded && us.QueueDepth() > cap(us.send)/4 {
+		return false
+	}
+	if !us.Send(f, ack) {
+		return false
+	}
+	us.degraded = false
+	if !f.Reliable() {
+		// 可靠通道的快照与状态流无序关系，只有 state 能作为新的增量基线
+		us.resync.Store(false)
+	}
+	return true
+}
+
+// SnapshotOnly 客户端未确认过状态流、报告了缺口、确认落后过多或发送队列溢出时只发全量
+func (us *udpSession) SnapshotOnly() bool {
+	if us.degraded || !us.ackSeen.Load() || us.resync.Load() {
+		return true
+	}
+	return int32(us.streamSent.Load()-us.streamAcked.Load()) > udpStateAckWindow
+}
+
+// simulatedLoss 网络模拟丢弃了一帧状态流：跳过一个序号，让客户端如同真实丢包一样发现缺口
+func (us *udpSession) simulatedLoss() { us.lostSeq.Add(1) }
+
+func (us *udpSession) Close() {
+	if !us.closed {
+		us.closed = true
+		close(us.send)
+		close(us.ctrl)
+	}
+	us.terminate("closed by server")
+	// 写协程随会话结束退出，归还仍在队列中的帧
+	for out := range us.send {
+		out.frame.Release()
+	}
+	for out := range us.ctrl {
+		out.frame.Release()
+	}
+}
+
+// CloseAfterFlush 写完队列并等待可靠包确认（或重传耗尽）后断开
+func (us *udpSession) CloseAfterFlush() {
+	if !us.closed {
+		us.closed = true
+		close(us.send)
+		close(us.ctrl)
+	}
+}
+
+func (us *udpSession) Done() <-chan struct{} { return us.gone }
+
+// terminate 结束会话（可重复调用，任意协程）
+func (us *udpSession) terminate(reason string) {
+	us.once.Do(func() {
+		close(us.gone)
+		us.srv.remove(us.token)
+		unregisterSession(us)
+		us.srv.write(us.peer(), udpBye, us.token, 0, nil)
+		Log.Infof("udp session closed: room=%s player=%s reason=%s", us.roomID, us.playerID, reason)
+	})
+}
+
+func (us *udpSession) QueueDepth() int { return len(us.send) }
+
+func (us *udpSession) labels() (string, string, PlayerID) { return "udp", us.roomID, us.playerID }
+func (us *udpSession) stats() *ConnStats                  { return &us.Stats }
+
+// writeLoop 写协程：按 Frame 类型选择通道（可靠消息优先），定期重传未确认的可靠包
+func (us *udpSession) writeLoop() {
+	t := time.NewTicker(udpResendInterval)
+	defer t.Stop()
+	send, ctrl := us.send, us.ctrl
+	flushing := false
+	for {
+		select {
+		case out, ok := <-ctrl:
+			if ok {
+				us.write(out)
+			} else {
+				ctrl = nil
+			}
+			continue
+		default:
+		}
+		if send == nil && ctrl == nil && !flushing {
+			// 两个队列都已关闭：等待可靠包确认后断开
+			flushing = true
+			if us.pendingCount() == 0 {
+				us.terminate("flushed")
+				return
+			}
+		}
+		select {
+		case <-us.gone:
+			return
+		case out, ok := <-ctrl:
+			if !ok {
+				ctrl = nil
+				continue
+			}
+			us.write(out)
+		case out, ok := <-send:
+			if !ok {
+				send = nil
+				continue
+			}
+			us.write(out)
+		case <-t.C:
+			if !us.resend() {
+				us.terminate("peer unresponsive")
+				return
+			}
+			if flushing && us.pendingCount() == 0 {
+				us.terminate("flushed")
+				return
+			}
+		}
+	}
+}
+
+// write 写出一帧并释放队列持有的引用
+func (us *udpSession) write(out outbound) {
+	err := us.writeFrame(out)
+	out.frame.Release()
+	if err != nil {
+		Log.Warnf("udp write: room=%s player=%s err=%v", us.roomID, us.playerID, err)
+	}
+}
+
+var errUDPTooLarge = errors.New("message exceeds udp packet size")
+
+func (us *udpSession) writeFrame(out outbound) error {
+	f := out.frame
+	if udpHeaderLen+len(f.Bytes())+64 > udpMaxPacket {
+		atomic.AddInt64(&us.Stats.Dropped, 1)
+		return errUDPTooLarge
+	}
+	typ, seq := udpUnreliable, uint32(0)
+	if f.Reliable() {
+		us.relSeq++
+		typ, seq = udpReliable, us.relSeq
+	} else {
+		us.streamSeq += 1 + us.lostSeq.Swap(0)
+		seq = us.streamSeq
+		us.streamSent.Store(seq)
+	}
+	us.buf = appendUDPHeader(us.buf[:0], typ, us.token, seq)
+	us.buf = f.AppendTo(us.buf, out.ack)
+	if typ == udpReliable {
+		us.pendMu.Lock()
+		us.pending = append(us.pending, udpPending{seq: seq, pkt: append([]byte(nil), us.buf...), sentAt: time.Now()})
+		us.pendMu.Unlock()
+	}
+	if _, err := us.srv.conn.WriteToUDP(us.buf, us.peer()); err != nil {
+		return err
+	}
+	atomic.AddInt64(&us.Stats.MessagesSent, 1)
+	atomic.AddInt64(&us.Stats.BytesSent, int64(len(us.buf)))
+	return nil
+}
+
+// resend 重传超时未确认的可靠包；超过重传上限返回 false
+func (us *udpSession) resend() bool {
+	now := time.Now()
+	addr := us.peer()
+	us.pendMu.Lock()
+	defer us.pendMu.Unlock()
+	for i := range us.pending {
+		p := &us.pending[i]
+		if now.Sub(p.sentAt) < udpResendInterval {
+			continue
+		}
+		if p.resends >= udpMaxResends {
+			return false
+		}
+		p.resends++
+		p.sentAt = now
+		_, _ = us.srv.conn.WriteToUDP(p.pkt, addr)
+		atomic.AddInt64(&us.Stats.BytesSent, int64(len(p.pkt)))
+	}
+	return true
+}
+
+func (us *udpSession) pendingCount() int {
+	us.pendMu.Lock()
+	defer us.pendMu.Unlock()
+	return len(us.pending)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testUDPSession 监听本地端口的 UDP 会话（不启动读写协程），返回会话与客户端套接字
+func testUDPSession(t *testing.T, r *Room, queue int) (*udpSession, *net.UDPConn) {
+	t.Helper()
+	srv, err := ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.conn.Close() })
+	client, err := net.DialUDP("udp", nil, srv.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	us := newUDPSession(srv, r, "alice", client.LocalAddr().(*net.UDPAddr))
+	us.send = make(chan outbound, queue)
+	return us, client
+}
+
+// readStreamSeq 读取客户端收到的下一个数据包，返回类型与序号
+func readStreamSeq(t *testing.T, c *net.UDPConn) (byte, uint32) {
+	t.Helper()
+	buf := make([]byte, udpMaxPacket)
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < udpHeaderLen {
+		t.Fatalf("short packet: %d bytes", n)
+	}
+	return buf[0], binary.BigEndian.Uint32(buf[9:13])
+}
+
+func TestUDPSessionDeltaAfterStateAck(t *testing.T) {
+	r := testRoom(t, nil)
+	us, _ := testUDPSession(t, r, 8)
+	if !us.SnapshotOnly() {
+		t.Fatal("session without state ack should receive full state")
+	}
+	us.stateAcked(0, false)
+	if us.SnapshotOnly() {
+		t.Fatal("acked session should receive deltas")
+	}
+
+	us.stateAcked(3, true)
+	if !us.SnapshotOnly() {
+		t.Fatal("gap report should fall back to full state")
+	}
+	snap := controlFrame(map[string]string{"type": "snapshot"})
+	defer snap.Release()
+	us.SendFull(snap, 0)
+	if !us.SnapshotOnly() {
+		t.Fatal("reliable snapshot must not clear the gap")
+	}
+	state := r.encodeState("state")
+	defer state.Release()
+	us.SendFull(state, 0)
+	if us.SnapshotOnly() {
+		t.Fatal("full state should restore deltas")
+	}
+
+	// 旧确认乱序到达不回退
+	us.stateAcked(2, false)
+	if got := us.streamAcked.Load(); got != 3 {
+		t.Fatalf("acked = %d, want 3", got)
+	}
+	us.streamSent.Store(3 + udpStateAckWindow + 1)
+	if !us.SnapshotOnly() {
+		t.Fatal("acks lagging beyond the window should fall back to full state")
+	}
+}
+
+func TestUDPSessionQueueOverflowDegrades(t *testing.T) {
+	r := testRoom(t, nil)
+	us, _ := testUDPSession(t, r, 4)
+	us.stateAcked(0, false)
+	f := r.encodeState("delta")
+	defer f.Release()
+	for i := 0; i < cap(us.send); i++ {
+		if !us.Send(f, 0) {
+			t.Fatalf("send %d rejected", i)
+		}
+	}
+	if us.Send(f, 0) || !us.SnapshotOnly() {
+		t.Fatal("overflow should drop and degrade")
+	}
+	if us.SendFull(f, 0) {
+		t.Fatal("full state accepted above the low watermark")
+	}
+	for len(us.send) > 0 {
+		(<-us.send).frame.Release()
+	}
+	if !us.SendFull(f, 0) || us.SnapshotOnly() {
+		t.Fatal("full state after drain should restore deltas")
+	}
+	(<-us.send).frame.Release()
+}
+
+func TestUDPSessionSimulatedLossLeavesGap(t *testing.T) {
+	r := testRoom(t, nil)
+	us, client := testUDPSession(t, r, 4)
+	f := r.encodeState("delta")
+	defer f.Release()
+
+	if err := us.writeFrame(outbound{frame: f}); err != nil {
+		t.Fatal(err)
+	}
+	us.simulatedLoss()
+	if err := us.writeFrame(outbound{frame: f}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []uint32{1, 3} {
+		typ, seq := readStreamSeq(t, client)
+		if typ != udpUnreliable || seq != want {
+			t.Fatalf("packet type=%d seq=%d, want unreliable seq %d", typ, seq, want)
+		}
+	}
+	if got := us.streamSent.Load(); got != 3 {
+		t.Fatalf("streamSent = %d, want 3", got)
+	}
+}
+
+func TestUDPSessionQueuesReliableSeparately(t *testing.T) {
+	r := testRoom(t, nil)
+	us, _ := testUDPSession(t, r, 1)
+	us.ctrl = make(chan outbound, 1)
+	state := frameFromBytes([]byte(`{"type":"state"}`))
+	defer state.Release()
+	us.Send(state, 0)
+	if us.Send(state, 0) || !us.degraded {
+		t.Fatal("overflowing state frame should degrade the session")
+	}
+
+	// 状态流拥塞不影响可靠消息
+	chat := controlFrame(map[string]string{"type": "chat"})
+	defer chat.Release()
+	if !us.Send(chat, 0) {
+		t.Fatal("reliable frame dropped on a full state queue")
+	}
+	select {
+	case <-us.Done():
+		t.Fatal("session closed while the control queue had room")
+	default:
+	}
+	if us.Send(chat, 0) {
+		t.Fatal("reliable frame accepted by a full control queue")
+	}
+	select {
+	case <-us.Done():
+	default:
+		t.Fatal("session kept open after the control queue overflowed")
+	}
+
+	// Close 归还仍在队列中的帧
+	us.Close()
+	if s, c := atomic.LoadInt32(&state.refs), atomic.LoadInt32(&chat.refs); s != 1 || c != 1 {
+		t.Fatalf("refs after close: state=%d chat=%d, want 1 (caller only)", s, c)
+	}
+}
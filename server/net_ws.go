@@ -1,101 +1,272 @@
 package server
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// ClientConn 负责发送（写）数据到客户端的轻量包装
+// ClientConn WebSocket 传输的会话实现：写协程发送、读协程接收输入
 type ClientConn struct {
-	ws   *websocket.Conn
-	send chan []byte
+	ws     *websocket.Conn
+	send   chan outbound
+	ctrl   chan outbound // 可靠消息队列：不丢弃，优先写出
+	closed bool          // 仅由 Tick 线程读写
+	gone   chan struct{} // 读协程退出（断线）时关闭
+
+	roomID   string
+	playerID PlayerID
+	metrics  *RoomMetrics
+
+	// 出站统计（并发安全）
+	Stats ConnStats
+	// 慢消费者状态（仅由 Tick 线程读写）
+	policy        SlowConsumerPolicy
+	dropStreak    int       // 连续丢弃次数
+	degradedSince time.Time // 进入“仅全量”降级的时间，零值表示正常
+	evicted       bool
+	// 写协程当前写操作的开始时间（UnixNano，0 表示空闲）
+	writingSince int64
 }
 
-func NewClientConn(ws *websocket.Conn) *ClientConn {
+// 慢消费者断开时使用的 WebSocket 关闭码
+const (
+	CloseSlowConsumerDrops = 4001 // 发送队列持续溢出
+	CloseSlowConsumerStall = 4002 // 写出阻塞
+)
+
+func NewClientConn(ws *websocket.Conn, room *Room, playerID PlayerID) *ClientConn {
+	cfg := CurrentConfig().Server
 	return &ClientConn{
-		ws:   ws,
-		send: make(chan []byte, 64),
+		ws:       ws,
+		send:     make(chan outbound, cfg.SendQueueSize),
+		ctrl:     make(chan outbound, controlQueueSize),
+		gone:     make(chan struct{}),
+		roomID:   room.ID,
+		playerID: playerID,
+		metrics:  room.metrics,
+		policy:   cfg.SlowConsumer,
+	}
+}
+
+// QueueDepth 当前发送队列中待写出的消息数（并发安全）
+func (c *ClientConn) QueueDepth() int {
+	return len(c.send)
+}
+
+// SnapshotOnly 连接是否处于降级状态（增量已丢失，只能发送全量）
+func (c *ClientConn) SnapshotOnly() bool {
+	return !c.degradedSince.IsZero()
+}
+
+// Done 断线时关闭
+func (c *ClientConn) Done() <-chan struct{} { return c.gone }
+
+func (c *ClientConn) labels() (string, string, PlayerID) { return "ws", c.roomID, c.playerID }
+func (c *ClientConn) stats() *ConnStats                  { return &c.Stats }
+
+// Send 将要发送的消息压入队列（非阻塞，满则丢弃并进入降级）
+// 入队成功时队列持有 f 的一个引用，写出后释放；ack 为该接收者头部中的确认序列
+func (c *ClientConn) Send(f *Frame, ack int64) bool {
+	if c.closed || c.evicted || c.checkPolicy(time.Now()) {
+		return false
+	}
+	f.Retain()
+	if f.Reliable() {
+		return c.sendControl(outbound{frame: f, ack: ack})
+	}
+	select {
+	case c.send <- outbound{frame: f, ack: ack}:
+		c.dropStreak = 0
+		return true
+	default:
+		f.Release()
+		// 为了实时性，丢弃该消息（防止阻塞 Tick）；后续改发全量以便客户端重新对齐
+		atomic.AddInt64(&c.Stats.Dropped, 1)
+		c.dropStreak++
+		if c.degradedSince.IsZero() {
+			c.degradedSince = time.Now()
+			c.metrics.IncSnapshotFallback()
+			Log.Warnf("send queue full, fallback to snapshots: room=%s player=%s depth=%d", c.roomID, c.playerID, c.QueueDepth())
+		}
+		if c.policy.MaxConsecutiveDrops > 0 && c.dropStreak >= c.policy.MaxConsecutiveDrops {
+			c.evict(CloseSlowConsumerDrops, "slow consumer: send queue overflow")
+		}
+		return false
 	}
 }
 
-// Enqueue 将要发送的消息压入队列（非阻塞，满则丢弃）
-func (c *ClientConn) Enqueue(b []byte) {
+// sendControl 可靠消息进入独立队列，不因状态流拥塞而丢弃；队列满时断开连接
+func (c *ClientConn) sendControl(out outbound) bool {
 	select {
-	case c.send <- b:
+	case c.ctrl <- out:
+		return true
 	default:
-		// 为了实时性，丢弃旧消息（防止阻塞 Tick）
+		out.frame.Release()
+		atomic.AddInt64(&c.Stats.Dropped, 1)
+		c.evict(CloseSlowConsumerDrops, "slow consumer: control queue overflow")
+		return false
+	}
+}
+
+// SendFull 发送全量消息；降级连接需等队列回落到低水位再发送，成功后恢复增量
+func (c *ClientConn) SendFull(f *Frame, ack int64) bool {
+	if c.SnapshotOnly() && c.QueueDepth() > cap(c.send)/4 {
+		c.checkPolicy(time.Now())
+		return false
+	}
+	if !c.Send(f, ack) {
+		return false
+	}
+	if c.SnapshotOnly() {
+		Log.Infof("send queue recovered: room=%s player=%s degraded=%s", c.roomID, c.playerID, time.Since(c.degradedSince))
+		c.degradedSince = time.Time{}
 	}
+	return true
+}
+
+// checkPolicy 检查写阻塞与降级时长，超限则断开；返回是否已断开
+func (c *ClientConn) checkPolicy(now time.Time) bool {
+	if since := atomic.LoadInt64(&c.writingSince); since > 0 && c.policy.WriteStallMs > 0 &&
+		now.Sub(time.Unix(0, since)) > time.Duration(c.policy.WriteStallMs)*time.Millisecond {
+		c.evict(CloseSlowConsumerStall, "slow consumer: write stalled")
+	} else if c.SnapshotOnly() && c.policy.MaxDegradedMs > 0 &&
+		now.Sub(c.degradedSince) > time.Duration(c.policy.MaxDegradedMs)*time.Millisecond {
+		c.evict(CloseSlowConsumerDrops, "slow consumer: send queue overflow")
+	}
+	return c.evicted
+}
+
+// evict 以指定关闭码断开慢消费者；读泵随之退出并触发离开流程
+func (c *ClientConn) evict(code int, reason string) {
+	if c.evicted {
+		return
+	}
+	c.evicted = true
+	c.metrics.IncSlowConsumerEvicted()
+	Log.Warnf("evict slow consumer: room=%s player=%s code=%d reason=%q dropped=%d depth=%d",
+		c.roomID, c.playerID, code, reason, atomic.LoadInt64(&c.Stats.Dropped), c.QueueDepth())
+	// 写协程可能正阻塞在网络上，关闭帧在独立协程中发送，避免拖慢 Tick
+	go func() {
+		msg := websocket.FormatCloseMessage(code, reason)
+		_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = c.ws.Close()
+	}()
 }
 
 // Close 关闭底层连接与发送队列
 func (c *ClientConn) Close() {
-	if c.send != nil {
+	if !c.closed {
 		// 关闭发送通道以结束写协程
+		c.closed = true
 		close(c.send)
-		c.send = nil
+		close(c.ctrl)
 	}
 	_ = c.ws.Close()
 }
 
-// writePump 独立协程，负责从 send 队列写出到 WS
+// CloseAfterFlush 关闭发送队列，写协程写完剩余消息后断开连接
+func (c *ClientConn) CloseAfterFlush() {
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+		close(c.ctrl)
+	}
+}
+
+// writePump 独立协程，负责从 send 与 ctrl 队列写出到 WS；两个队列都关闭并写完后退出
 func (c *ClientConn) writePump() {
 	defer c.ws.Close()
-	for msg := range c.send {
-		c.ws.SetWriteDeadline(time.Now().Add(5 * time.Second))
-		if err := c.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+	var hdr []byte // 接收者头部缓冲，写协程内复用
+	for {
+		out, ok := c.next()
+		if !ok {
 			return
 		}
+		now := time.Now()
+		atomic.StoreInt64(&c.writingSince, now.UnixNano())
+		c.ws.SetWriteDeadline(now.Add(5 * time.Second))
+		hdr = out.frame.AppendHeader(hdr[:0], out.ack)
+		n := len(hdr) + len(out.frame.Bytes())
+		err := c.writeFrame(hdr, out.frame.Bytes())
+		out.frame.Release()
+		atomic.StoreInt64(&c.writingSince, 0)
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(&c.Stats.MessagesSent, 1)
+		atomic.AddInt64(&c.Stats.BytesSent, int64(n))
 	}
 }
 
-// readPump 读取客户端输入，转换为 Input 注入房间
+// next 取下一条待写消息，可靠消息优先；两个队列都已关闭且为空时返回 false
+func (c *ClientConn) next() (outbound, bool) {
+	ctrl, send := c.ctrl, c.send
+	for ctrl != nil || send != nil {
+		select {
+		case out, ok := <-ctrl:
+			if ok {
+				return out, true
+			}
+			ctrl = nil
+			continue
+		default:
+		}
+		select {
+		case out, ok := <-ctrl:
+			if !ok {
+				ctrl = nil
+				continue
+			}
+			return out, true
+		case out, ok := <-send:
+			if !ok {
+				send = nil
+				continue
+			}
+			return out, true
+		}
+	}
+	return outbound{}, false
+}
+
+// writeFrame 以一条文本消息写出头部与共享主体，不拼接复制
+func (c *ClientConn) writeFrame(hdr, body []byte) error {
+	w, err := c.ws.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	if len(hdr) > 0 {
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// readPump 读取客户端消息并经消息路由分发到房间
 func (c *ClientConn) readPump(room *Room, playerID PlayerID) {
 	defer c.ws.Close()
-	// 读泵退出时，通知房间在 Tick 线程中移除该玩家
-	defer room.RequestLeave(playerID)
+	defer unregisterSession(c)
+	// 读泵退出时关闭 Done，房间随之在 Tick 线程中移除该玩家
+	defer close(c.gone)
 	c.ws.SetReadLimit(1 << 20) // 1MB
 	c.ws.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.ws.SetPongHandler(func(string) error { c.ws.SetReadDeadline(time.Now().Add(60 * time.Second)); return nil })
 
+	in := newInbound(room, playerID)
 	for {
 		_, payload, err := c.ws.ReadMessage()
 		if err != nil {
 			return
 		}
-		var im InputMessage
-		if err := json.Unmarshal(payload, &im); err != nil {
-			continue
-		}
-		if strings.ToLower(im.Type) != "move" {
-			continue
-		}
-		var dir Direction
-		switch strings.ToLower(im.Command) {
-		case "up":
-			dir = DirUp
-		case "down":
-			dir = DirDown
-		case "left":
-			dir = DirLeft
-		case "right":
-			dir = DirRight
-		default:
-			dir = DirNone
-		}
-		// 调试日志：观察输入是否被识别
-		// 示例：input player=alice type=move cmd=right seq=123 dir=DirRight
-		// 注意：线上应调整为更轻量的日志
-		// 使用标准 log 以避免额外依赖
-		if dir == DirNone {
-			Log.Debugf("input unrecognized: player=%s type=%s cmd=%s seq=%d", playerID, im.Type, im.Command, im.Seq)
-		} else {
-			Log.Debugf("input recv: player=%s type=%s cmd=%s seq=%d", playerID, im.Type, im.Command, im.Seq)
-		}
-		room.OnInput(Input{PlayerID: playerID, Command: dir, Seq: im.Seq})
+		in.dispatch(payload)
 	}
 }
 
@@ -108,15 +279,15 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// HandleWS WebSocket 接入：?room=room-1&player=alice
+// HandleWS WebSocket 接入：?room=room-1&player=alice[&team=red]
 func HandleWS(w http.ResponseWriter, r *http.Request) {
 	roomID := r.URL.Query().Get("room")
 	if roomID == "" {
 		roomID = "room-1"
 	}
-	playerID := r.URL.Query().Get("player")
-	if playerID == "" {
-		http.Error(w, "missing player query", http.StatusBadRequest)
+	playerID, err := authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -129,12 +300,20 @@ func HandleWS(w http.ResponseWriter, r *http.Request) {
 	rm := GetRoomManager()
 	room := rm.GetOrCreateRoom(roomID)
 
-	client := NewClientConn(ws)
-	room.JoinPlayer(PlayerID(playerID), client)
-
+	client := NewClientConn(ws, room, playerID)
+	registerSession(client)
 	go client.writePump()
-	go client.readPump(room, PlayerID(playerID))
 
-	// 初次连接/重连时，立即发送一次权威快照，便于客户端对齐并重演未确认输入
-	room.SendSnapshotTo(PlayerID(playerID))
+	// 加入与初始快照在 Tick 线程中完成
+	room.Attach(playerID, client, JoinRequest{Team: r.URL.Query().Get("team")})
+	go client.readPump(room, playerID)
+}
+
+// authenticate 识别请求方玩家（/ws 与档案接口共用）：演示环境以 player 参数标识身份，接入登录后在此校验令牌
+func authenticate(r *http.Request) (PlayerID, error) {
+	id := r.URL.Query().Get("player")
+	if id == "" {
+		return "", fmt.Errorf("missing player query")
+	}
+	return PlayerID(id), nil
 }
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClientConn 建立一条本地 WebSocket 连接并包装为 ClientConn（不启动读写协程）
+func testClientConn(t *testing.T, r *Room) (*ClientConn, *websocket.Conn) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ws, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		accepted <- ws
+	}))
+	t.Cleanup(srv.Close)
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	c := NewClientConn(<-accepted, r, "alice")
+	t.Cleanup(func() { _ = c.ws.Close() })
+	return c, client
+}
+
+func TestClientConnKeepsReliableFramesWhenQueueFull(t *testing.T) {
+	r := testRoom(t, nil)
+	c, client := testClientConn(t, r)
+	c.send = make(chan outbound, 2)
+	c.policy = SlowConsumerPolicy{}
+
+	state := frameFromBytes([]byte(`{"type":"state"}`))
+	defer state.Release()
+	for i := 0; i < 3; i++ {
+		c.Send(state, 0)
+	}
+	if !c.SnapshotOnly() {
+		t.Fatal("overflowing state frame should degrade the connection")
+	}
+	chat := controlFrame(map[string]string{"type": "chat"})
+	defer chat.Release()
+	if !c.Send(chat, 0) {
+		t.Fatal("reliable frame dropped on a full send queue")
+	}
+
+	// 可靠消息先于积压的状态流写出，关闭后剩余消息照常写完
+	c.CloseAfterFlush()
+	go c.writePump()
+	var got []string
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, b, err := client.ReadMessage()
+		if err != nil {
+			break
+		}
+		got = append(got, string(b))
+	}
+	if len(got) != 3 || !strings.Contains(got[0], `"chat"`) {
+		t.Fatalf("written frames = %q, want chat first then two states", got)
+	}
+}
+
+func TestClientConnEvictsWhenControlQueueFull(t *testing.T) {
+	r := testRoom(t, nil)
+	c, _ := testClientConn(t, r)
+	c.ctrl = make(chan outbound, 1)
+
+	chat := controlFrame(map[string]string{"type": "chat"})
+	defer chat.Release()
+	if !c.Send(chat, 0) {
+		t.Fatal("first reliable frame rejected")
+	}
+	if c.Send(chat, 0) || !c.evicted {
+		t.Fatalf("control queue overflow: evicted=%v, want eviction", c.evicted)
+	}
+}
+
+func TestSimulatedLossSparesReliableFrames(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	drain(t, s)
+	r.netDefault = NetConditions{Outbound: NetProfile{Loss: 1}}
+
+	r.runTick(time.Now()) // 状态流全部丢弃
+	chat := controlFrame(map[string]string{"type": "chat"})
+	r.send(r.Players["alice"], chat, false)
+	chat.Release()
+	r.runTick(time.Now())
+	msgs := drain(t, s)
+	if _, ok := lastOfType(msgs, "chat"); !ok {
+		t.Fatal("reliable frame lost to simulated outbound loss")
+	}
+	if _, ok := lastOfType(msgs, "state", "delta"); ok {
+		t.Fatal("state frame survived loss=1")
+	}
+}
+
+// closeCode 客户端收到的关闭码
+func closeCode(t *testing.T, client *websocket.Conn) int {
+	t.Helper()
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, _, err := client.ReadMessage(); err != nil {
+			if ce, ok := err.(*websocket.CloseError); ok {
+				return ce.Code
+			}
+			t.Fatalf("read: %v", err)
+		}
+	}
+}
+
+// fillQueue 将发送队列写满（写协程未启动，消息不会被取走）
+func fillQueue(c *ClientConn, f *Frame) {
+	for len(c.send) < cap(c.send) {
+		c.Send(f, 0)
+	}
+}
+
+func TestSlowConsumerEvictedAfterConsecutiveDrops(t *testing.T) {
+	r := testRoom(t, nil)
+	c, client := testClientConn(t, r)
+	c.send = make(chan outbound, 2)
+	c.policy = SlowConsumerPolicy{MaxConsecutiveDrops: 3}
+	state := frameFromBytes([]byte(`{"type":"state"}`))
+	defer state.Release()
+	fillQueue(c, state)
+
+	for i := 1; i <= 3; i++ {
+		if c.Send(state, 0) {
+			t.Fatal("send succeeded on a full queue")
+		}
+		if evicted := c.evicted; evicted != (i == 3) {
+			t.Fatalf("after %d drops evicted=%v", i, evicted)
+		}
+	}
+	if code := closeCode(t, client); code != CloseSlowConsumerDrops {
+		t.Fatalf("close code = %d, want %d", code, CloseSlowConsumerDrops)
+	}
+}
+
+func TestSlowConsumerEvictedAfterMaxDegraded(t *testing.T) {
+	r := testRoom(t, nil)
+	c, client := testClientConn(t, r)
+	c.send = make(chan outbound, 2)
+	c.policy = SlowConsumerPolicy{MaxDegradedMs: 50}
+	state := frameFromBytes([]byte(`{"type":"state"}`))
+	defer state.Release()
+	fillQueue(c, state)
+	c.Send(state, 0)
+	if !c.SnapshotOnly() || c.evicted {
+		t.Fatal("connection should be degraded but connected")
+	}
+
+	c.degradedSince = time.Now().Add(-100 * time.Millisecond)
+	if c.SendFull(state, 0) || !c.evicted {
+		t.Fatal("degraded connection not evicted after MaxDegradedMs")
+	}
+	if code := closeCode(t, client); code != CloseSlowConsumerDrops {
+		t.Fatalf("close code = %d, want %d", code, CloseSlowConsumerDrops)
+	}
+}
+
+func TestSlowConsumerEvictedOnWriteStall(t *testing.T) {
+	r := testRoom(t, nil)
+	c, client := testClientConn(t, r)
+	c.policy = SlowConsumerPolicy{WriteStallMs: 50}
+	state := frameFromBytes([]byte(`{"type":"state"}`))
+	defer state.Release()
+
+	// 模拟写协程卡在一次写操作上
+	atomic.StoreInt64(&c.writingSince, time.Now().Add(-100*time.Millisecond).UnixNano())
+	if c.Send(state, 0) || !c.evicted {
+		t.Fatal("stalled writer not evicted")
+	}
+	if code := closeCode(t, client); code != CloseSlowConsumerStall {
+		t.Fatalf("close code = %d, want %d", code, CloseSlowConsumerStall)
+	}
+}
+
+func TestSlowConsumerRecoversAtLowWatermark(t *testing.T) {
+	r := testRoom(t, nil)
+	c, _ := testClientConn(t, r)
+	c.send = make(chan outbound, 8)
+	c.policy = SlowConsumerPolicy{}
+	state := frameFromBytes([]byte(`{"type":"state"}`))
+	defer state.Release()
+	fillQueue(c, state)
+	c.Send(state, 0)
+	if !c.SnapshotOnly() {
+		t.Fatal("connection not degraded")
+	}
+
+	// 队列高于低水位（容量的 1/4）时不发全量
+	for len(c.send) > 3 {
+		(<-c.send).frame.Release()
+	}
+	if c.SendFull(state, 0) || !c.SnapshotOnly() {
+		t.Fatal("full state sent above the low watermark")
+	}
+	(<-c.send).frame.Release()
+	if !c.SendFull(state, 0) || c.SnapshotOnly() {
+		t.Fatal("connection did not recover at the low watermark")
+	}
+	if c.dropStreak != 0 {
+		t.Fatalf("drop streak = %d after recovery", c.dropStreak)
+	}
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// NetProfile 单方向的网络条件（全部为 0 表示直通）
+type NetProfile struct {
+	LatencyMs     int     `json:"latencyMs"`     // 固定延迟
+	JitterMs      int     `json:"jitterMs"`      // 额外随机延迟 [0, JitterMs]
+	Loss          float64 `json:"loss"`          // 丢包率（0~1）
+	Duplicate     float64 `json:"duplicate"`     // 重复率（0~1）
+	BandwidthKbps int     `json:"bandwidthKbps"` // 带宽上限，0 表示不限
+}
+
+// NetConditions 双向网络条件：Inbound 为客户端 → 房间，Outbound 为房间 → 客户端
+type NetConditions struct {
+	Inbound  NetProfile `json:"inbound"`
+	Outbound NetProfile `json:"outbound"`
+}
+
+func (p NetProfile) passthrough() bool { return p == NetProfile{} }
+
+func (p NetProfile) validate() error {
+	if p.LatencyMs < 0 || p.JitterMs < 0 || p.BandwidthKbps < 0 {
+		return fmt.Errorf("latency, jitter and bandwidth must be non-negative")
+	}
+	if p.Loss < 0 || p.Loss > 1 || p.Duplicate < 0 || p.Duplicate > 1 {
+		return fmt.Errorf("loss and duplicate must be within [0,1]")
+	}
+	return nil
+}
+
+// Validate 检查双向参数
+func (c NetConditions) Validate() error {
+	if err := c.Inbound.validate(); err != nil {
+		return fmt.Errorf("inbound: %w", err)
+	}
+	if err := c.Outbound.validate(); err != nil {
+		return fmt.Errorf("outbound: %w", err)
+	}
+	return nil
+}
+
+// inputSize 未携带原始长度的输入按该字节数计入带宽
+const inputSize = 48
+
+type delayedInput struct {
+	at int64 // 释放 Tick
+	in Input
+}
+
+type delayedFrame struct {
+	at    int64
+	frame *Frame
+	ack   int64
+	full  bool
+}
+
+// conditioner 单个玩家的网络条件模拟层，位于连接与房间之间
+// 仅由 Tick 线程访问；延迟以 Tick 为单位调度，同方向按入队顺序释放（不乱序）
+type conditioner struct {
+	inQ                 []delayedInput
+	out                 []delayedFrame
+	inLast, outLast     int64 // 最近一次安排的释放 Tick，保证单调
+	inCredit, outCredit int   // 带宽额度（字节）
+}
+
+// netFor 返回玩家当前生效的网络条件（玩家覆盖优先于房间默认）
+func (r *Room) netFor(pid PlayerID) NetConditions {
+	if c, ok := r.netOverrides[pid]; ok {
+		return c
+	}
+	return r.netDefault
+}
+
+// scheduleAt 计算释放 Tick：延迟 + 抖动换算为帧数，且不早于上一次
+func (r *Room) scheduleAt(p NetProfile, last *int64) int64 {
+	delay := p.LatencyMs
+	if p.JitterMs > 0 {
+		delay += r.rng.Intn(p.JitterMs + 1)
+	}
+	at := r.tickSeq
+	if delay > 0 {
+		at += int64((time.Duration(delay)*time.Millisecond + r.TickInterval() - 1) / r.TickInterval())
+	}
+	if at < *last {
+		at = *last
+	}
+	*last = at
+	return at
+}
+
+// bandwidthPerTick 每 Tick 可用字节数；0 表示不限
+func (r *Room) bandwidthPerTick(p NetProfile) int {
+	if p.BandwidthKbps <= 0 {
+		return 0
+	}
+	n := p.BandwidthKbps * 125 / r.TickRate()
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// conditionInput 入站输入经过玩家的网络条件：丢弃、重复或排队到释放 Tick
+func (r *Room) conditionInput(p *Player, in Input) {
+	prof := r.netFor(p.ID).Inbound
+	if prof.passthrough() {
+		p.net.inQ = append(p.net.inQ, delayedInput{at: r.tickSeq, in: in})
+		return
+	}
+	if prof.Loss > 0 && r.rng.Float64() < prof.Loss {
+		// 丢弃该条输入，模拟丢包（调试）
+		Log.Debugf("drop input: player=%s seq=%d", string(in.PlayerID), in.Seq)
+		r.metrics.IncDropsSimulated()
+		return
+	}
+	copies := 1
+	if prof.Duplicate > 0 && r.rng.Float64() < prof.Duplicate {
+		copies = 2
+		r.metrics.IncDuplicatesSimulated()
+	}
+	for i := 0; i < copies; i++ {
+		p.net.inQ = append(p.net.inQ, delayedInput{at: r.scheduleAt(prof, &p.net.inLast), in: in})
+	}
+}
+
+// releaseInputs 释放本 Tick 到期且带宽允许的入站输入
+func (r *Room) releaseInputs(p *Player, apply func(*Player, Input)) {
+	q := p.net.inQ
+	if len(q) == 0 {
+		return
+	}
+	perTick := r.bandwidthPerTick(r.netFor(p.ID).Inbound)
+	p.net.inCredit = refill(p.net.inCredit, perTick)
+	n := 0
+	for n < len(q) && q[n].at <= r.tickSeq {
+		if perTick > 0 && !spend(&p.net.inCredit, inputSize, perTick) {
+			break
+		}
+		apply(p, q[n].in)
+		n++
+	}
+	p.net.inQ = compactInputs(q, n)
+}
+
+// lossAware 需要感知模拟出站丢包的会话（如 UDP 状态流据此留出序号缺口）
+type lossAware interface {
+	simulatedLoss()
+}
+
+// send 出站消息经过接收者的网络条件；full 表示全量消息（慢消费者恢复用）
+func (r *Room) send(p *Player, f *Frame, full bool) {
+	if p.Conn == nil {
+		return
+	}
+	ack := r.lastSeqProcessed[p.ID]
+	prof := r.netFor(p.ID).Outbound
+	if prof.passthrough() && len(p.net.out) == 0 {
+		r.deliver(p, f, ack, full)
+		return
+	}
+	// 可靠消息（聊天、阶段、对局结果等）不参与模拟丢包，只受延迟与带宽影响
+	if !f.Reliable() && prof.Loss > 0 && r.rng.Float64() < prof.Loss {
+		r.metrics.IncOutboundDropsSimulated()
+		if la, ok := p.Conn.(lossAware); ok {
+			la.simulatedLoss()
+		}
+		return
+	}
+	copies := 1
+	if prof.Duplicate > 0 && r.rng.Float64() < prof.Duplicate {
+		copies = 2
+		r.metrics.IncDuplicatesSimulated()
+	}
+	for i := 0; i < copies; i++ {
+		f.Retain()
+		p.net.out = append(p.net.out, delayedFrame{at: r.scheduleAt(prof, &p.net.outLast), frame: f, ack: ack, full: full})
+	}
+}
+
+func (r *Room) deliver(p *Player, f *Frame, ack int64, full bool) {
+	if full {
+		p.Conn.SendFull(f, ack)
+	} else {
+		p.Conn.Send(f, ack)
+	}
+}
+
+// flushOutbound 在 Tick 末尾释放所有玩家到期的出站消息
+func (r *Room) flushOutbound() {
+	for _, p := range r.Players {
+		q := p.net.out
+		if len(q) == 0 {
+			continue
+		}
+		perTick := r.bandwidthPerTick(r.netFor(p.ID).Outbound)
+		p.net.outCredit = refill(p.net.outCredit, perTick)
+		n := 0
+		for n < len(q) && q[n].at <= r.tickSeq {
+			if perTick > 0 && !spend(&p.net.outCredit, len(q[n].frame.Bytes()), perTick) {
+				break
+			}
+			if p.Conn != nil {
+				r.deliver(p, q[n].frame, q[n].ack, q[n].full)
+			}
+			q[n].frame.Release()
+			n++
+		}
+		rest := copy(q, q[n:])
+		clear(q[rest:])
+		p.net.out = q[:rest]
+	}
+}
+
+// dropConditioned 玩家离开时丢弃其排队中的出站消息
+func (p *Player) dropConditioned() {
+	for _, d := range p.net.out {
+		d.frame.Release()
+	}
+	p.net.out = nil
+	p.net.inQ = nil
+}
+
+// refill 每 Tick 补充带宽额度，最多累积 4 个 Tick 的突发
+func refill(credit, perTick int) int {
+	if perTick <= 0 {
+		return 0
+	}
+	credit += perTick
+	if credit > 4*perTick {
+		credit = 4 * perTick
+	}
+	return credit
+}
+
+// spend 扣除额度；额度已满但仍不足（单条消息超大）时允许透支，避免永久阻塞
+func spend(credit *int, size, perTick int) bool {
+	if *credit >= size || *credit >= 4*perTick {
+		*credit -= size
+		return true
+	}
+	return false
+}
+
+func compactInputs(q []delayedInput, n int) []delayedInput {
+	rest := copy(q, q[n:])
+	return q[:rest]
+}
+
+// SetNetDefault 修改房间默认网络条件（可选重设随机种子，便于复现）
+func (r *Room) SetNetDefault(c NetConditions, seed *int64) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	r.Do(func() {
+		r.netDefault = c
+		if seed != nil {
+			r.rng = newRoomRand(*seed)
+		}
+		Log.Infof("net default updated: room=%s net=%+v", r.ID, c)
+	})
+	return nil
+}
+
+// SetPlayerNet 为单个玩家设置网络条件；c 为 nil 时恢复房间默认
+func (r *Room) SetPlayerNet(pid PlayerID, c *NetConditions) error {
+	if c != nil {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	r.Do(func() {
+		if c == nil {
+			delete(r.netOverrides, pid)
+			Log.Infof("player net reset: room=%s player=%s", r.ID, pid)
+			return
+		}
+		r.netOverrides[pid] = *c
+		Log.Infof("player net updated: room=%s player=%s net=%+v", r.ID, pid, *c)
+	})
+	return nil
+}
+
+// newRoomRand 按种子创建随机源；0 表示按时间取种子
+func newRoomRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// netRoom 10 TPS（100ms = 1 Tick）、以固定种子设置默认网络条件的测试房间
+func netRoom(t *testing.T, c NetConditions, seed int64) *Room {
+	t.Helper()
+	r := testRoom(t, func(p *RoomProfile) { p.TicksPerSecond = 10 })
+	if err := r.SetNetDefault(c, &seed); err != nil {
+		t.Fatal(err)
+	}
+	r.runTick(time.Now())
+	return r
+}
+
+func TestScheduleAtRoundsUpAndStaysMonotonic(t *testing.T) {
+	r := netRoom(t, NetConditions{}, 1)
+	var last int64
+	if at := r.scheduleAt(NetProfile{LatencyMs: 100}, &last); at != r.tickSeq+1 {
+		t.Fatalf("100ms at 10 TPS = tick %d, want %d", at, r.tickSeq+1)
+	}
+	if at := r.scheduleAt(NetProfile{LatencyMs: 101}, &last); at != r.tickSeq+2 {
+		t.Fatalf("101ms at 10 TPS = tick %d, want %d (rounded up)", at, r.tickSeq+2)
+	}
+	// 延迟变短也不早于上一次安排，保证同方向不乱序
+	if at := r.scheduleAt(NetProfile{}, &last); at != r.tickSeq+2 {
+		t.Fatalf("zero latency after 200ms = tick %d, want %d", at, r.tickSeq+2)
+	}
+
+	jitter := NetProfile{LatencyMs: 100, JitterMs: 500}
+	seq := func(seed int64) []int64 {
+		r.rng = newRoomRand(seed)
+		var last int64
+		var out []int64
+		for i := 0; i < 50; i++ {
+			at := r.scheduleAt(jitter, &last)
+			if at < r.tickSeq+1 || at > r.tickSeq+6 {
+				t.Fatalf("jittered release tick %d outside [+1, +6]", at-r.tickSeq)
+			}
+			if len(out) > 0 && at < out[len(out)-1] {
+				t.Fatalf("release ticks not monotonic: %v", append(out, at))
+			}
+			out = append(out, at)
+		}
+		return out
+	}
+	a, b := seq(42), seq(42)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed produced different schedules: %v vs %v", a, b)
+		}
+	}
+}
+
+func TestInboundLatencyDelaysInput(t *testing.T) {
+	r := netRoom(t, NetConditions{Inbound: NetProfile{LatencyMs: 200}}, 1)
+	s := ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	drain(t, s)
+
+	s.Input(r, DirRight, 1)
+	for i := 1; i <= 3; i++ {
+		r.runTick(time.Now())
+		msg, _ := lastOfType(drain(t, s), "state", "delta")
+		if applied := msg.Ack == 1; applied != (i == 3) {
+			t.Fatalf("tick %d after input: ack=%d", i, msg.Ack)
+		}
+	}
+}
+
+func TestOutboundDuplicateDeliversTwice(t *testing.T) {
+	r := netRoom(t, NetConditions{Outbound: NetProfile{Duplicate: 1}}, 1)
+	s := ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	drain(t, s)
+
+	r.runTick(time.Now())
+	n := 0
+	for _, m := range drain(t, s) {
+		if m.Type == "state" || m.Type == "delta" {
+			n++
+		}
+	}
+	if n != 2 {
+		t.Fatalf("got %d state messages in one tick, want 2", n)
+	}
+}
+
+func TestRefillAndSpend(t *testing.T) {
+	credit := 0
+	for i := 0; i < 10; i++ {
+		credit = refill(credit, 100)
+	}
+	if credit != 400 {
+		t.Fatalf("credit after idle ticks = %d, want burst cap 400", credit)
+	}
+	if refill(50, 0) != 0 {
+		t.Fatal("unlimited bandwidth should not accumulate credit")
+	}
+
+	credit = 150
+	if !spend(&credit, 100, 100) || credit != 50 {
+		t.Fatalf("spend within credit: credit=%d", credit)
+	}
+	if spend(&credit, 100, 100) || credit != 50 {
+		t.Fatalf("spend beyond credit should wait: credit=%d", credit)
+	}
+	// 额度已满仍不足：允许透支，超大消息不会永久阻塞
+	credit = 400
+	if !spend(&credit, 1000, 100) || credit != -600 {
+		t.Fatalf("overdraft: credit=%d, want -600", credit)
+	}
+	for i := 0; i < 6; i++ {
+		credit = refill(credit, 100)
+	}
+	if credit != 0 || spend(&credit, 1, 100) {
+		t.Fatalf("credit after repaying overdraft = %d", credit)
+	}
+}
+
+// paddedFrame 长度恰为 size 字节的非可靠测试帧，n 为其编号
+func paddedFrame(n, size int) *Frame {
+	b := []byte(`{"type":"state","n":` + strconv.Itoa(n) + `,"pad":"`)
+	for len(b) < size-2 {
+		b = append(b, ' ')
+	}
+	return frameFromBytes(append(b, '"', '}'))
+}
+
+// receivedNumbers 会话收到的测试帧编号（按到达顺序）
+func receivedNumbers(t *testing.T, s *MemSession) []int {
+	t.Helper()
+	var out []int
+	for {
+		select {
+		case b := <-s.Recv():
+			var m struct {
+				N *int `json:"n"`
+			}
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("invalid message %s: %v", b, err)
+			}
+			if m.N != nil {
+				out = append(out, *m.N)
+			}
+		default:
+			return out
+		}
+	}
+}
+
+func TestBandwidthDefersInOrder(t *testing.T) {
+	// 8 kbps 在 10 TPS 下每 Tick 100 字节
+	r := netRoom(t, NetConditions{Outbound: NetProfile{BandwidthKbps: 8}}, 1)
+	s := ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	drain(t, s)
+
+	p := r.Players["alice"]
+	p.dropConditioned() // 丢弃此前受限排队的广播
+	p.net.outCredit = 0
+	for i := 0; i < 5; i++ {
+		f := paddedFrame(i, 60)
+		r.send(p, f, false)
+		f.Release()
+	}
+	// 额度 100 → 40 → 140 → 20 → 120 → 0：每次释放 1、2、2 条
+	var got []int
+	for _, want := range []int{1, 2, 2} {
+		r.flushOutbound()
+		n := receivedNumbers(t, s)
+		if len(n) != want {
+			t.Fatalf("flush delivered %v, want %d frames", n, want)
+		}
+		got = append(got, n...)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("delivery order = %v, want 0..4", got)
+		}
+	}
+}
+
+func TestSimulatedLossIsSeededAndSparesReliable(t *testing.T) {
+	run := func() (reliable, states int) {
+		r := netRoom(t, NetConditions{Outbound: NetProfile{Loss: 0.5}}, 7)
+		s := ConnectMem(r, "alice", 256)
+		r.runTick(time.Now())
+		drain(t, s)
+		p := r.Players["alice"]
+		for i := 0; i < 40; i++ {
+			f := paddedFrame(i, 40)
+			if i%2 == 0 {
+				f.reliable = true
+			}
+			r.send(p, f, false)
+			f.Release()
+		}
+		r.flushOutbound()
+		for _, n := range receivedNumbers(t, s) {
+			if n%2 == 0 {
+				reliable++
+			} else {
+				states++
+			}
+		}
+		return reliable, states
+	}
+	reliable, states := run()
+	if reliable != 20 {
+		t.Fatalf("delivered %d of 20 reliable frames under loss", reliable)
+	}
+	if states == 0 || states == 20 {
+		t.Fatalf("delivered %d of 20 state frames at loss=0.5", states)
+	}
+	if _, again := run(); again != states {
+		t.Fatalf("same seed delivered %d then %d state frames", states, again)
+	}
+}
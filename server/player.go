@@ -16,9 +16,10 @@ const (
 
 // PlayerState 为广播给客户端的轻量状态
 type PlayerState struct {
-    ID string  `json:"id"`
-    X  float64 `json:"x"`
-    Y  float64 `json:"y"`
+    ID   string  `json:"id"`
+    X    float64 `json:"x"`
+    Y    float64 `json:"y"`
+    Team string  `json:"team,omitempty"`
 }
 
 // Player 房间内的玩家实体（服务端权威状态）
@@ -27,7 +28,14 @@ type Player struct {
     X   float64
     Y   float64
     Dir Direction // 当前意图方向，在下一次 Tick 生效
+    Team string   // 所属队伍，房间未配置队伍时为空
+    Name string   // 显示名（加入时从玩家档案读取）
 
-    Conn *ClientConn // 网络连接的发送端（写协程）
+    Conn Session     // 客户端会话（传输无关），为 nil 表示无连接
+    net  conditioner // 网络条件模拟队列（Tick 线程访问）
 }
 
+// state 当前广播用状态
+func (p *Player) state() PlayerState {
+    return PlayerState{ID: string(p.ID), X: p.X, Y: p.Y, Team: p.Team}
+}
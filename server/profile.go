@@ -0,0 +1,412 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ProfileStoreConfig 玩家档案存储参数（进程级）
+type ProfileStoreConfig struct {
+	File    string `json:"file"`    // JSON 文件，为空则仅保存在内存
+	FlushMs int    `json:"flushMs"` // 有修改时写回文件的间隔
+}
+
+// LifetimeStats 玩家生涯统计（每局结算时累加）
+type LifetimeStats struct {
+	Matches        int            `json:"matches"`
+	Wins           int            `json:"wins"` // 名次第一（分队时为队伍第一）
+	Score          int            `json:"score"`
+	Distance       float64        `json:"distance"`
+	InputsAccepted int64          `json:"inputsAccepted"`
+	InputsLimited  int64          `json:"inputsLimited"`
+	PlayMs         int64          `json:"playMs"`
+	Mode           map[string]int `json:"mode,omitempty"`
+}
+
+// PlayerProfile 玩家持久档案
+type PlayerProfile struct {
+	ID          PlayerID          `json:"id"`
+	DisplayName string            `json:"displayName"`
+	Cosmetics   map[string]string `json:"cosmetics,omitempty"` // 外观设置，如 color、hat（服务端不解释）
+	Lifetime    LifetimeStats     `json:"lifetime"`
+	Rating      *Rating           `json:"rating,omitempty"` // 未参加过计分对局时为空
+	CreatedAt   time.Time         `json:"createdAt"`
+	LastSeen    time.Time         `json:"lastSeen"`
+}
+
+func (p *PlayerProfile) clone() PlayerProfile {
+	c := *p
+	c.Cosmetics = maps.Clone(p.Cosmetics)
+	c.Lifetime.Mode = maps.Clone(p.Lifetime.Mode)
+	if p.Rating != nil {
+		r := *p.Rating
+		r.History = slices.Clone(p.Rating.History)
+		c.Rating = &r
+	}
+	return c
+}
+
+// PlayerStore 玩家档案存储：房间在加入、离开与结算时读写（Tick 线程，不得阻塞），HTTP 接口查询与修改
+type PlayerStore interface {
+	// Get 读取档案拷贝
+	Get(id PlayerID) (PlayerProfile, bool)
+	// Update 修改档案（不存在时先创建），返回修改后的拷贝；fn 返回错误时放弃修改
+	Update(id PlayerID, fn func(*PlayerProfile) error) (PlayerProfile, error)
+	// Leaderboard 有评分的玩家按评分降序分页，返回该页与总数
+	Leaderboard(offset, limit int) (page []PlayerProfile, total int)
+	Close() error
+}
+
+// fileStore 内存保存全部档案，有修改时由后台协程定期整体写回 JSON 文件（先写临时文件再替换）
+//
+// 档案写入时整体替换指针、不原地修改，写回时只需在锁内浅拷贝 map，编码与写文件在锁外进行
+type fileStore struct {
+	mu       sync.Mutex
+	profiles map[PlayerID]*PlayerProfile
+	ranked   []*PlayerProfile // 有评分的档案，按 rankBefore 排序，Update 时增量维护
+	dirty    bool
+
+	saveMu sync.Mutex // 串行化写回，避免并发写同一临时文件
+	path   string
+	flush  time.Duration
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// OpenPlayerStore 按配置创建档案存储；配置了文件时加载已有档案
+func OpenPlayerStore(cfg ProfileStoreConfig) (PlayerStore, error) {
+	s := &fileStore{profiles: make(map[PlayerID]*PlayerProfile), path: cfg.File}
+	if cfg.File == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(cfg.File)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("read profiles: %w", err)
+	default:
+		if err := json.Unmarshal(b, &s.profiles); err != nil {
+			return nil, fmt.Errorf("parse profiles %s: %w", cfg.File, err)
+		}
+	}
+	for _, p := range s.profiles {
+		if p.Rating != nil {
+			s.ranked = append(s.ranked, p)
+		}
+	}
+	sort.Slice(s.ranked, func(i, j int) bool { return rankBefore(s.ranked[i], s.ranked[j]) })
+	Log.Infof("profiles loaded: file=%s players=%d", cfg.File, len(s.profiles))
+	s.flush = time.Duration(cfg.FlushMs) * time.Millisecond
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.flushLoop()
+	return s, nil
+}
+
+func (s *fileStore) Get(id PlayerID) (PlayerProfile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.profiles[id]
+	if !ok {
+		return PlayerProfile{}, false
+	}
+	return p.clone(), true
+}
+
+func (s *fileStore) Update(id PlayerID, fn func(*PlayerProfile) error) (PlayerProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.profiles[id]
+	if !ok {
+		cur = &PlayerProfile{ID: id, DisplayName: string(id), CreatedAt: time.Now()}
+	}
+	next := cur.clone()
+	if err := fn(&next); err != nil {
+		return cur.clone(), err
+	}
+	if ok && cur.Rating != nil {
+		s.unrank(cur)
+	}
+	s.profiles[id] = &next
+	if next.Rating != nil {
+		s.rank(&next)
+	}
+	s.dirty = true
+	return next.clone(), nil
+}
+
+func (s *fileStore) Leaderboard(offset, limit int) ([]PlayerProfile, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var page []PlayerProfile
+	for i := max(offset, 0); i < len(s.ranked) && len(page) < limit; i++ {
+		page = append(page, s.ranked[i].clone())
+	}
+	return page, len(s.ranked)
+}
+
+// rankBefore 排行榜顺序：评分降序，同分按 ID 升序
+func rankBefore(a, b *PlayerProfile) bool {
+	if a.Rating.Rating != b.Rating.Rating {
+		return a.Rating.Rating > b.Rating.Rating
+	}
+	return a.ID < b.ID
+}
+
+// rank 将有评分的档案插入排行索引（持有 mu）
+func (s *fileStore) rank(p *PlayerProfile) {
+	i := sort.Search(len(s.ranked), func(i int) bool { return !rankBefore(s.ranked[i], p) })
+	s.ranked = slices.Insert(s.ranked, i, p)
+}
+
+// unrank 从排行索引移除档案（持有 mu）
+func (s *fileStore) unrank(p *PlayerProfile) {
+	i := sort.Search(len(s.ranked), func(i int) bool { return !rankBefore(s.ranked[i], p) })
+	if i < len(s.ranked) && s.ranked[i] == p {
+		s.ranked = slices.Delete(s.ranked, i, i+1)
+	}
+}
+
+func (s *fileStore) flushLoop() {
+	defer close(s.done)
+	t := time.NewTicker(s.flush)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := s.save(); err != nil {
+				Log.Errorf("profiles save failed: file=%s err=%v", s.path, err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// save 有修改时整体写回文件；失败时保留修改标记，下一次写回重试
+func (s *fileStore) save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	profiles := maps.Clone(s.profiles)
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := s.write(profiles); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) write(profiles map[PlayerID]*PlayerProfile) error {
+	b, err := json.Marshal(profiles)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Close 停止后台写回并保存最后的修改
+func (s *fileStore) Close() error {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	<-s.done
+	return s.save()
+}
+
+var (
+	playerStoreMu sync.RWMutex
+	playerStore   PlayerStore = &fileStore{profiles: make(map[PlayerID]*PlayerProfile)}
+)
+
+// SetPlayerStore 替换全局玩家档案存储（启动时调用）
+func SetPlayerStore(s PlayerStore) {
+	playerStoreMu.Lock()
+	playerStore = s
+	playerStoreMu.Unlock()
+}
+
+// GetPlayerStore 全局玩家档案存储；未设置时为仅内存存储
+func GetPlayerStore() PlayerStore {
+	playerStoreMu.RLock()
+	defer playerStoreMu.RUnlock()
+	return playerStore
+}
+
+// touchProfile 玩家加入或离开时刷新最近在线时间，返回显示名
+func touchProfile(id PlayerID) string {
+	p, err := GetPlayerStore().Update(id, func(p *PlayerProfile) error {
+		p.LastSeen = time.Now()
+		return nil
+	})
+	if err != nil {
+		Log.Warnf("profile update failed: player=%s err=%v", id, err)
+		return string(id)
+	}
+	return p.DisplayName
+}
+
+// recordLifetime 将一局结算累加到参与者的生涯统计（Tick 线程）
+func recordLifetime(res *MatchResult) {
+	teamWin := make(map[string]bool, len(res.Teams))
+	for _, t := range res.Teams {
+		teamWin[t.ID] = t.Rank == 1
+	}
+	for _, s := range res.Players {
+		won := s.Rank == 1
+		if len(res.Teams) > 0 {
+			won = teamWin[s.Team]
+		}
+		_, err := GetPlayerStore().Update(s.ID, func(p *PlayerProfile) error {
+			l := &p.Lifetime
+			l.Matches++
+			if won {
+				l.Wins++
+			}
+			l.Score += s.Score
+			if st := s.Stats; st != nil {
+				l.Distance += st.Distance
+				l.InputsAccepted += st.InputsAccepted
+				l.InputsLimited += st.InputsLimited
+				l.PlayMs += st.AliveMs
+				for k, v := range st.Mode {
+					if l.Mode == nil {
+						l.Mode = make(map[string]int)
+					}
+					l.Mode[k] += v
+				}
+			}
+			p.LastSeen = res.EndedAt
+			return nil
+		})
+		if err != nil {
+			Log.Warnf("lifetime stats update failed: player=%s match=%s err=%v", s.ID, res.ID, err)
+		}
+	}
+}
+
+// 档案可修改字段的限制
+const (
+	maxDisplayNameLen = 32
+	maxCosmetics      = 16
+	maxCosmeticLen    = 64
+)
+
+// profilePatch PATCH 请求体：省略的字段不修改；cosmetics 中值为 null 的键被删除
+type profilePatch struct {
+	DisplayName *string            `json:"displayName"`
+	Cosmetics   map[string]*string `json:"cosmetics"`
+}
+
+func (pp profilePatch) apply(p *PlayerProfile) error {
+	if pp.DisplayName != nil {
+		name := strings.TrimSpace(*pp.DisplayName)
+		if name == "" || utf8.RuneCountInString(name) > maxDisplayNameLen {
+			return fmt.Errorf("displayName must be 1-%d characters", maxDisplayNameLen)
+		}
+		if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return fmt.Errorf("displayName contains control characters")
+		}
+		p.DisplayName = name
+	}
+	for k, v := range pp.Cosmetics {
+		if v == nil {
+			delete(p.Cosmetics, k)
+			continue
+		}
+		if k == "" || len(k) > maxCosmeticLen || len(*v) > maxCosmeticLen {
+			return fmt.Errorf("cosmetic keys and values must be 1-%d bytes", maxCosmeticLen)
+		}
+		if p.Cosmetics == nil {
+			p.Cosmetics = make(map[string]string)
+		}
+		p.Cosmetics[k] = *v
+	}
+	if len(p.Cosmetics) > maxCosmetics {
+		return fmt.Errorf("at most %d cosmetics", maxCosmetics)
+	}
+	return nil
+}
+
+// HandleProfiles 玩家档案（鉴权同 /ws）
+//
+//	GET   /profiles/{id}  读取档案
+//	PATCH /profiles/{id}  修改自己的显示名与外观：{"displayName":"..","cosmetics":{"color":"#e53935"}}
+func HandleProfiles(w http.ResponseWriter, r *http.Request) {
+	caller, err := authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	id := PlayerID(strings.Trim(strings.TrimPrefix(r.URL.Path, "/profiles"), "/"))
+	if id == "" || strings.Contains(string(id), "/") {
+		http.NotFound(w, r)
+		return
+	}
+	store := GetPlayerStore()
+	var prof PlayerProfile
+	switch r.Method {
+	case http.MethodGet:
+		var ok bool
+		if prof, ok = store.Get(id); !ok {
+			http.Error(w, "profile not found", http.StatusNotFound)
+			return
+		}
+	case http.MethodPatch:
+		if id != caller {
+			http.Error(w, "can only modify own profile", http.StatusForbidden)
+			return
+		}
+		var patch profilePatch
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&patch); err != nil {
+			http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if prof, err = store.Update(id, patch.apply); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		Log.Infof("profile updated: player=%s name=%s", id, prof.DisplayName)
+		renameOnline(id, prof.DisplayName)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prof)
+}
+
+// renameOnline 将新的显示名同步给玩家所在的房间
+func renameOnline(id PlayerID, name string) {
+	for _, room := range GetRoomManager().Rooms() {
+		room := room
+		room.Do(func() {
+			if p, ok := room.Players[id]; ok {
+				p.Name = name
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setRating(s PlayerStore, id PlayerID, rating float64) {
+	_, _ = s.Update(id, func(p *PlayerProfile) error {
+		p.Rating = &Rating{Rating: rating}
+		return nil
+	})
+}
+
+func TestLeaderboardOrderFollowsUpdates(t *testing.T) {
+	s := &fileStore{profiles: make(map[PlayerID]*PlayerProfile)}
+	setRating(s, "alice", 1500)
+	setRating(s, "bob", 1600)
+	setRating(s, "carol", 1500)
+	_, _ = s.Update("dave", func(*PlayerProfile) error { return nil }) // 无评分不上榜
+	setRating(s, "alice", 1700)
+
+	page, total := s.Leaderboard(0, 10)
+	if total != 3 {
+		t.Fatalf("total = %d, want 3", total)
+	}
+	var ids []PlayerID
+	for _, p := range page {
+		ids = append(ids, p.ID)
+	}
+	want := []PlayerID{"alice", "bob", "carol"}
+	for i := range want {
+		if i >= len(ids) || ids[i] != want[i] {
+			t.Fatalf("leaderboard = %v, want %v", ids, want)
+		}
+	}
+	if page, _ := s.Leaderboard(1, 1); len(page) != 1 || page[0].ID != "bob" {
+		t.Fatalf("page (1,1) = %+v, want bob", page)
+	}
+}
+
+func TestProfileSaveRetriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	s := &fileStore{profiles: make(map[PlayerID]*PlayerProfile), path: filepath.Join(dir, "missing", "profiles.json")}
+	setRating(s, "alice", 1500)
+	if err := s.save(); err == nil {
+		t.Fatal("save into a missing directory succeeded")
+	}
+	if !s.dirty {
+		t.Fatal("dirty cleared after failed save")
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "missing"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := OpenPlayerStore(ProfileStoreConfig{File: s.path, FlushMs: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if page, total := loaded.Leaderboard(0, 10); total != 1 || page[0].ID != "alice" {
+		t.Fatalf("reloaded leaderboard = %+v (total %d)", page, total)
+	}
+}
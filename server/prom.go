@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Histogram 固定桶的并发安全直方图（Prometheus 语义：桶为累计 le）
+type Histogram struct {
+	bounds []float64
+	counts []uint64 // 与 bounds 对应的非累计计数，最后一个为 +Inf
+	sum    uint64   // float64 bits
+	count  uint64
+}
+
+// NewHistogram 以升序上界创建直方图
+func NewHistogram(bounds ...float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	for {
+		old := atomic.LoadUint64(&h.sum)
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sum, old, next) {
+			return
+		}
+	}
+}
+
+// Count 返回观测次数
+func (h *Histogram) Count() uint64 { return atomic.LoadUint64(&h.count) }
+
+// Sum 返回观测值之和
+func (h *Histogram) Sum() float64 { return math.Float64frombits(atomic.LoadUint64(&h.sum)) }
+
+// 常用桶：时长（秒）与字节数
+var (
+	durationBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5}
+	bytesBuckets    = []float64{64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536}
+)
+
+// promWriter 生成 Prometheus 文本格式（exposition format 0.0.4）
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+// family 写出指标族的 HELP 与 TYPE
+func (p *promWriter) family(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 写出一个样本；labels 为 k1,v1,k2,v2... 形式
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	p.printf("%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+// histogram 写出直方图的 bucket/sum/count 样本
+func (p *promWriter) histogram(name string, h *Histogram, labels ...string) {
+	var cum uint64
+	for i, b := range h.bounds {
+		cum += atomic.LoadUint64(&h.counts[i])
+		p.sample(name+"_bucket", float64(cum), append(labels, "le", formatValue(b))...)
+	}
+	cum += atomic.LoadUint64(&h.counts[len(h.bounds)])
+	p.sample(name+"_bucket", float64(cum), append(labels, "le", "+Inf")...)
+	p.sample(name+"_sum", h.Sum(), labels...)
+	p.sample(name+"_count", float64(cum), labels...)
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(labels[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Glicko-2 参数：新玩家 1500 ± 350，每局为一个评级周期
+const (
+	ratingInitial    = 1500.0
+	rdInitial        = 350.0
+	volatilityInit   = 0.06
+	glickoTau        = 0.5      // 波动率变化约束
+	glickoScale      = 173.7178 // Glicko ↔ Glicko-2 刻度换算
+	glickoEpsilon    = 1e-6
+	ratingHistoryMax = 50 // 每名玩家保留的最近评分变化
+)
+
+// Rating 玩家技术评分（Glicko-2）
+type Rating struct {
+	Rating     float64       `json:"rating"`
+	RD         float64       `json:"rd"` // 评分偏差，越小越可信
+	Volatility float64       `json:"volatility"`
+	Matches    int           `json:"matches"` // 计分对局数
+	History    []RatingPoint `json:"history,omitempty"`
+}
+
+// RatingPoint 一局后的评分
+type RatingPoint struct {
+	Match  string    `json:"match"`
+	Rating float64   `json:"rating"`
+	RD     float64   `json:"rd"`
+	Delta  float64   `json:"delta"`
+	At     time.Time `json:"at"`
+}
+
+// newRating 新玩家的初始评分
+func newRating() Rating {
+	return Rating{Rating: ratingInitial, RD: rdInitial, Volatility: volatilityInit}
+}
+
+// PlayerRating 玩家当前评分（未参加过计分对局时为初始评分），供匹配与大厅列表读取
+func PlayerRating(id PlayerID) Rating {
+	if p, ok := GetPlayerStore().Get(id); ok && p.Rating != nil {
+		return *p.Rating
+	}
+	return newRating()
+}
+
+// glickoOpponent 评级周期内的一个对手（或对方队伍的合成评分）及比赛结果
+type glickoOpponent struct {
+	rating, rd float64
+	score      float64 // 1 胜、0.5 平、0 负
+}
+
+// glicko2 按 Glickman 的 Glicko-2 算法计算一个评级周期后的评分
+func glicko2(r Rating, opps []glickoOpponent) Rating {
+	mu := (r.Rating - ratingInitial) / glickoScale
+	phi := r.RD / glickoScale
+	sigma := r.Volatility
+	if len(opps) == 0 {
+		r.RD = math.Min(math.Sqrt(phi*phi+sigma*sigma)*glickoScale, rdInitial)
+		return r
+	}
+
+	var vInv, sum float64
+	for _, o := range opps {
+		muj := (o.rating - ratingInitial) / glickoScale
+		phij := o.rd / glickoScale
+		g := 1 / math.Sqrt(1+3*phij*phij/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muj)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (o.score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	// 新波动率：Illinois 法求根
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(glickoTau*glickoTau)
+	}
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*glickoTau) < 0 {
+			k++
+		}
+		B = a - k*glickoTau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB < 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	sigma = math.Exp(A / 2)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	r.Rating = mu*glickoScale + ratingInitial
+	r.RD = math.Min(phi*glickoScale, rdInitial)
+	r.Volatility = sigma
+	return r
+}
+
+// ratingSide 计分的一方：个人对局为单个玩家，分队对局为一支队伍
+type ratingSide struct {
+	rank    int
+	members []int // res.Players 下标
+	rating  float64
+	rd      float64
+}
+
+// outcome 本方相对另一方的结果：中途离开者负于未离开者，其余按名次
+func outcome(rank, otherRank int, left, otherLeft bool) float64 {
+	switch {
+	case left != otherLeft:
+		if left {
+			return 0
+		}
+		return 1
+	case rank < otherRank:
+		return 1
+	case rank > otherRank:
+		return 0
+	default:
+		return 0.5
+	}
+}
+
+// rateMatch 按结算结果更新参与者评分并写入名次（Tick 线程，在生成结算后、广播前调用）
+//
+// 个人对局中每名玩家与其他每名玩家各算一场；分队对局中玩家与其他每支队伍各算一场，
+// 对方队伍的评分取成员平均，偏差取成员偏差的均方根
+func rateMatch(res *MatchResult) {
+	store := GetPlayerStore()
+	before := make([]Rating, len(res.Players))
+	for i, s := range res.Players {
+		before[i] = PlayerRating(s.ID)
+	}
+
+	var sides []*ratingSide
+	if len(res.Teams) > 0 {
+		byTeam := make(map[string]*ratingSide, len(res.Teams))
+		for _, t := range res.Teams {
+			sd := &ratingSide{rank: t.Rank}
+			byTeam[t.ID] = sd
+			sides = append(sides, sd)
+		}
+		for i, s := range res.Players {
+			if sd, ok := byTeam[s.Team]; ok {
+				sd.members = append(sd.members, i)
+			}
+		}
+	} else {
+		for i, s := range res.Players {
+			sides = append(sides, &ratingSide{rank: s.Rank, members: []int{i}})
+		}
+	}
+	n := 0
+	for _, sd := range sides {
+		if len(sd.members) == 0 {
+			continue
+		}
+		n++
+		var sq float64
+		for _, i := range sd.members {
+			sd.rating += before[i].Rating
+			sq += before[i].RD * before[i].RD
+		}
+		sd.rating /= float64(len(sd.members))
+		sd.rd = math.Sqrt(sq / float64(len(sd.members)))
+	}
+	if n < 2 {
+		return
+	}
+	res.Rated = true
+
+	for _, own := range sides {
+		for _, i := range own.members {
+			s := &res.Players[i]
+			var opps []glickoOpponent
+			for _, other := range sides {
+				if other == own || len(other.members) == 0 {
+					continue
+				}
+				otherLeft := len(other.members) == 1 && res.Players[other.members[0]].Left
+				opps = append(opps, glickoOpponent{
+					rating: other.rating,
+					rd:     other.rd,
+					score:  outcome(own.rank, other.rank, s.Left, otherLeft),
+				})
+			}
+			// 以存储中的当前评分为起点在 Update 内计算，其他房间同时结算的对局不会被覆盖
+			var delta float64
+			prof, err := store.Update(s.ID, func(p *PlayerProfile) error {
+				cur := newRating()
+				if p.Rating != nil {
+					cur = *p.Rating
+				}
+				next := glicko2(cur, opps)
+				next.Matches++
+				delta = next.Rating - cur.Rating
+				next.History = append(next.History, RatingPoint{
+					Match: res.ID, Rating: next.Rating, RD: next.RD, Delta: delta, At: res.EndedAt,
+				})
+				if len(next.History) > ratingHistoryMax {
+					next.History = next.History[len(next.History)-ratingHistoryMax:]
+				}
+				p.Rating = &next
+				return nil
+			})
+			if err != nil {
+				Log.Warnf("rating update failed: player=%s match=%s err=%v", s.ID, res.ID, err)
+				continue
+			}
+			s.Rating, s.RatingDelta = math.Round(prof.Rating.Rating), math.Round(delta)
+		}
+	}
+	Log.Infof("match rated: match=%s sides=%d", res.ID, n)
+}
+
+// leaderboardEntry 排行榜条目
+type leaderboardEntry struct {
+	Rank        int      `json:"rank"`
+	ID          PlayerID `json:"id"`
+	DisplayName string   `json:"displayName"`
+	Rating      float64  `json:"rating"`
+	RD          float64  `json:"rd"`
+	Matches     int      `json:"matches"`
+}
+
+// HandleLeaderboard 评分排行榜：GET /leaderboard?offset=&limit=（按评分降序，limit 默认 20、最大 100）
+func HandleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	qs := r.URL.Query()
+	offset, limit := 0, 20
+	if v := qs.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if v := qs.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, 100)
+	}
+	profiles, total := GetPlayerStore().Leaderboard(offset, limit)
+	out := make([]leaderboardEntry, 0, len(profiles))
+	for i, p := range profiles {
+		out = append(out, leaderboardEntry{
+			Rank:        offset + i + 1,
+			ID:          p.ID,
+			DisplayName: p.DisplayName,
+			Rating:      math.Round(p.Rating.Rating),
+			RD:          math.Round(p.Rating.RD),
+			Matches:     p.Rating.Matches,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"total":   total,
+		"offset":  offset,
+		"players": out,
+	})
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestGlicko2GlickmanExample(t *testing.T) {
+	// Glickman《Example of the Glicko-2 system》中的示例
+	r := glicko2(Rating{Rating: 1500, RD: 200, Volatility: 0.06}, []glickoOpponent{
+		{rating: 1400, rd: 30, score: 1},
+		{rating: 1550, rd: 100, score: 0},
+		{rating: 1700, rd: 300, score: 0},
+	})
+	for _, c := range []struct {
+		name      string
+		got, want float64
+		tol       float64
+	}{
+		{"rating", r.Rating, 1464.06, 0.01},
+		{"rd", r.RD, 151.52, 0.01},
+		{"volatility", r.Volatility, 0.05999, 0.00001},
+	} {
+		if math.Abs(c.got-c.want) > c.tol {
+			t.Errorf("%s = %.5f, want %.5f", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestGlicko2NoGamesWidensRD(t *testing.T) {
+	r := glicko2(Rating{Rating: 1500, RD: 50, Volatility: 0.06}, nil)
+	want := math.Sqrt(50*50/(glickoScale*glickoScale)+0.06*0.06) * glickoScale
+	if r.Rating != 1500 || math.Abs(r.RD-want) > 1e-9 {
+		t.Fatalf("rating=%v rd=%v, want 1500 and %v", r.Rating, r.RD, want)
+	}
+}
+
+func TestRateMatchConcurrentResults(t *testing.T) {
+	prev := GetPlayerStore()
+	SetPlayerStore(&fileStore{profiles: make(map[PlayerID]*PlayerProfile)})
+	t.Cleanup(func() { SetPlayerStore(prev) })
+
+	// 多个房间同时结算包含同一玩家的对局，每局都必须计入
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rateMatch(&MatchResult{ID: "m", Players: []Standing{
+				{ID: "alice", Rank: 1},
+				{ID: PlayerID("opp-" + string(rune('a'+i))), Rank: 2},
+			}})
+		}(i)
+	}
+	wg.Wait()
+	if got := PlayerRating("alice"); got.Matches != n || len(got.History) != n {
+		t.Fatalf("alice matches=%d history=%d, want %d", got.Matches, len(got.History), n)
+	}
+	if r := PlayerRating("alice"); r.Rating <= ratingInitial {
+		t.Fatalf("alice rating = %v after %d wins", r.Rating, n)
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// roomSnapshot 最近一次正常 Tick 结束时的权威状态，用于故障恢复
+type roomSnapshot struct {
+	tick    int64
+	players map[PlayerID]PlayerState
+	acks    map[PlayerID]int64
+	teams   map[string]int // 队伍分数
+	stats   map[PlayerID]*PlayerStats
+	match   matchState // ready / participants 为独立副本
+	mode    any        // GameMode.SnapshotState 的返回值
+}
+
+// roomHealth 房间健康状态（并发安全，供看门狗与 /healthz 读取）
+type roomHealth struct {
+	lastTickAt int64       // 最近一次 Tick 开始时间（UnixNano）
+	stalled    atomic.Bool // 看门狗判定为停滞
+	faults     int64       // 累计 panic 次数
+	lastFault  atomic.Value
+}
+
+// markTick 记录 Tick 心跳
+func (r *Room) markTick(now time.Time) {
+	atomic.StoreInt64(&r.health.lastTickAt, now.UnixNano())
+}
+
+// afterGoodTick 正常 Tick 结束：清零连续故障计数，并按间隔保存快照
+func (r *Room) afterGoodTick() {
+	r.consecutiveFaults = 0
+	if r.snapshotInterval > 0 && (r.lastGood == nil || r.tickSeq-r.lastGood.tick >= int64(r.ticksFor(r.snapshotInterval))) {
+		r.lastGood = r.captureSnapshot()
+	}
+}
+
+// captureSnapshot 复制当前玩家位置、确认序列、队伍分数、对局状态与玩法状态
+func (r *Room) captureSnapshot() *roomSnapshot {
+	s := &roomSnapshot{
+		tick:    r.tickSeq,
+		players: make(map[PlayerID]PlayerState, len(r.Players)),
+		acks:    make(map[PlayerID]int64, len(r.lastSeqProcessed)),
+		teams:   make(map[string]int, len(r.teams)),
+		stats:   make(map[PlayerID]*PlayerStats, len(r.stats)),
+		match:   r.match,
+		mode:    r.mode.SnapshotState(r),
+	}
+	for pid, p := range r.Players {
+		s.players[pid] = p.state()
+	}
+	for pid, seq := range r.lastSeqProcessed {
+		s.acks[pid] = seq
+	}
+	for _, t := range r.teams {
+		s.teams[t.ID] = t.Score
+	}
+	for pid, st := range r.stats {
+		s.stats[pid] = st.clone()
+	}
+	s.match.ready = maps.Clone(r.match.ready)
+	s.match.participants = maps.Clone(r.match.participants)
+	return s
+}
+
+// onTickPanic 记录 panic（房间 ID + 堆栈），恢复到最近快照；连续失败过多则关闭房间
+func (r *Room) onTickPanic(v any) {
+	r.consecutiveFaults++
+	atomic.AddInt64(&r.health.faults, 1)
+	r.health.lastFault.Store(fmt.Sprint(v))
+	r.metrics.IncFault()
+	Log.Errorf("tick panic: room=%s tick=%d faults=%d err=%v\n%s", r.ID, r.tickSeq, r.consecutiveFaults, v, debug.Stack())
+
+	if r.lastGood == nil || (r.maxConsecutiveFaults > 0 && r.consecutiveFaults > r.maxConsecutiveFaults) {
+		r.closeFaulted(fmt.Sprintf("internal error: %v", v))
+		return
+	}
+	// 恢复过程本身出错时直接关闭，避免反复崩溃
+	defer func() {
+		if v2 := recover(); v2 != nil {
+			Log.Errorf("restore panic: room=%s err=%v\n%s", r.ID, v2, debug.Stack())
+			r.closeFaulted(fmt.Sprintf("internal error: %v", v2))
+		}
+	}()
+	r.restoreSnapshot(r.lastGood)
+	Log.Warnf("room restored: room=%s from_tick=%d", r.ID, r.lastGood.tick)
+}
+
+// restoreSnapshot 回滚玩家位置与确认序列，并强制下一帧广播全量
+func (r *Room) restoreSnapshot(s *roomSnapshot) {
+	for pid, p := range r.Players {
+		if st, ok := s.players[pid]; ok {
+			p.X, p.Y = st.X, st.Y
+		}
+	}
+	r.lastSeqProcessed = make(map[PlayerID]int64, len(s.acks))
+	for pid, seq := range s.acks {
+		r.lastSeqProcessed[pid] = seq
+	}
+	clear(r.inputBudget)
+	r.restoreMatch(s)
+	// 清空增量基线：下一帧全部玩家视为变化，降级为全量 state
+	clear(r.lastBroadcast)
+}
+
+// restoreMatch 回滚对局阶段、队伍分数、本局统计与玩法状态；
+// 快照之后已开始新对局或已结算时保持现状，避免结果被重复记录
+func (r *Room) restoreMatch(s *roomSnapshot) {
+	cur := &r.match
+	if s.match.seq != cur.seq || s.match.active != cur.active {
+		Log.Warnf("match moved on since snapshot, keep match state: room=%s match=%d snapshot_match=%d", r.ID, cur.seq, s.match.seq)
+		return
+	}
+	phase := cur.phase
+	m := s.match
+	m.cfg = cur.cfg // 配置以运行期为准
+	m.ready = maps.Clone(s.match.ready)
+	m.participants = maps.Clone(s.match.participants)
+	// 快照之后离开的玩家不在房间中，不会再触发 matchLeave
+	for id := range m.ready {
+		if _, ok := r.Players[id]; !ok {
+			delete(m.ready, id)
+		}
+	}
+	if m.active {
+		for id := range m.participants {
+			if _, ok := r.Players[id]; !ok {
+				m.participants[id] = true
+			}
+		}
+		for id := range r.Players {
+			if _, ok := m.participants[id]; !ok {
+				m.participants[id] = false
+			}
+		}
+	}
+	r.match = m
+
+	for _, t := range r.teams {
+		if score, ok := s.teams[t.ID]; ok {
+			t.Score = score
+		}
+	}
+	r.teamsDirty = true
+	clear(r.stats)
+	for pid, st := range s.stats {
+		r.stats[pid] = st.clone()
+	}
+	r.mode.RestoreState(r, s.mode)
+	if r.match.phase != phase {
+		r.broadcastPhase()
+	}
+}
+
+// closeFaulted 通知玩家并关闭房间（无法恢复时）
+func (r *Room) closeFaulted(reason string) {
+	Log.Errorf("room closed after fault: room=%s reason=%s", r.ID, reason)
+	r.notifyClosed(reason)
+	if r.match.phase == PhasePlaying {
+		r.endMode()
+	}
+	GetRoomManager().removeRoom(r, reason)
+	r.Close()
+}
+
+// notifyClosed 向玩家发送 room_closed 并在写完后断开
+func (r *Room) notifyClosed(reason string) {
+	payload := struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}{Type: "room_closed", Reason: reason}
+	b, _ := json.Marshal(payload)
+	f := frameFromBytes(b)
+	f.reliable = true
+	for _, p := range r.Players {
+		if p.Conn != nil {
+			p.Conn.Send(f, 0)
+			p.Conn.CloseAfterFlush()
+		}
+	}
+	f.Release()
+}
+
+// Stop 通知玩家并关闭房间，返回时不再有 Tick 在执行（用于进程退出，不得在 Tick 线程调用）
+func (r *Room) Stop(reason string) {
+	r.Query(func() {
+		r.notifyClosed(reason)
+		if r.match.phase == PhasePlaying {
+			r.endMode()
+		}
+	})
+	r.Close()
+	r.tickMu.Lock()
+	r.tickMu.Unlock()
+}
+
+// Close 停止房间 Tick，后续投递到房间的请求将被忽略
+func (r *Room) Close() {
+	if r.closed.CompareAndSwap(false, true) {
+		close(r.done)
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// ctfRoom 红蓝两队的夺旗测试房间，旗座分别位于 (10,50) 与 (90,50)
+func ctfRoom(t *testing.T, edit func(p *RoomProfile)) *Room {
+	t.Helper()
+	return testRoom(t, func(p *RoomProfile) {
+		p.Width, p.Height = 100, 100
+		p.Mode = ModeCTF
+		p.ModeConfig = json.RawMessage(`{"bases":{"red":{"x":10,"y":50},"blue":{"x":90,"y":50}},"pickupRadius":2,"captureLimit":3}`)
+		p.Teams = []TeamConfig{
+			{ID: "red", Spawn: &Rect{X: 0, Y: 0, W: 5, H: 5}},
+			{ID: "blue", Spawn: &Rect{X: 95, Y: 95, W: 5, H: 5}},
+		}
+		if edit != nil {
+			edit(p)
+		}
+	})
+}
+
+// joinTeam 以内存会话加入指定队伍
+func joinTeam(r *Room, id PlayerID, team string) *MemSession {
+	s := NewMemSession(r, id, 256)
+	r.Attach(id, s, JoinRequest{Team: team})
+	return s
+}
+
+// moveTo 直接把玩家放到指定位置（绕过输入），随后推进一帧
+func moveTo(r *Room, id PlayerID, x, y float64) {
+	p := r.Players[id]
+	p.X, p.Y = x, y
+	r.runTick(time.Now())
+}
+
+func TestRestoreSnapshotRollsBackModeAndScores(t *testing.T) {
+	r := ctfRoom(t, nil)
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "blue")
+	r.runTick(time.Now())
+	s := r.captureSnapshot()
+
+	moveTo(r, "alice", 90, 50) // 拾取蓝旗
+	ctf := r.mode.(*CaptureTheFlag)
+	if f := ctf.flag("blue"); f.carrier != "alice" {
+		t.Fatalf("blue flag carrier = %q, want alice", f.carrier)
+	}
+	r.AddTeamScore("red", 2)
+
+	r.restoreSnapshot(s)
+	if f := ctf.flag("blue"); f.state() != flagAtBase {
+		t.Fatalf("blue flag after restore = %s, want base", f.state())
+	}
+	if got := r.TeamByID("red").Score; got != 0 {
+		t.Fatalf("red score after restore = %d, want 0", got)
+	}
+	if st := r.stats["alice"]; st != nil && st.Mode["pickups"] != 0 {
+		t.Fatalf("pickup stat survived restore: %+v", st)
+	}
+
+	// 同一份快照可再次恢复
+	moveTo(r, "alice", 90, 50)
+	r.restoreSnapshot(s)
+	if f := ctf.flag("blue"); f.state() != flagAtBase {
+		t.Fatalf("second restore: blue flag = %s, want base", f.state())
+	}
+}
+
+func TestRestoreSnapshotKeepsFinishedMatch(t *testing.T) {
+	r := ctfRoom(t, func(p *RoomProfile) {
+		p.Match = MatchConfig{Enabled: true, MinPlayers: 2, ResultsMs: 60000}
+	})
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "blue")
+	for i := 0; i < 3 && r.Phase() != PhasePlaying; i++ {
+		r.runTick(time.Now())
+	}
+	if r.Phase() != PhasePlaying {
+		t.Fatalf("phase = %s, want playing", r.Phase())
+	}
+	s := r.captureSnapshot()
+
+	r.AddTeamScore("red", 1)
+	r.EndMatch(EndTimeLimit)
+	r.restoreSnapshot(s)
+	if r.Phase() != PhaseResults || r.match.active {
+		t.Fatalf("finished match rolled back: phase=%s active=%v", r.Phase(), r.match.active)
+	}
+	if got := r.TeamByID("red").Score; got != 1 {
+		t.Fatalf("red score = %d, want 1 (match already recorded)", got)
+	}
+}
+
+func TestRoomStopWaitsForTick(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 64)
+	r.StartTicker()
+	r.Stop("server shutdown")
+
+	// Stop 返回后不再有 Tick 执行
+	tick := r.Tick()
+	time.Sleep(3 * r.TickInterval())
+	if r.Tick() != tick {
+		t.Fatalf("tick advanced after stop: %d -> %d", tick, r.Tick())
+	}
+	if _, ok := lastOfType(drain(t, s), "room_closed"); !ok {
+		t.Fatal("players not notified on stop")
+	}
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
-	"encoding/json"
 	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,24 +14,31 @@ type Room struct {
 
 	Players   map[PlayerID]*Player
 	inputChan chan Input
-	leaveChan chan PlayerID
+	ctrlChan  chan func() // 控制命令（配置热更新等），在 Tick 线程中执行
+	done      chan struct{}
+	closed    atomic.Bool
+	tickMu    sync.Mutex // 执行 Tick 期间持有，Stop 借此等待进行中的 Tick 结束
 
-	// Phase 2：网络模拟与裁决
-	simulateDelayMinMs int     // 输入延迟下限（毫秒）
-	simulateDelayMaxMs int     // 输入延迟上限（毫秒）
-	simulateDropProb   float64 // 随机丢弃比例（0~1）
-	rng                *rand.Rand
+	// Phase 2：网络模拟与裁决（按玩家、双向、以 Tick 为单位调度）
+	netDefault   NetConditions              // 房间默认网络条件
+	netOverrides map[PlayerID]NetConditions // 按玩家覆盖（断线重连后保留）
+	rng          *rand.Rand                 // 可设种子，便于复现
 
-	// 每 Tick 输入限流
-	inputsAcceptedThisTick map[PlayerID]int
-	maxInputsPerTick       int
+	// 输入限流：每名玩家的剩余额度，每 Tick 补充 inputsPerTick，满额时删除
+	inputBudget        map[PlayerID]float64
+	maxInputsPerSecond int
+	inputsPerTick      float64 // 由 maxInputsPerSecond 与 Tick 频率换算
 
-	// 配置：世界边界与每 Tick 移动步长
+	// 配置：世界边界、移动速度与每条输入的步长（由 speed 换算）
 	width  float64
 	height float64
+	speed  float64
 	step   float64
+	spawnX float64
+	spawnY float64
 
 	tickerStarted bool
+	tickRate      atomic.Int32 // 每秒 Tick 数，Tick 线程写入，其他协程只读
 
 	// 阶段3：Tick 序号与输入确认序列
 	tickSeq          int64
@@ -40,46 +49,166 @@ type Room struct {
 
 	// 阶段5：上一帧广播的权威状态，用于增量计算
 	lastBroadcast map[PlayerID]PlayerState
+	// 广播热路径的帧间复用缓冲
+	changedScratch []*Player
+	removedScratch []PlayerID
+	allocs         allocSampler
+
+	// 故障隔离：最近正常快照与连续故障计数
+	lastGood             *roomSnapshot
+	consecutiveFaults    int
+	snapshotInterval     time.Duration
+	maxConsecutiveFaults int
+	health               roomHealth
+
+	// 固定步长调度的追帧策略
+	catchUpPolicy   string
+	maxCatchUpTicks int
+
+	// 只读观察者（SSE）与最近事件缓冲
+	events eventLog
+
+	// 玩法规则（创建时按配置选择），见 mode.go
+	mode GameMode
+	// 对局阶段状态机，见 match.go
+	match matchState
+
+	// 队伍（创建时按配置），见 teams.go
+	teams            []*Team
+	teamOf           map[PlayerID]string // 玩家 → 队伍（离线后保留，重连回到原队伍）
+	maxTeamImbalance int
+	teamsDirty       bool // 队伍分数有变化，下一帧随增量广播
+
+	// 玩家本局统计与定时记分板，见 stats.go
+	stats              map[PlayerID]*PlayerStats
+	scoreboardInterval time.Duration // 0 表示仅按请求发送
+
+	// 聊天限流、禁言与最近消息，见 chat.go
+	chat chatState
 
 	// 监控指标
 	metrics *RoomMetrics
 }
 
-// NewRoom 创建房间，初始化数据结构
-func NewRoom(id string) *Room {
-	return &Room{
-		ID:        id,
-		Players:   make(map[PlayerID]*Player),
-		inputChan: make(chan Input, 256), // 足够缓冲，避免网络读阻塞影响 Tick
-		leaveChan: make(chan PlayerID, 64),
-		width:     100,
-		height:    100,
-		step:      1, // 每次输入仅移动 1 单位
-		// Phase 2 默认参数
-		simulateDelayMinMs:     150,
-		simulateDelayMaxMs:     300,
-		simulateDropProb:       0.10,
-		rng:                    rand.New(rand.NewSource(time.Now().UnixNano())),
-		inputsAcceptedThisTick: make(map[PlayerID]int),
-		maxInputsPerTick:       1,
+// NewRoom 按配置参数创建房间，初始化数据结构
+func NewRoom(id string, p RoomProfile) *Room {
+	r := &Room{
+		ID:           id,
+		Players:      make(map[PlayerID]*Player),
+		inputChan:    make(chan Input, p.InputQueueSize), // 足够缓冲，避免网络读阻塞影响 Tick
+		ctrlChan:     make(chan func(), 16),
+		done:         make(chan struct{}),
+		netOverrides: make(map[PlayerID]NetConditions),
+		rng:          newRoomRand(p.NetSeed),
+		inputBudget:  make(map[PlayerID]float64),
 		// 阶段3：确认序列
 		lastSeqProcessed: make(map[PlayerID]int64),
 		// 阶段4：最近快照
 		lastKnown:     make(map[PlayerID]PlayerState),
+		stats:         make(map[PlayerID]*PlayerStats),
 		lastBroadcast: make(map[PlayerID]PlayerState),
-		metrics:       &RoomMetrics{},
+		metrics:       NewRoomMetrics(),
+	}
+	r.tickRate.Store(int32(p.TicksPerSecond))
+	r.applyProfile(p)
+	mode, err := newMode(p)
+	if err != nil {
+		// 配置校验阶段已检查，此处仅兜底
+		Log.Errorf("room mode fallback: room=%s err=%v", id, err)
+		mode = FreeMove{}
+	}
+	r.mode = mode
+	r.initTeams(p)
+	r.initMatch()
+	r.initChat()
+	r.mode.OnRoomStart(r)
+	Log.Infof("room created: room=%s mode=%s", id, r.mode.Name())
+	return r
+}
+
+// applyProfile 写入可热更新的房间参数（仅在创建时或 Tick 线程中调用）
+func (r *Room) applyProfile(p RoomProfile) {
+	r.width = p.Width
+	r.height = p.Height
+	r.speed = p.Speed
+	r.spawnX = p.SpawnX
+	r.spawnY = p.SpawnY
+	r.maxInputsPerSecond = p.MaxInputsPerSecond
+	r.setTickRate(p.TicksPerSecond)
+	r.netDefault = p.Net
+	r.snapshotInterval = time.Duration(p.SnapshotIntervalMs) * time.Millisecond
+	r.maxConsecutiveFaults = p.MaxConsecutiveFaults
+	r.catchUpPolicy = p.CatchUpPolicy
+	r.maxCatchUpTicks = p.MaxCatchUpTicks
+	r.match.cfg = p.Match
+	r.scoreboardInterval = time.Duration(p.ScoreboardIntervalMs) * time.Millisecond
+	r.chat.cfg = p.Chat
+}
+
+// ApplyProfile 请求在 Tick 线程中应用新的房间参数（配置热加载）
+func (r *Room) ApplyProfile(p RoomProfile) {
+	r.Do(func() {
+		if p.Mode != "" && p.Mode != r.mode.Name() {
+			Log.Warnf("profile mode changed: room=%s mode=%s new=%s, applies to new rooms only", r.ID, r.mode.Name(), p.Mode)
+		}
+		r.applyProfile(p)
+		Log.Infof("profile applied: room=%s tps=%d speed=%.2f maxInputsPerSecond=%d net=%+v",
+			r.ID, r.TickRate(), r.speed, r.maxInputsPerSecond, r.netDefault)
+	})
+}
+
+// Query 在 Tick 线程中执行 fn 并等待完成，用于安全读取房间状态；房间已关闭时返回 false
+func (r *Room) Query(fn func()) bool {
+	finished := make(chan struct{})
+	r.Do(func() {
+		fn()
+		close(finished)
+	})
+	select {
+	case <-finished:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// Do 投递一个控制命令，由 Tick 线程在下一帧执行，避免并发改动房间状态
+// 房间已关闭时命令被丢弃
+func (r *Room) Do(fn func()) {
+	select {
+	case r.ctrlChan <- fn:
+	case <-r.done:
+	}
+}
+
+// tryDo 投递客户端发起的控制命令；控制队列已满时丢弃，不阻塞读协程
+func (r *Room) tryDo(fn func()) bool {
+	select {
+	case r.ctrlChan <- fn:
+		return true
+	default:
+		Log.Warnf("discard client command, ctrl chan full: room=%s", r.ID)
+		return false
 	}
 }
 
 // JoinPlayer 将玩家加入房间
-func (r *Room) JoinPlayer(id PlayerID, conn *ClientConn) *Player {
-	// 若存在历史快照，按最近位置恢复；否则默认居中
-	initX, initY := 50.0, 50.0
+func (r *Room) JoinPlayer(id PlayerID, conn Session, req JoinRequest) *Player {
+	// 同一玩家重复接入（重连）时断开旧会话，其排队消息一并丢弃
+	if old, ok := r.Players[id]; ok {
+		r.LeavePlayer(old.ID)
+	}
+	// 初始位置由玩法决定（重连时提供最近快照）
+	p := &Player{ID: id, Dir: DirNone, Conn: conn, Name: touchProfile(id)}
+	r.joinTeam(p, r.assignTeam(id, req.Team))
+	var last *PlayerState
 	if st, ok := r.lastKnown[id]; ok {
-		initX, initY = st.X, st.Y
+		last = &st
 	}
-	p := &Player{ID: id, X: initX, Y: initY, Dir: DirNone, Conn: conn}
+	r.mode.OnJoin(r, p, last)
 	r.Players[id] = p
+	r.matchJoin(id)
+	r.publishPresence("join", id)
 	return p
 }
 
@@ -89,165 +218,228 @@ func (r *Room) LeavePlayer(id PlayerID) {
 		if p.Conn != nil {
 			p.Conn.Close()
 		}
+		p.dropConditioned()
+		r.mode.OnLeave(r, p)
+		r.matchLeave(id)
+		r.leaveTeam(p)
 		// 记录最近位置快照，供断线重连恢复
-		r.lastKnown[id] = PlayerState{ID: string(id), X: p.X, Y: p.Y}
+		r.lastKnown[id] = p.state()
 		delete(r.Players, id)
+		touchProfile(id)
+		r.publishPresence("leave", id)
 	}
 }
 
 // OnInput 入站输入（不立即改变位置），仅记录意图，等下一次 Tick 处理
+// 网络条件模拟（延迟、丢包等）在 Tick 线程中按玩家执行，见 netcond.go
 func (r *Room) OnInput(in Input) {
-	// Phase 2：引入随机延迟与随机丢弃（延迟的是输入进入世界的时间）
-	if r.simulateDropProb > 0 && r.rng.Float64() < r.simulateDropProb {
-		// 丢弃该条输入，模拟丢包（调试）
-		Log.Debugf("drop input: player=%s seq=%d", string(in.PlayerID), in.Seq)
-		r.metrics.IncDropsSimulated()
-		return
-	}
-	min, max := r.simulateDelayMinMs, r.simulateDelayMaxMs
-	if max < min {
-		max = min
-	}
-	delayMs := min
-	if max > min {
-		delayMs = min + r.rng.Intn(max-min+1)
+	// 不阻塞：入口通道满则丢弃，保证 Tick 准时
+	select {
+	case r.inputChan <- in:
+	default:
+		// 丢弃：避免背压影响世界推进
+		Log.Warnf("discard due to chan full: player=%s seq=%d", string(in.PlayerID), in.Seq)
+		r.metrics.IncChanFullDiscarded()
 	}
-	time.AfterFunc(time.Duration(delayMs)*time.Millisecond, func() {
-		// 不阻塞：入口通道满则丢弃，保证 Tick 准时
-		select {
-		case r.inputChan <- in:
-		default:
-			// 丢弃：避免背压影响世界推进
-			Log.Warnf("discard due to chan full: player=%s seq=%d", string(in.PlayerID), in.Seq)
-			r.metrics.IncChanFullDiscarded()
-		}
-	})
 }
 
 // ProcessInputs 处理当前帧的所有输入意图（非阻塞 drain）
 func (r *Room) ProcessInputs() {
 	for {
+		// 控制命令优先：保证 Attach 等先于同一玩家的后续输入生效
 		select {
-		case pid := <-r.leaveChan:
-			r.LeavePlayer(pid)
+		case fn := <-r.ctrlChan:
+			fn()
+			continue
+		default:
+		}
+		select {
+		case fn := <-r.ctrlChan:
+			fn()
 		case in := <-r.inputChan:
 			if p, ok := r.Players[in.PlayerID]; ok {
-				// 阶段3：去重/乱序保护（按客户端序列号）
-				if in.Seq > 0 {
-					if last := r.lastSeqProcessed[in.PlayerID]; in.Seq <= last {
-						Log.Debugf("ignore old seq: player=%s seq=%d last=%d", string(in.PlayerID), in.Seq, last)
-						r.metrics.IncOldSeqIgnored()
-						break
-					}
-				}
-				// 每 Tick 限流：超额输入忽略（权威裁决）
-				cnt := r.inputsAcceptedThisTick[in.PlayerID]
-				if cnt >= r.maxInputsPerTick {
-					// 超限输入丢弃
-					Log.Warnf("rate limit: player=%s seq=%d cnt=%d", string(in.PlayerID), in.Seq, cnt)
-					r.metrics.IncRateLimited()
-				} else {
-					r.applyMove(p, in.Command) // 每个输入仅移动一步
-					r.inputsAcceptedThisTick[in.PlayerID] = cnt + 1
-					if in.Seq > 0 {
-						r.lastSeqProcessed[in.PlayerID] = in.Seq
-						Log.Infof("accept input: player=%s seq=%d cnt=%d", string(in.PlayerID), in.Seq, cnt+1)
-						r.metrics.IncAccepted()
-					}
-				}
+				r.conditionInput(p, in)
 			}
 		default:
+			// 释放经过网络模拟后本帧到期的输入
+			for _, p := range r.Players {
+				r.releaseInputs(p, r.applyInput)
+			}
 			return
 		}
 	}
 }
 
-// UpdateWorld 推进世界其他状态（本例中位置由输入驱动，世界无持续速度）
+// applyInput 裁决并应用一条输入
+func (r *Room) applyInput(p *Player, in Input) {
+	// 阶段3：去重/乱序保护（按客户端序列号）
+	if in.Seq > 0 {
+		if last := r.lastSeqProcessed[in.PlayerID]; in.Seq <= last {
+			Log.Debugf("ignore old seq: player=%s seq=%d last=%d", string(in.PlayerID), in.Seq, last)
+			r.metrics.IncOldSeqIgnored()
+			return
+		}
+	}
+	// 限流：额度不足的输入忽略（权威裁决）
+	budget, ok := r.inputBudget[in.PlayerID]
+	if !ok {
+		budget = r.inputBurst()
+	}
+	if budget < 1 {
+		// 超限输入丢弃
+		Log.Warnf("rate limit: player=%s seq=%d budget=%.2f", string(in.PlayerID), in.Seq, budget)
+		r.metrics.IncRateLimited()
+		r.playerStats(in.PlayerID).InputsLimited++
+		return
+	}
+	if r.acceptsMoves() {
+		x, y := p.X, p.Y
+		r.mode.OnInput(r, p, in)
+		r.recordMove(p, x, y)
+	} else {
+		// 非对局阶段：输入不生效，但仍确认序列，避免客户端无限重演
+		Log.Debugf("input ignored in phase: player=%s seq=%d phase=%s", string(in.PlayerID), in.Seq, r.match.phase)
+	}
+	r.inputBudget[in.PlayerID] = budget - 1
+	if in.Seq > 0 {
+		r.lastSeqProcessed[in.PlayerID] = in.Seq
+		Log.Infof("accept input: player=%s seq=%d budget=%.2f", string(in.PlayerID), in.Seq, budget-1)
+		r.metrics.IncAccepted()
+	}
+}
+
+// UpdateWorld 推进对局阶段与世界其他状态（计时器、碰撞等由玩法实现）
 func (r *Room) UpdateWorld() {
-	// 预留：例如子弹、碰撞、计时器等；当前无需位置变化
+	r.advanceMatch()
+	if r.match.phase == PhasePlaying {
+		r.mode.OnTick(r)
+		r.advanceStats()
+	}
 }
 
 // Broadcast 将当前世界状态广播给所有玩家（文本 JSON）
 func (r *Room) Broadcast() {
-	snapshot := make([]PlayerState, 0, len(r.Players))
+	f := r.encodeState("state")
 	for _, p := range r.Players {
-		snapshot = append(snapshot, PlayerState{ID: string(p.ID), X: p.X, Y: p.Y})
-	}
-	acks := make(map[string]int64, len(r.lastSeqProcessed))
-	for pid, seq := range r.lastSeqProcessed {
-		acks[string(pid)] = seq
+		r.send(p, f, true)
 	}
-	payload := struct {
-		Type    string           `json:"type"`
-		Tick    int64            `json:"tick"`
-		Players []PlayerState    `json:"players"`
-		Acks    map[string]int64 `json:"acks"`
-	}{Type: "state", Tick: r.tickSeq, Players: snapshot, Acks: acks}
+	r.publish("state", f)
+	f.Release()
+}
 
-	b, _ := json.Marshal(payload)
+// encodeState 编码当前全量状态（state / snapshot 消息）的共享主体，调用方负责 Release
+func (r *Room) encodeState(typ string) *Frame {
+	f := newPersonalFrame(r.tickSeq)
+	b := append(f.buf, `"type":"`...)
+	b = append(b, typ...)
+	b = append(b, `","tickRate":`...)
+	b = strconv.AppendInt(b, int64(r.TickRate()), 10)
+	b = append(b, `,"players":[`...)
+	first := true
 	for _, p := range r.Players {
-		if p.Conn != nil {
-			p.Conn.Enqueue(b)
+		if !first {
+			b = append(b, ',')
 		}
+		first = false
+		b = r.mode.BuildPlayerView(r, p, b)
 	}
+	b = append(b, ']')
+	b = r.appendTeams(b)
+	b = r.mode.BuildWorldView(r, b, true)
+	f.buf = append(b, '}')
+	r.metrics.ObservePayload(len(f.buf))
+	return f
 }
 
 // BroadcastDelta 只广播变化的玩家，以及被移除的玩家列表
 func (r *Room) BroadcastDelta() {
-	// 计算变化与移除
-	changed := make([]PlayerState, 0, len(r.Players))
-	removed := make([]string, 0)
+	// 计算变化与移除（复用帧间缓冲）
+	changed := r.changedScratch[:0]
+	removed := r.removedScratch[:0]
 
 	// 被移除的玩家：存在于 lastBroadcast 但不在当前 Players
 	for pid := range r.lastBroadcast {
 		if _, ok := r.Players[pid]; !ok {
-			removed = append(removed, string(pid))
+			removed = append(removed, pid)
 		}
 	}
 	// 发生变化或新增的玩家
 	for pid, p := range r.Players {
 		prev, had := r.lastBroadcast[pid]
-		if !had || prev.X != p.X || prev.Y != p.Y {
-			changed = append(changed, PlayerState{ID: string(pid), X: p.X, Y: p.Y})
+		if !had || prev != p.state() {
+			changed = append(changed, p)
 		}
 	}
+	r.changedScratch, r.removedScratch = changed, removed
+	defer func() {
+		// 不持有玩家指针到下一帧
+		clear(r.changedScratch)
+	}()
 
 	// 如果变化覆盖率很高，降级为全量（state）
 	if len(changed) >= len(r.Players) {
 		r.Broadcast()
 		// 同步 lastBroadcast 为当前全量
-		r.lastBroadcast = make(map[PlayerID]PlayerState, len(r.Players))
+		clear(r.lastBroadcast)
 		for pid, p := range r.Players {
-			r.lastBroadcast[pid] = PlayerState{ID: string(pid), X: p.X, Y: p.Y}
+			r.lastBroadcast[pid] = p.state()
 		}
+		r.teamsDirty = false
 		return
 	}
 
-	acks := make(map[string]int64, len(r.lastSeqProcessed))
-	for pid, seq := range r.lastSeqProcessed {
-		acks[string(pid)] = seq
+	f := newPersonalFrame(r.tickSeq)
+	b := append(f.buf, `"type":"delta","players":[`...) // changed only
+	for i, p := range changed {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = r.mode.BuildPlayerView(r, p, b)
+	}
+	b = append(b, `],"removed":[`...)
+	for i, pid := range removed {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, string(pid))
+	}
+	b = append(b, ']')
+	if r.teamsDirty {
+		// 队伍分数仅在变化时随增量下发
+		b = r.appendTeams(b)
+		r.teamsDirty = false
 	}
-	payload := struct {
-		Type    string           `json:"type"`
-		Tick    int64            `json:"tick"`
-		Players []PlayerState    `json:"players"` // changed only
-		Removed []string         `json:"removed"`
-		Acks    map[string]int64 `json:"acks"`
-	}{Type: "delta", Tick: r.tickSeq, Players: changed, Removed: removed, Acks: acks}
+	b = r.mode.BuildWorldView(r, b, false)
+	f.buf = append(b, '}')
+	r.metrics.ObservePayload(len(f.buf))
+	r.publish("delta", f)
 
-	b, _ := json.Marshal(payload)
+	var full *Frame
 	for _, p := range r.Players {
-		if p.Conn != nil {
-			p.Conn.Enqueue(b)
+		if p.Conn == nil {
+			continue
+		}
+		if p.Conn.SnapshotOnly() {
+			// 慢消费者已丢失增量，改发全量使其重新对齐
+			if full == nil {
+				full = r.encodeState("state")
+			}
+			r.send(p, full, true)
+			continue
 		}
+		r.send(p, f, false)
+	}
+	f.Release()
+	if full != nil {
+		full.Release()
 	}
 
 	// 更新 lastBroadcast：删除 removed，写入 changed
 	for _, id := range removed {
-		delete(r.lastBroadcast, PlayerID(id))
+		delete(r.lastBroadcast, id)
 	}
-	for _, st := range changed {
-		r.lastBroadcast[PlayerID(st.ID)] = st
+	for _, p := range changed {
+		r.lastBroadcast[p.ID] = p.state()
 	}
 }
 
@@ -257,24 +449,12 @@ func (r *Room) SendSnapshotTo(id PlayerID) {
 	if !ok || p.Conn == nil {
 		return
 	}
-	world := make([]PlayerState, 0, len(r.Players))
-	for _, pl := range r.Players {
-		world = append(world, PlayerState{ID: string(pl.ID), X: pl.X, Y: pl.Y})
-	}
-	acks := make(map[string]int64, len(r.lastSeqProcessed))
-	for pid, seq := range r.lastSeqProcessed {
-		acks[string(pid)] = seq
-	}
-	payload := struct {
-		Type    string           `json:"type"`
-		Tick    int64            `json:"tick"`
-		Players []PlayerState    `json:"players"`
-		Acks    map[string]int64 `json:"acks"`
-	}{Type: "snapshot", Tick: r.tickSeq, Players: world, Acks: acks}
-	b, _ := json.Marshal(payload)
+	f := r.encodeState("snapshot")
+	f.reliable = true
 	// 打印快照（调试）
-	Log.Debugf("snapshot: %s", string(b))
-	p.Conn.Enqueue(b)
+	Log.Debugf("snapshot: %s", f.Bytes())
+	r.send(p, f, false)
+	f.Release()
 }
 
 // applyMove 执行一次移动并进行越界裁剪
@@ -305,14 +485,20 @@ func (r *Room) applyMove(p *Player, dir Direction) {
 	}
 }
 
-// RequestLeave 请求在 Tick 线程中移除玩家，避免并发改动房间状态
-func (r *Room) RequestLeave(pid PlayerID) {
-	// 为保证移除一定生效，这里采用阻塞式写入（通道有容量，避免死锁）
-	r.leaveChan <- pid
-}
-
-// BeginTick 每帧开始时重置输入计数，保证同一时间线上的裁决一致
+// BeginTick 每帧开始时补充输入额度，保证同一时间线上的裁决一致
 func (r *Room) BeginTick() {
 	r.tickSeq++
-	r.inputsAcceptedThisTick = make(map[PlayerID]int)
+	burst := r.inputBurst()
+	for id, b := range r.inputBudget {
+		if b += r.inputsPerTick; b >= burst {
+			delete(r.inputBudget, id)
+		} else {
+			r.inputBudget[id] = b
+		}
+	}
+}
+
+// inputBurst 输入额度上限：一个 Tick 的额度，且至少一条
+func (r *Room) inputBurst() float64 {
+	return max(r.inputsPerTick, 1)
 }
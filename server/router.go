@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dispatch 消息处理器的执行位置
+type Dispatch int
+
+const (
+	// OnRead 在连接的读协程中直接执行：不得访问房间状态，只能调用并发安全的入口（如 OnInput）
+	OnRead Dispatch = iota
+	// OnTick 作为控制命令投递到 Tick 线程执行，可读写房间状态；命令队列满时丢弃
+	OnTick
+)
+
+// 错误响应码
+const (
+	ErrCodeMalformed   = "malformed"    // 不是 JSON 对象或缺少 type
+	ErrCodeUnknownType = "unknown_type" // 未注册的消息类型
+	ErrCodeBadPayload  = "bad_payload"  // 处理器解码失败
+	ErrCodeRateLimited = "rate_limited" // 超过该类型的每连接速率
+	ErrCodeInvalid     = "invalid"      // 处理器拒绝（未指定错误码时）
+)
+
+// errorMessage 入站消息被拒绝时回复发送者
+type errorMessage struct {
+	Type  string `json:"type"`
+	Code  string `json:"code"`
+	Ref   string `json:"ref,omitempty"` // 被拒绝消息的 type
+	Error string `json:"error,omitempty"`
+}
+
+// MessageError 处理器返回该错误时以 Code 回复发送者
+type MessageError struct {
+	Code string
+	Msg  string
+}
+
+func (e *MessageError) Error() string {
+	if e.Msg == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Msg
+}
+
+// MessageContext 一次消息处理的上下文
+type MessageContext struct {
+	Room   *Room
+	Player PlayerID
+	Type   string
+	OnTick bool // 处理器是否在 Tick 线程中执行
+}
+
+// Reply 向发送者回复一条可靠消息；读协程中调用时经命令队列转到 Tick 线程发送
+func (c *MessageContext) Reply(v any) {
+	if c.OnTick {
+		c.Room.reply(c.Player, v)
+		return
+	}
+	room, id := c.Room, c.Player
+	room.tryDo(func() { room.reply(id, v) })
+}
+
+// reply 向单个在线玩家发送控制消息（Tick 线程）
+func (r *Room) reply(id PlayerID, v any) {
+	p, ok := r.Players[id]
+	if !ok {
+		return
+	}
+	f := controlFrame(v)
+	r.send(p, f, false)
+	f.Release()
+}
+
+// MessageHandler 一种入站消息类型的处理器
+type MessageHandler struct {
+	// Decode 将完整的消息 JSON 解析为 Handle 的参数；为空时参数为原始字节（OnTick 处理器收到副本）
+	Decode func(payload []byte) (any, error)
+	// Handle 处理消息；返回错误时回复发送者（*MessageError 指定错误码，否则为 invalid）
+	Handle   func(ctx *MessageContext, msg any) error
+	Dispatch Dispatch
+	// 每连接该类型的令牌桶限流；PerSecond 为 0 时不限
+	PerSecond float64
+	Burst     int
+}
+
+// JSONHandler 以 JSON 解码到 T 的类型化处理器
+func JSONHandler[T any](d Dispatch, fn func(ctx *MessageContext, msg *T) error) MessageHandler {
+	return MessageHandler{
+		Decode: func(payload []byte) (any, error) {
+			msg := new(T)
+			if err := json.Unmarshal(payload, msg); err != nil {
+				return nil, err
+			}
+			return msg, nil
+		},
+		Handle: func(ctx *MessageContext, msg any) error {
+			return fn(ctx, msg.(*T))
+		},
+		Dispatch: d,
+	}
+}
+
+// Limit 设置每连接速率限制
+func (h MessageHandler) Limit(perSecond float64, burst int) MessageHandler {
+	h.PerSecond, h.Burst = perSecond, max(burst, 1)
+	return h
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]MessageHandler{}
+)
+
+// RegisterMessage 注册入站消息类型（type 不区分大小写），通常在 init 中调用；重名时 panic
+func RegisterMessage(typ string, h MessageHandler) {
+	if h.Handle == nil {
+		panic("server: nil handler for message " + typ)
+	}
+	typ = strings.ToLower(typ)
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if _, dup := handlers[typ]; dup {
+		panic("server: duplicate message type " + typ)
+	}
+	handlers[typ] = h
+}
+
+// MessageTypes 已注册的入站消息类型（排序）
+func MessageTypes() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	out := make([]string, 0, len(handlers))
+	for typ := range handlers {
+		out = append(out, typ)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// 错误响应自身的限流，避免异常客户端借此刷满房间命令队列
+const (
+	errorRepliesPerSecond = 5
+	errorReplyBurst       = 10
+)
+
+// inbound 单个连接的入站消息分发器（各传输共用）；只在该连接的读协程中使用，
+// 错误响应预算由读协程与 Tick 线程（OnTick 处理器的错误）共用
+type inbound struct {
+	room    *Room
+	player  PlayerID
+	buckets map[string]*tokenBucket
+	errMu   sync.Mutex
+	errors  *tokenBucket
+}
+
+func newInbound(room *Room, player PlayerID) *inbound {
+	return &inbound{room: room, player: player, buckets: make(map[string]*tokenBucket)}
+}
+
+// envelope 入站消息的公共部分
+type envelope struct {
+	Type string `json:"type"`
+}
+
+// dispatch 解析一条入站文本消息并交给对应处理器；被拒绝时回复 error
+func (in *inbound) dispatch(payload []byte) {
+	var env envelope
+	if err := json.Unmarshal(payload, &env); err != nil || env.Type == "" {
+		in.reject("", ErrCodeMalformed, "expected a JSON object with a type")
+		return
+	}
+	typ := strings.ToLower(env.Type)
+	handlersMu.RLock()
+	h, ok := handlers[typ]
+	handlersMu.RUnlock()
+	if !ok {
+		in.reject(env.Type, ErrCodeUnknownType, "")
+		return
+	}
+	if h.PerSecond > 0 {
+		b, ok := in.buckets[typ]
+		if !ok {
+			b = newTokenBucket(h.Burst, time.Now())
+			in.buckets[typ] = b
+		}
+		if !b.take(time.Now(), h.PerSecond, h.Burst) {
+			in.reject(typ, ErrCodeRateLimited, "")
+			return
+		}
+	}
+	var msg any = payload
+	if h.Decode != nil {
+		var err error
+		if msg, err = h.Decode(payload); err != nil {
+			in.reject(typ, ErrCodeBadPayload, err.Error())
+			return
+		}
+	} else if h.Dispatch == OnTick {
+		// payload 可能是传输层复用的读缓冲（如 UDP），延迟到 Tick 执行前必须复制
+		msg = bytes.Clone(payload)
+	}
+	ctx := &MessageContext{Room: in.room, Player: in.player, Type: typ, OnTick: h.Dispatch == OnTick}
+	if !ctx.OnTick {
+		if err := h.Handle(ctx, msg); err != nil {
+			in.fail(ctx, err)
+		}
+		return
+	}
+	// 命令队列满时 tryDo 已记录丢弃，且无法再经队列回复
+	in.room.tryDo(func() {
+		if err := h.Handle(ctx, msg); err != nil && in.allowError() {
+			in.room.reply(in.player, handlerError(typ, err))
+		}
+	})
+}
+
+// fail 读协程中处理器返回的错误
+func (in *inbound) fail(ctx *MessageContext, err error) {
+	if !in.allowError() {
+		return
+	}
+	ctx.Reply(handlerError(ctx.Type, err))
+}
+
+// reject 回复分发阶段的错误（读协程）
+func (in *inbound) reject(typ, code, detail string) {
+	Log.Debugf("message rejected: room=%s player=%s type=%s code=%s", in.room.ID, in.player, typ, code)
+	if !in.allowError() {
+		return
+	}
+	room, id := in.room, in.player
+	room.tryDo(func() {
+		room.reply(id, errorMessage{Type: "error", Code: code, Ref: typ, Error: detail})
+	})
+}
+
+func (in *inbound) allowError() bool {
+	in.errMu.Lock()
+	defer in.errMu.Unlock()
+	now := time.Now()
+	if in.errors == nil {
+		in.errors = newTokenBucket(errorReplyBurst, now)
+	}
+	return in.errors.take(now, errorRepliesPerSecond, errorReplyBurst)
+}
+
+// handlerError 将处理器错误转换为错误响应
+func handlerError(typ string, err error) errorMessage {
+	var me *MessageError
+	if errors.As(err, &me) {
+		return errorMessage{Type: "error", Code: me.Code, Ref: typ, Error: me.Msg}
+	}
+	return errorMessage{Type: "error", Code: ErrCodeInvalid, Ref: typ, Error: err.Error()}
+}
+
+// tokenBucket 令牌桶限流（调用方负责同步）
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: float64(burst), last: now}
+}
+
+// take 按速率补充令牌后取走一个，不足时返回 false
+func (b *tokenBucket) take(now time.Time, perSecond float64, burst int) bool {
+	b.tokens = min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// rawTickSeen 原始字节 OnTick 测试处理器收到的消息
+var rawTickSeen []string
+
+func init() {
+	RegisterMessage("test_raw_tick", MessageHandler{
+		Handle: func(ctx *MessageContext, msg any) error {
+			rawTickSeen = append(rawTickSeen, string(msg.([]byte)))
+			return nil
+		},
+		Dispatch: OnTick,
+	})
+	RegisterMessage("test_fail_tick", MessageHandler{
+		Handle: func(*MessageContext, any) error {
+			return &MessageError{Code: "nope", Msg: "always fails"}
+		},
+		Dispatch: OnTick,
+	})
+	RegisterMessage("test_limited", MessageHandler{
+		Handle: func(*MessageContext, any) error { return nil },
+	}.Limit(0.001, 2))
+}
+
+// routerErrors 会话收到的错误响应，格式为 code/ref
+func routerErrors(t *testing.T, s *MemSession) []string {
+	t.Helper()
+	var out []string
+	for {
+		select {
+		case b := <-s.Recv():
+			var m errorMessage
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("invalid message %s: %v", b, err)
+			}
+			if m.Type == "error" {
+				out = append(out, m.Code+"/"+m.Ref)
+			}
+		default:
+			return out
+		}
+	}
+}
+
+func TestDispatchErrorCodes(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"not json", `hello`, ErrCodeMalformed + "/"},
+		{"missing type", `{"command":"up"}`, ErrCodeMalformed + "/"},
+		{"unknown type", `{"type":"Teleport"}`, ErrCodeUnknownType + "/Teleport"},
+		{"bad payload", `{"type":"move","seq":"x"}`, ErrCodeBadPayload + "/move"},
+		{"handler error on tick", `{"type":"test_fail_tick"}`, "nope/test_fail_tick"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := testRoom(t, nil)
+			s := ConnectMem(r, "alice", 64)
+			r.runTick(time.Now())
+			drain(t, s)
+
+			newInbound(r, "alice").dispatch([]byte(c.payload))
+			r.runTick(time.Now())
+			if got := routerErrors(t, s); len(got) != 1 || got[0] != c.want {
+				t.Fatalf("errors = %v, want [%s]", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDispatchPerTypeLimit(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	drain(t, s)
+
+	in := newInbound(r, "alice")
+	for i := 0; i < 3; i++ {
+		in.dispatch([]byte(`{"type":"test_limited"}`))
+	}
+	// 限流按类型计：其他类型不受影响
+	in.dispatch([]byte(`{"type":"move","command":"up","seq":1}`))
+	r.runTick(time.Now())
+	if got := routerErrors(t, s); len(got) != 1 || got[0] != ErrCodeRateLimited+"/test_limited" {
+		t.Fatalf("errors = %v, want one rate_limited", got)
+	}
+}
+
+func TestDispatchTickErrorsShareBudget(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	drain(t, s)
+
+	in := newInbound(r, "alice")
+	for i := 0; i < errorReplyBurst; i++ {
+		in.dispatch([]byte(`{"type":"test_fail_tick"}`))
+		r.runTick(time.Now())
+	}
+	// 读协程与 Tick 线程的错误共用同一预算
+	in.dispatch([]byte(`{"type":"unknown"}`))
+	in.dispatch([]byte(`{"type":"test_fail_tick"}`))
+	r.runTick(time.Now())
+	if got := routerErrors(t, s); len(got) != errorReplyBurst {
+		t.Fatalf("got %d error replies, want %d", len(got), errorReplyBurst)
+	}
+}
+
+func TestRawOnTickHandlerGetsCopy(t *testing.T) {
+	r := testRoom(t, nil)
+	ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	rawTickSeen = nil
+
+	// 模拟传输层在 Tick 执行前复用读缓冲
+	buf := []byte(`{"type":"test_raw_tick","n":1}`)
+	want := string(buf)
+	newInbound(r, "alice").dispatch(buf)
+	copy(buf, `{"type":"test_raw_tick","n":2}`)
+	r.runTick(time.Now())
+	if len(rawTickSeen) != 1 || rawTickSeen[0] != want {
+		t.Fatalf("handler saw %q, want %q", rawTickSeen, want)
+	}
+}
+
+func TestMoveWithoutDirectionAdvancesAck(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	drain(t, s)
+
+	newInbound(r, "alice").dispatch([]byte(`{"type":"move","command":"","seq":3}`))
+	r.runTick(time.Now())
+	msgs := drain(t, s)
+	if _, ok := lastOfType(msgs, "error"); ok {
+		t.Fatal("idle move rejected")
+	}
+	if msg, ok := lastOfType(msgs, "state", "delta"); !ok || msg.Ack != 3 {
+		t.Fatalf("ack after idle move = %+v, want 3", msg)
+	}
+}
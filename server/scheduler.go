@@ -0,0 +1,158 @@
+package server
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 调度模式
+const (
+	SchedulerGoroutine = "goroutine" // 每个房间一个协程 + 计时器（默认）
+	SchedulerPool      = "pool"      // 固定工作协程池 + 时间轮
+)
+
+// tickPool 工作协程池调度器：房间的下一次 Tick 挂在时间轮上，到期后交给任一工作协程执行
+// 每个房间在时间轮中最多只有一个条目，且仅在本帧执行完毕后重新挂入，保证房间内单线程推进
+type tickPool struct {
+	wheel   *timingWheel
+	work    chan *wheelEntry
+	workers int
+	rooms   int64 // 调度中的房间数
+
+	Lag *Histogram // 到期时间 → 工作协程开始执行的延迟（秒）
+}
+
+var (
+	poolOnce    sync.Once
+	defaultPool *tickPool
+)
+
+// getTickPool 按配置返回全局协程池；goroutine 模式下返回 nil
+func getTickPool() *tickPool {
+	poolOnce.Do(func() {
+		cfg := CurrentConfig().Server
+		if cfg.Scheduler != SchedulerPool {
+			return
+		}
+		workers := cfg.SchedulerWorkers
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		defaultPool = newTickPool(workers, time.Millisecond, 512)
+		Log.Infof("tick scheduler: mode=pool workers=%d", workers)
+	})
+	return defaultPool
+}
+
+func newTickPool(workers int, resolution time.Duration, slots int) *tickPool {
+	p := &tickPool{
+		wheel:   newTimingWheel(resolution, slots),
+		work:    make(chan *wheelEntry, 1024),
+		workers: workers,
+		Lag:     NewHistogram(durationBuckets...),
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	go p.wheel.run(p.work)
+	return p
+}
+
+// schedule 将房间加入调度
+func (p *tickPool) schedule(r *Room) {
+	atomic.AddInt64(&p.rooms, 1)
+	p.wheel.add(&wheelEntry{room: r, due: time.Now().Add(r.TickInterval())})
+}
+
+// worker 执行到期房间的 Tick，并按房间自己的频率重新挂入时间轮
+func (p *tickPool) worker() {
+	for e := range p.work {
+		if e.room.closed.Load() {
+			atomic.AddInt64(&p.rooms, -1)
+			continue
+		}
+		p.Lag.Observe(time.Since(e.due).Seconds())
+		e.due = e.room.advance(e.due)
+		p.wheel.add(e)
+	}
+}
+
+// wheelEntry 时间轮中的一个待执行房间
+type wheelEntry struct {
+	room   *Room
+	due    time.Time
+	rounds int // 还需转过的整圈数
+}
+
+// timingWheel 单层时间轮：槽位粒度 resolution，超过一圈的条目以 rounds 计数
+type timingWheel struct {
+	mu         sync.Mutex
+	slots      [][]*wheelEntry
+	resolution time.Duration
+	start      time.Time
+	cur        int64 // 最近处理过的绝对槽位序号
+}
+
+func newTimingWheel(resolution time.Duration, slots int) *timingWheel {
+	return &timingWheel{
+		slots:      make([][]*wheelEntry, slots),
+		resolution: resolution,
+		start:      time.Now(),
+	}
+}
+
+// add 按到期时间放入槽位（向上取整；已到期的放入下一个槽位）
+func (w *timingWheel) add(e *wheelEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	abs := int64((e.due.Sub(w.start) + w.resolution - 1) / w.resolution)
+	if abs <= w.cur {
+		abs = w.cur + 1
+	}
+	size := int64(len(w.slots))
+	e.rounds = int((abs - w.cur - 1) / size)
+	w.slots[abs%size] = append(w.slots[abs%size], e)
+}
+
+// run 驱动时间轮：按墙钟推进所有经过的槽位，将到期条目投递给工作协程
+func (w *timingWheel) run(out chan<- *wheelEntry) {
+	t := time.NewTicker(w.resolution)
+	defer t.Stop()
+	var due []*wheelEntry
+	for now := range t.C {
+		due = w.expire(int64(now.Sub(w.start)/w.resolution), due)
+		// 投递时不持锁：工作协程会回调 add
+		for i, e := range due {
+			out <- e
+			due[i] = nil
+		}
+		due = due[:0]
+	}
+}
+
+// expire 推进到绝对槽位 target，将经过的槽位中到期的条目追加到 due
+func (w *timingWheel) expire(target int64, due []*wheelEntry) []*wheelEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	size := int64(len(w.slots))
+	for w.cur < target {
+		w.cur++
+		slot := w.slots[w.cur%size]
+		keep := slot[:0]
+		for _, e := range slot {
+			if e.rounds == 0 {
+				due = append(due, e)
+			} else {
+				e.rounds--
+				keep = append(keep, e)
+			}
+		}
+		for i := len(keep); i < len(slot); i++ {
+			slot[i] = nil
+		}
+		w.slots[w.cur%size] = keep
+	}
+	return due
+}
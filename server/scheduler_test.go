@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// expireAt 逐槽推进时间轮，返回每个条目到期时的槽位序号
+func expireAt(w *timingWheel, until int64) map[*wheelEntry]int64 {
+	at := make(map[*wheelEntry]int64)
+	for slot := w.cur + 1; slot <= until; slot++ {
+		for _, e := range w.expire(slot, nil) {
+			at[e] = slot
+		}
+	}
+	return at
+}
+
+func TestTimingWheelExpiresOnDueSlot(t *testing.T) {
+	const res = time.Millisecond
+	w := newTimingWheel(res, 8)
+	cases := []struct {
+		offset time.Duration
+		slot   int64
+	}{
+		{1 * res, 1},
+		{3 * res, 3},
+		{2500 * time.Microsecond, 3}, // 向上取整
+		{8 * res, 8},                 // 恰好一圈
+		{9 * res, 9},                 // 超过一圈：需要转过整圈
+		{17 * res, 17},
+		{40 * res, 40},
+	}
+	entries := make([]*wheelEntry, len(cases))
+	for i, c := range cases {
+		entries[i] = &wheelEntry{due: w.start.Add(c.offset)}
+		w.add(entries[i])
+	}
+	at := expireAt(w, 64)
+	for i, c := range cases {
+		if got, ok := at[entries[i]]; !ok || got != c.slot {
+			t.Errorf("offset %v: expired at slot %d (ok=%v), want %d", c.offset, got, ok, c.slot)
+		}
+	}
+}
+
+func TestTimingWheelPastDueGoesToNextSlot(t *testing.T) {
+	w := newTimingWheel(time.Millisecond, 8)
+	w.expire(5, nil)
+	e := &wheelEntry{due: w.start.Add(-time.Second)}
+	w.add(e)
+	if due := w.expire(6, nil); len(due) != 1 || due[0] != e {
+		t.Fatalf("past-due entry not expired on next slot: %v", due)
+	}
+}
+
+func TestTimingWheelRescheduleAfterExpire(t *testing.T) {
+	const res = time.Millisecond
+	w := newTimingWheel(res, 4)
+	e := &wheelEntry{due: w.start.Add(2 * res)}
+	w.add(e)
+	var fired []int64
+	for slot := int64(1); slot <= 30; slot++ {
+		for _, d := range w.expire(slot, nil) {
+			fired = append(fired, slot)
+			// 与工作协程相同：执行后按 5 个槽位的间隔重新挂入
+			d.due = d.due.Add(5 * res)
+			w.add(d)
+		}
+	}
+	want := []int64{2, 7, 12, 17, 22, 27}
+	if len(fired) != len(want) {
+		t.Fatalf("fired at %v, want %v", fired, want)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Fatalf("fired at %v, want %v", fired, want)
+		}
+	}
+}
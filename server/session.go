@@ -0,0 +1,171 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Session 房间视角下的一个客户端会话，与具体传输（WebSocket、内存等）无关
+//
+// Send / SendFull / SnapshotOnly / Close / CloseAfterFlush 仅由 Tick 线程调用；
+// Done 可在任意协程中读取，传输层检测到断线时关闭该通道，房间随之移除玩家
+type Session interface {
+	// Send 投递一帧（非阻塞）；成功时会话持有 f 的一个引用，写出后释放；ack 为该接收者头部中的确认序列
+	Send(f *Frame, ack int64) bool
+	// SendFull 投递全量消息；处于降级状态的会话借此恢复增量
+	SendFull(f *Frame, ack int64) bool
+	// SnapshotOnly 会话是否已丢失增量，只能接收全量消息
+	SnapshotOnly() bool
+	// Close 立即断开
+	Close()
+	// CloseAfterFlush 写完已排队的消息后断开
+	CloseAfterFlush()
+	// Done 会话断开时关闭
+	Done() <-chan struct{}
+}
+
+// controlQueueSize 网络会话的可靠消息队列容量（独立于状态流的发送队列）；
+// 可靠消息稀疏且不可丢弃，队列写满说明客户端已无法跟上，会话将被断开
+const controlQueueSize = 256
+
+// Attach 在 Tick 线程中将会话作为玩家加入房间并发送初始快照；会话断开后自动离开
+// 所有传输适配器都通过该入口接入，输入经 OnInput 注入
+func (r *Room) Attach(id PlayerID, s Session, req JoinRequest) {
+	select {
+	case r.ctrlChan <- func() {
+		p := r.JoinPlayer(id, s, req)
+		// 初次连接/重连时，立即发送一次权威快照，便于客户端对齐并重演未确认输入
+		r.SendSnapshotTo(id)
+		r.sendPhaseTo(p)
+	}:
+	case <-r.done:
+		s.Close()
+		return
+	}
+	go func() {
+		select {
+		case <-s.Done():
+			r.detach(id, s)
+		case <-r.done:
+		}
+	}()
+}
+
+// detach 会话断开后移除玩家；同一玩家已被新会话接管（重连）时不做处理
+func (r *Room) detach(id PlayerID, s Session) {
+	r.Do(func() {
+		if p, ok := r.Players[id]; ok && p.Conn == s {
+			r.LeavePlayer(id)
+		}
+	})
+}
+
+// monitored 可上报每连接指标的会话（/metrics 使用）
+type monitored interface {
+	labels() (transport, room string, player PlayerID)
+	stats() *ConnStats
+	QueueDepth() int
+}
+
+// ConnStats 单连接出站计数
+type ConnStats struct {
+	BytesSent    int64
+	MessagesSent int64
+	Dropped      int64
+}
+
+// activeSessions 在线会话登记表（监控用），键为 monitored
+var activeSessions sync.Map
+
+func registerSession(s monitored)   { activeSessions.Store(s, struct{}{}) }
+func unregisterSession(s monitored) { activeSessions.Delete(s) }
+
+// MemSession 内存会话：不经过网络，供测试与机器人直接接入房间
+// 收到的消息（头部 + 主体）以独立字节切片投递到 Recv；队列满时丢弃并降级为仅全量
+type MemSession struct {
+	roomID   string
+	playerID PlayerID
+	recv     chan []byte
+	done     chan struct{}
+	once     sync.Once
+	closed   atomic.Bool
+	degraded bool // 仅由 Tick 线程读写
+	Stats    ConnStats
+}
+
+// NewMemSession 创建内存会话并登记到监控；size 为接收队列容量
+func NewMemSession(room *Room, playerID PlayerID, size int) *MemSession {
+	if size <= 0 {
+		size = CurrentConfig().Server.SendQueueSize
+	}
+	s := &MemSession{
+		roomID:   room.ID,
+		playerID: playerID,
+		recv:     make(chan []byte, size),
+		done:     make(chan struct{}),
+	}
+	registerSession(s)
+	return s
+}
+
+// ConnectMem 创建内存会话并加入房间
+func ConnectMem(room *Room, playerID PlayerID, size int) *MemSession {
+	s := NewMemSession(room, playerID, size)
+	room.Attach(playerID, s, JoinRequest{})
+	return s
+}
+
+// Recv 接收通道；会话关闭后不再有新消息，调用方应同时监听 Done
+func (s *MemSession) Recv() <-chan []byte { return s.recv }
+
+// Done 会话关闭时关闭
+func (s *MemSession) Done() <-chan struct{} { return s.done }
+
+// Input 以该会话的玩家身份提交一条输入
+func (s *MemSession) Input(room *Room, dir Direction, seq int64) {
+	room.OnInput(Input{PlayerID: s.playerID, Command: dir, Seq: seq})
+}
+
+func (s *MemSession) Send(f *Frame, ack int64) bool {
+	if s.closed.Load() {
+		return false
+	}
+	b := f.AppendTo(nil, ack)
+	select {
+	case s.recv <- b:
+		atomic.AddInt64(&s.Stats.MessagesSent, 1)
+		atomic.AddInt64(&s.Stats.BytesSent, int64(len(b)))
+		return true
+	default:
+		atomic.AddInt64(&s.Stats.Dropped, 1)
+		s.degraded = true
+		return false
+	}
+}
+
+func (s *MemSession) SendFull(f *Frame, ack int64) bool {
+	if !s.Send(f, ack) {
+		return false
+	}
+	s.degraded = false
+	return true
+}
+
+func (s *MemSession) SnapshotOnly() bool { return s.degraded }
+
+// Close 关闭会话（可在任意协程调用，可重复调用）
+func (s *MemSession) Close() {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.done)
+		unregisterSession(s)
+	})
+}
+
+// CloseAfterFlush 内存会话无写出过程，已投递的消息仍可从 Recv 读取
+func (s *MemSession) CloseAfterFlush() { s.Close() }
+
+func (s *MemSession) QueueDepth() int { return len(s.recv) }
+
+func (s *MemSession) labels() (string, string, PlayerID) { return "mem", s.roomID, s.playerID }
+func (s *MemSession) stats() *ConnStats                  { return &s.Stats }
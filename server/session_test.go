@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// testRoom 不启动 Tick 循环的房间，测试中手动调用 runTick 推进；默认关闭网络模拟
+func testRoom(t *testing.T, edit func(p *RoomProfile)) *Room {
+	t.Helper()
+	p := DefaultConfig().Room
+	p.Net = NetConditions{}
+	if edit != nil {
+		edit(&p)
+	}
+	r := NewRoom(t.Name(), p)
+	t.Cleanup(r.Close)
+	return r
+}
+
+// memMessage 内存会话收到的一条消息（只解析测试关心的字段）
+type memMessage struct {
+	Type    string `json:"type"`
+	Tick    int64  `json:"tick"`
+	Ack     int64  `json:"ack"`
+	Players []struct {
+		ID string  `json:"id"`
+		X  float64 `json:"x"`
+		Y  float64 `json:"y"`
+	} `json:"players"`
+}
+
+// drain 读出会话中已投递的全部消息
+func drain(t *testing.T, s *MemSession) []memMessage {
+	t.Helper()
+	var out []memMessage
+	for {
+		select {
+		case b := <-s.Recv():
+			var m memMessage
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("invalid message %s: %v", b, err)
+			}
+			out = append(out, m)
+		default:
+			return out
+		}
+	}
+}
+
+func lastOfType(msgs []memMessage, types ...string) (memMessage, bool) {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		for _, typ := range types {
+			if msgs[i].Type == typ {
+				return msgs[i], true
+			}
+		}
+	}
+	return memMessage{}, false
+}
+
+func TestMemSessionJoinSnapshotInputAck(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+
+	snap, ok := lastOfType(drain(t, s), "snapshot")
+	if !ok {
+		t.Fatal("no snapshot after join")
+	}
+	if len(snap.Players) != 1 || snap.Players[0].ID != "alice" {
+		t.Fatalf("snapshot players = %+v", snap.Players)
+	}
+	if snap.Ack != 0 {
+		t.Fatalf("snapshot ack = %d, want 0", snap.Ack)
+	}
+	x0 := snap.Players[0].X
+
+	s.Input(r, DirRight, 1)
+	r.runTick(time.Now())
+	msg, ok := lastOfType(drain(t, s), "state", "delta")
+	if !ok {
+		t.Fatal("no state after input")
+	}
+	if msg.Ack != 1 {
+		t.Fatalf("ack = %d, want 1", msg.Ack)
+	}
+	if len(msg.Players) != 1 || msg.Players[0].X != x0+r.step {
+		t.Fatalf("players after move = %+v, want x=%v", msg.Players, x0+r.step)
+	}
+
+	// 旧序列不再生效，确认保持不变
+	s.Input(r, DirRight, 1)
+	r.runTick(time.Now())
+	r.runTick(time.Now())
+	if p := r.Players["alice"]; p.X != x0+r.step {
+		t.Fatalf("duplicate seq applied: x=%v", p.X)
+	}
+
+	s.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(r.Players) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("player not removed after session close")
+		}
+		time.Sleep(time.Millisecond)
+		r.runTick(time.Now())
+	}
+}
+
+func TestMemSessionReconnectReplacesSession(t *testing.T) {
+	r := testRoom(t, nil)
+	old := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	old.Input(r, DirDown, 1)
+	r.runTick(time.Now())
+
+	s := ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	select {
+	case <-old.Done():
+	default:
+		t.Fatal("old session not closed on reconnect")
+	}
+	snap, ok := lastOfType(drain(t, s), "snapshot")
+	if !ok || snap.Ack != 1 {
+		t.Fatalf("reconnect snapshot = %+v (ok=%v), want ack 1", snap, ok)
+	}
+	if p := r.Players["alice"]; p == nil || p.Conn != s {
+		t.Fatal("player not attached to new session")
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"maps"
+	"math"
+)
+
+// PlayerStats 玩家本局统计（开局时清零；未启用对局流程时自房间创建起累计）
+type PlayerStats struct {
+	Distance       float64        `json:"distance"`       // 移动距离（世界单位）
+	InputsAccepted int64          `json:"inputsAccepted"` // 通过去重与限流的输入
+	InputsLimited  int64          `json:"inputsLimited"`  // 被每 Tick 限流丢弃的输入
+	AliveMs        int64          `json:"aliveMs"`        // 对局进行中在线的时长
+	Mode           map[string]int `json:"mode,omitempty"` // 玩法统计，如 kills、captures
+}
+
+// clone 拷贝统计（结算结果与历史记录不随后续 Tick 变化）
+func (s *PlayerStats) clone() *PlayerStats {
+	c := *s
+	c.Mode = maps.Clone(s.Mode)
+	return &c
+}
+
+// scoreboardMessage 记分板：当前分数、名次与统计
+type scoreboardMessage struct {
+	Type    string         `json:"type"`
+	Tick    int64          `json:"tick"`
+	Match   int64          `json:"match"`
+	Phase   Phase          `json:"phase"`
+	Players []Standing     `json:"players"`
+	Teams   []TeamStanding `json:"teams,omitempty"`
+}
+
+// playerStats 玩家的本局统计（不存在时创建）
+func (r *Room) playerStats(id PlayerID) *PlayerStats {
+	s, ok := r.stats[id]
+	if !ok {
+		s = &PlayerStats{}
+		r.stats[id] = s
+	}
+	return s
+}
+
+// AddStat 累加玩家的玩法统计（如 kills、captures），随记分板与结算下发
+func (r *Room) AddStat(id PlayerID, key string, n int) {
+	s := r.playerStats(id)
+	if s.Mode == nil {
+		s.Mode = make(map[string]int)
+	}
+	s.Mode[key] += n
+}
+
+// resetStats 开局清零统计
+func (r *Room) resetStats() {
+	clear(r.stats)
+}
+
+// recordMove 记录一条已接受输入造成的位移
+func (r *Room) recordMove(p *Player, x, y float64) {
+	s := r.playerStats(p.ID)
+	s.InputsAccepted++
+	s.Distance += math.Hypot(p.X-x, p.Y-y)
+}
+
+// advanceStats 每帧推进在线时长与定时记分板（Tick 线程，对局进行中）
+func (r *Room) advanceStats() {
+	ms := r.TickInterval().Milliseconds()
+	for id := range r.Players {
+		r.playerStats(id).AliveMs += ms
+	}
+	if r.scoreboardInterval > 0 && r.tickSeq%int64(r.ticksFor(r.scoreboardInterval)) == 0 {
+		r.broadcastControl("scoreboard", r.scoreboard())
+	}
+}
+
+// scoreboard 当前记分板
+func (r *Room) scoreboard() scoreboardMessage {
+	return scoreboardMessage{
+		Type:    "scoreboard",
+		Tick:    r.tickSeq,
+		Match:   r.match.seq,
+		Phase:   r.match.phase,
+		Players: r.standings(),
+		Teams:   r.teamStandings(),
+	}
+}
+
+func init() {
+	RegisterMessage("scoreboard", MessageHandler{
+		Dispatch: OnTick,
+		Handle: func(ctx *MessageContext, _ any) error {
+			ctx.Room.SendScoreboard(ctx.Player)
+			return nil
+		},
+	}.Limit(2, 4))
+}
+
+// SendScoreboard 向单个玩家发送记分板（客户端 scoreboard 请求）
+func (r *Room) SendScoreboard(id PlayerID) {
+	p, ok := r.Players[id]
+	if !ok {
+		return
+	}
+	f := controlFrame(r.scoreboard())
+	r.send(p, f, false)
+	f.Release()
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// scoreboards 会话收到的记分板，以及 ref 为 scoreboard 的错误码
+func scoreboards(t *testing.T, s *MemSession) (boards []scoreboardMessage, errs []string) {
+	t.Helper()
+	for {
+		select {
+		case b := <-s.Recv():
+			var m errorMessage
+			if err := json.Unmarshal(b, &m); err != nil {
+				t.Fatalf("invalid message %s: %v", b, err)
+			}
+			switch {
+			case m.Type == "scoreboard":
+				var sb scoreboardMessage
+				if err := json.Unmarshal(b, &sb); err != nil {
+					t.Fatal(err)
+				}
+				boards = append(boards, sb)
+			case m.Type == "error" && m.Ref == "scoreboard":
+				errs = append(errs, m.Code)
+			}
+		default:
+			return boards, errs
+		}
+	}
+}
+
+func TestScoreboardRequest(t *testing.T) {
+	r := kothRoom(t, `{`+kothZones+`,"rotateMs":0}`, nil)
+	alice := ConnectMem(r, "alice", 256)
+	bob := ConnectMem(r, "bob", 256)
+	r.runTick(time.Now())
+	koth := r.mode.(*KingOfTheHill)
+	koth.addScore("bob")
+	koth.addScore("bob")
+	koth.addScore("alice")
+	r.AddStat("alice", "captures", 2)
+	scoreboards(t, alice)
+	scoreboards(t, bob)
+
+	newInbound(r, "alice").dispatch([]byte(`{"type":"scoreboard"}`))
+	r.runTick(time.Now())
+	boards, errs := scoreboards(t, alice)
+	if len(boards) != 1 || len(errs) != 0 {
+		t.Fatalf("got %d scoreboards, errors %v", len(boards), errs)
+	}
+	sb := boards[0]
+	if sb.Tick != r.tickSeq || len(sb.Players) != 2 {
+		t.Fatalf("scoreboard tick=%d players=%d", sb.Tick, len(sb.Players))
+	}
+	if first, second := sb.Players[0], sb.Players[1]; first.ID != "bob" || first.Rank != 1 || first.Score != 2 ||
+		second.ID != "alice" || second.Rank != 2 || second.Stats == nil || second.Stats.Mode["captures"] != 2 {
+		t.Fatalf("standings = %+v, %+v", first, second)
+	}
+	// 记分板只发给请求方
+	if boards, _ := scoreboards(t, bob); len(boards) != 0 {
+		t.Fatalf("bob received %d scoreboards", len(boards))
+	}
+}
+
+func TestScoreboardRequestLimited(t *testing.T) {
+	r := testRoom(t, nil)
+	s := ConnectMem(r, "alice", 256)
+	r.runTick(time.Now())
+	scoreboards(t, s)
+
+	in := newInbound(r, "alice")
+	for i := 0; i < 6; i++ {
+		in.dispatch([]byte(`{"type":"scoreboard"}`))
+	}
+	r.runTick(time.Now())
+	boards, errs := scoreboards(t, s)
+	if len(boards) != 4 || len(errs) != 2 || errs[0] != ErrCodeRateLimited {
+		t.Fatalf("got %d scoreboards, errors %v; want 4 and 2 rate_limited", len(boards), errs)
+	}
+}
+
+func TestScoreboardBroadcastDuringMatch(t *testing.T) {
+	r := testRoom(t, func(p *RoomProfile) {
+		p.TicksPerSecond = 10
+		p.ScoreboardIntervalMs = 300
+		p.Match = MatchConfig{Enabled: true, MinPlayers: 1, TimeLimitMs: 60000, AfterResults: AfterResultsReset}
+	})
+	s := ConnectMem(r, "alice", 256)
+	tickUntil(t, r, PhasePlaying, 5)
+	scoreboards(t, s)
+
+	for i := 0; i < 9; i++ {
+		r.runTick(time.Now())
+	}
+	boards, _ := scoreboards(t, s)
+	if len(boards) != 3 {
+		t.Fatalf("got %d scoreboards in 9 ticks at 3-tick interval, want 3", len(boards))
+	}
+	for _, sb := range boards {
+		if sb.Phase != PhasePlaying || sb.Tick%3 != 0 {
+			t.Fatalf("scoreboard phase=%s tick=%d", sb.Phase, sb.Tick)
+		}
+	}
+}
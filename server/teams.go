@@ -0,0 +1,248 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Rect 轴对齐矩形区域（世界坐标）
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Contains 点是否在区域内（含边界）
+func (a Rect) Contains(x, y float64) bool {
+	return x >= a.X && x <= a.X+a.W && y >= a.Y && y <= a.Y+a.H
+}
+
+func (a Rect) validate(width, height float64) error {
+	if a.W < 0 || a.H < 0 {
+		return fmt.Errorf("negative size")
+	}
+	if a.X < 0 || a.Y < 0 || a.X+a.W > width || a.Y+a.H > height {
+		return fmt.Errorf("outside world bounds")
+	}
+	return nil
+}
+
+// TeamConfig 队伍定义
+type TeamConfig struct {
+	ID    string `json:"id"`
+	Color string `json:"color"`
+	Spawn *Rect  `json:"spawn,omitempty"` // 出生区域（区域内随机），为空时使用房间出生点
+}
+
+// Team 房间内队伍的运行期状态（Tick 线程访问）
+type Team struct {
+	TeamConfig
+	Score   int
+	members int
+}
+
+// TeamStanding 队伍的对局名次
+type TeamStanding struct {
+	ID    string `json:"id"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank"`
+}
+
+// JoinRequest 加入房间时的可选参数
+type JoinRequest struct {
+	Team string // 期望加入的队伍；不存在或会破坏人数平衡时自动分配
+}
+
+// validateTeams 检查队伍配置
+func validateTeams(teams []TeamConfig, width, height float64, imbalance int) error {
+	seen := make(map[string]bool, len(teams))
+	for _, t := range teams {
+		if t.ID == "" {
+			return fmt.Errorf("team id is required")
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("duplicate team id: %q", t.ID)
+		}
+		seen[t.ID] = true
+		if t.Spawn != nil {
+			if err := t.Spawn.validate(width, height); err != nil {
+				return fmt.Errorf("team %s spawn: %w", t.ID, err)
+			}
+		}
+	}
+	if len(teams) > 0 && imbalance < 1 {
+		return fmt.Errorf("maxTeamImbalance must be at least 1")
+	}
+	return nil
+}
+
+// initTeams 按配置创建队伍（仅在房间创建时）
+func (r *Room) initTeams(p RoomProfile) {
+	r.teamOf = make(map[PlayerID]string)
+	r.maxTeamImbalance = p.MaxTeamImbalance
+	for _, tc := range p.Teams {
+		r.teams = append(r.teams, &Team{TeamConfig: tc})
+	}
+}
+
+// Teams 房间的队伍（无队伍时为空）
+func (r *Room) Teams() []*Team { return r.teams }
+
+// TeamByID 按 ID 查找队伍
+func (r *Room) TeamByID(id string) *Team {
+	for _, t := range r.teams {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// assignTeam 为加入的玩家选择队伍：重连保持原队伍；期望队伍在人数差不超过上限时生效；否则进入人数最少的队伍
+func (r *Room) assignTeam(id PlayerID, requested string) *Team {
+	if len(r.teams) == 0 {
+		return nil
+	}
+	if t := r.TeamByID(r.teamOf[id]); t != nil {
+		return t
+	}
+	if t := r.TeamByID(requested); t != nil && r.balancedWith(t) {
+		return t
+	}
+	best := r.teams[0]
+	for _, t := range r.teams[1:] {
+		if t.members < best.members || (t.members == best.members && t.Score < best.Score) {
+			best = t
+		}
+	}
+	return best
+}
+
+// balancedWith 向 t 加入一人后各队人数差是否仍在上限内
+func (r *Room) balancedWith(t *Team) bool {
+	minN := t.members + 1
+	maxN := minN
+	for _, o := range r.teams {
+		if o == t {
+			continue
+		}
+		minN = min(minN, o.members)
+		maxN = max(maxN, o.members)
+	}
+	return maxN-minN <= r.maxTeamImbalance
+}
+
+// joinTeam 将在线玩家放入队伍
+func (r *Room) joinTeam(p *Player, t *Team) {
+	if t == nil {
+		return
+	}
+	p.Team = t.ID
+	t.members++
+	r.teamOf[p.ID] = t.ID
+}
+
+// leaveTeam 玩家离线：释放名额，保留归属供重连
+func (r *Room) leaveTeam(p *Player) {
+	if t := r.TeamByID(p.Team); t != nil {
+		t.members--
+	}
+}
+
+// SetTeam 将玩家移到指定队伍（管理接口）；玩家离线时修改其重连后的归属
+func (r *Room) SetTeam(id PlayerID, team string) error {
+	t := r.TeamByID(team)
+	if t == nil {
+		return fmt.Errorf("unknown team: %q", team)
+	}
+	if p, ok := r.Players[id]; ok {
+		if p.Team == t.ID {
+			return nil
+		}
+		r.leaveTeam(p)
+		r.joinTeam(p, t)
+	} else {
+		r.teamOf[id] = t.ID
+	}
+	Log.Infof("team changed: room=%s player=%s team=%s", r.ID, id, t.ID)
+	return nil
+}
+
+// AddTeamScore 增加队伍分数，随下一帧广播
+func (r *Room) AddTeamScore(team string, n int) {
+	if t := r.TeamByID(team); t != nil && n != 0 {
+		t.Score += n
+		r.teamsDirty = true
+	}
+}
+
+// resetTeamScores 开局清零队伍分数
+func (r *Room) resetTeamScores() {
+	for _, t := range r.teams {
+		t.Score = 0
+	}
+	r.teamsDirty = len(r.teams) > 0
+}
+
+// SpawnPoint 玩家的出生位置：所在队伍配置了出生区域时在区域内随机，否则为房间出生点
+func (r *Room) SpawnPoint(p *Player) (x, y float64) {
+	if t := r.TeamByID(p.Team); t != nil && t.Spawn != nil {
+		s := t.Spawn
+		return s.X + r.rng.Float64()*s.W, s.Y + r.rng.Float64()*s.H
+	}
+	return r.spawnX, r.spawnY
+}
+
+// BroadcastTeam 向某个队伍的在线玩家发送可靠控制消息（队内事件，不进入公开事件流）
+func (r *Room) BroadcastTeam(team string, v any) {
+	f := controlFrame(v)
+	for _, p := range r.Players {
+		if p.Team == team {
+			r.send(p, f, false)
+		}
+	}
+	f.Release()
+}
+
+// teamStandings 按队伍分数排名
+func (r *Room) teamStandings() []TeamStanding {
+	if len(r.teams) == 0 {
+		return nil
+	}
+	out := make([]TeamStanding, 0, len(r.teams))
+	for _, t := range r.teams {
+		out = append(out, TeamStanding{ID: t.ID, Score: t.Score})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	for i := range out {
+		if i > 0 && out[i].Score == out[i-1].Score {
+			out[i].Rank = out[i-1].Rank
+		} else {
+			out[i].Rank = i + 1
+		}
+	}
+	return out
+}
+
+// appendTeams 编码 ,"teams":[{"id":..,"color":..,"score":..}]（无队伍时不输出）
+func (r *Room) appendTeams(b []byte) []byte {
+	if len(r.teams) == 0 {
+		return b
+	}
+	b = append(b, `,"teams":[`...)
+	for i, t := range r.teams {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"id":`...)
+		b = appendJSONString(b, t.ID)
+		b = append(b, `,"color":`...)
+		b = appendJSONString(b, t.Color)
+		b = append(b, `,"score":`...)
+		b = strconv.AppendInt(b, int64(t.Score), 10)
+		b = append(b, '}')
+	}
+	return append(b, ']')
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// teamsRoom 红蓝两队、人数差上限为 1 的测试房间
+func teamsRoom(t *testing.T) *Room {
+	t.Helper()
+	return testRoom(t, func(p *RoomProfile) {
+		p.Teams = []TeamConfig{{ID: "red"}, {ID: "blue"}}
+		p.MaxTeamImbalance = 1
+	})
+}
+
+// teamCounts 各队在线人数
+func teamCounts(r *Room) map[string]int {
+	out := make(map[string]int)
+	for _, p := range r.Players {
+		out[p.Team]++
+	}
+	return out
+}
+
+func TestTeamsAutoAssignBalanced(t *testing.T) {
+	r := teamsRoom(t)
+	for _, id := range []PlayerID{"alice", "bob", "carol", "dave"} {
+		joinTeam(r, id, "")
+		r.runTick(time.Now())
+	}
+	if c := teamCounts(r); c["red"] != 2 || c["blue"] != 2 {
+		t.Fatalf("team sizes = %v, want 2/2", c)
+	}
+	for _, tm := range r.Teams() {
+		if tm.members != 2 {
+			t.Fatalf("team %s members = %d, want 2", tm.ID, tm.members)
+		}
+	}
+}
+
+func TestTeamsRequestedTeamRespectsImbalance(t *testing.T) {
+	r := teamsRoom(t)
+	joinTeam(r, "alice", "red")
+	joinTeam(r, "bob", "red") // 红队 2 人、蓝队 0 人超出上限
+	joinTeam(r, "carol", "red")
+	joinTeam(r, "dave", "green") // 不存在的队伍
+	r.runTick(time.Now())
+	want := map[PlayerID]string{"alice": "red", "bob": "blue", "carol": "red", "dave": "blue"}
+	for id, team := range want {
+		if got := r.Players[id].Team; got != team {
+			t.Fatalf("%s team = %q, want %q", id, got, team)
+		}
+	}
+}
+
+func TestTeamsLeaveFreesSlotAndReconnectKeepsTeam(t *testing.T) {
+	r := teamsRoom(t)
+	joinTeam(r, "alice", "red")
+	bob := joinTeam(r, "bob", "blue")
+	joinTeam(r, "carol", "red")
+	r.runTick(time.Now())
+
+	bob.Close()
+	waitLeft(t, r, "bob")
+	if tm := r.TeamByID("blue"); tm.members != 0 {
+		t.Fatalf("blue members after leave = %d, want 0", tm.members)
+	}
+	// 空出的名额由下一位加入者补上，即使其期望的是人多的队伍
+	joinTeam(r, "dave", "red")
+	r.runTick(time.Now())
+	if got := r.Players["dave"].Team; got != "blue" {
+		t.Fatalf("dave team = %q, want blue", got)
+	}
+
+	// 重连保持原队伍（不受人数平衡约束）
+	joinTeam(r, "bob", "red")
+	r.runTick(time.Now())
+	if got := r.Players["bob"].Team; got != "blue" {
+		t.Fatalf("bob team after reconnect = %q, want blue", got)
+	}
+	if c := teamCounts(r); c["red"] != 2 || c["blue"] != 2 {
+		t.Fatalf("team sizes = %v, want 2/2", c)
+	}
+}
+
+func TestSetTeam(t *testing.T) {
+	r := teamsRoom(t)
+	joinTeam(r, "alice", "red")
+	r.runTick(time.Now())
+
+	if err := r.SetTeam("alice", "green"); err == nil {
+		t.Fatal("unknown team accepted")
+	}
+	if err := r.SetTeam("alice", "blue"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Players["alice"].Team != "blue" || r.TeamByID("red").members != 0 || r.TeamByID("blue").members != 1 {
+		t.Fatalf("after move: team=%q red=%d blue=%d", r.Players["alice"].Team, r.TeamByID("red").members, r.TeamByID("blue").members)
+	}
+
+	// 离线玩家：修改重连后的归属
+	if err := r.SetTeam("bob", "blue"); err != nil {
+		t.Fatal(err)
+	}
+	joinTeam(r, "bob", "red")
+	r.runTick(time.Now())
+	if got := r.Players["bob"].Team; got != "blue" {
+		t.Fatalf("bob team = %q, want blue", got)
+	}
+}
+
+func TestAdminSetTeamUnknownTeam(t *testing.T) {
+	r := managedRoom(t)
+	code, _ := serve(HandleAdminRooms, http.MethodPost, "/admin/rooms/"+r.ID+"/players/alice/team", `{"team":"green"}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("unknown team = %d, want 400", code)
+	}
+}
@@ -3,32 +3,162 @@ package server
 import "time"
 
 const (
-	// TicksPerSecond 世界推进频率（20 TPS）
+	// TicksPerSecond 默认世界推进频率（20 TPS），可由房间配置 ticksPerSecond 覆盖
 	TicksPerSecond = 20
+	// MaxTicksPerSecond 允许的最高 Tick 频率
+	MaxTicksPerSecond = 1000
 )
 
-var tickInterval = time.Duration(1000/TicksPerSecond) * time.Millisecond // 50ms
+// 追帧策略：Tick 落后于墙钟时如何处理
+const (
+	CatchUpRun  = "catchup" // 补跑落后的 Tick（不超过上限），超出部分跳过
+	CatchUpSkip = "skip"    // 不补跑：跳过落后的 Tick，从当前时刻重新对齐
+
+	// catchUpSlowdown skip 的旧名称（并未放慢模拟），仍被配置接受
+	catchUpSlowdown = "slowdown"
+)
+
+// TickRate 房间当前 Tick 频率（并发安全）
+func (r *Room) TickRate() int {
+	return int(r.tickRate.Load())
+}
+
+// TickInterval 房间当前 Tick 间隔（默认 50ms）
+func (r *Room) TickInterval() time.Duration {
+	return time.Second / time.Duration(r.tickRate.Load())
+}
+
+// ticksFor 将时长换算为当前频率下的帧数（至少 1 帧）
+func (r *Room) ticksFor(d time.Duration) int {
+	n := int(d / r.TickInterval())
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
 
-// StartTicker 启动房间的 Tick 循环（单线程推进世界）
+// setTickRate 修改 Tick 频率（Tick 线程中调用），下一次调度即按新间隔执行
+// 按秒定义的参数在此换算：每条输入的步长与每 Tick 的输入额度，保证移动速度与输入吞吐不随频率变化
+func (r *Room) setTickRate(tps int) {
+	r.step = r.speed / float64(r.maxInputsPerSecond)
+	r.inputsPerTick = float64(r.maxInputsPerSecond) / float64(tps)
+	old := int64(r.tickRate.Load())
+	if old == int64(tps) {
+		return
+	}
+	// 对局阶段的剩余时长按墙钟保持，换算为新频率下的帧数
+	if m := &r.match; m.endsAt > r.tickSeq {
+		m.endsAt = r.tickSeq + max((m.endsAt-r.tickSeq)*int64(tps)/old, 1)
+	}
+	r.tickRate.Store(int32(tps))
+	// 清空增量基线，下一帧向客户端广播带新频率的全量 state
+	clear(r.lastBroadcast)
+	Log.Infof("tick rate changed: room=%s tps=%d", r.ID, tps)
+}
+
+// SetTickRate 请求在 Tick 线程中修改频率（管理接口）
+func (r *Room) SetTickRate(tps int) error {
+	if err := validateTickRate(tps); err != nil {
+		return err
+	}
+	r.Do(func() { r.setTickRate(tps) })
+	return nil
+}
+
+// StartTicker 启动房间的固定步长 Tick 循环（单线程推进世界）
+// 按配置 server.scheduler 选择独立协程或工作协程池
 func (r *Room) StartTicker() {
 	if r.tickerStarted {
 		return
 	}
 	r.tickerStarted = true
-	go func() {
-		ticker := time.NewTicker(tickInterval)
-		defer ticker.Stop()
-		for range ticker.C {
-			// 核心循环：处理输入 → 更新世界 → 广播结果
-			start := time.Now()
-			r.BeginTick() // 同一 Tick 时间线：重置输入计数等帧内状态
-			r.ProcessInputs()
-			r.UpdateWorld()
-			r.BroadcastDelta()
-			elapsed := time.Since(start)
-			if r.metrics != nil {
-				r.metrics.AddTick(elapsed.Nanoseconds())
-			}
+	if p := getTickPool(); p != nil {
+		p.schedule(r)
+		return
+	}
+	go r.tickLoop()
+}
+
+// tickLoop 独立协程模式：本房间专用计时器驱动
+func (r *Room) tickLoop() {
+	next := time.Now().Add(r.TickInterval())
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			next = r.advance(next)
+			timer.Reset(time.Until(next))
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// advance 执行计划于 due 的 Tick，并按追帧策略处理落后，返回下一次计划时间
+func (r *Room) advance(due time.Time) time.Time {
+	interval := r.TickInterval()
+	r.runTick(due)
+	// 本帧结束时已落后的完整 Tick 数（含调度延迟与本帧耗时）
+	behind := int(time.Since(due) / interval)
+	if behind <= 0 {
+		return due.Add(interval)
+	}
+	if r.catchUpPolicy != CatchUpRun {
+		// 跳过：丢弃落后的 Tick，从当前时刻重新对齐（这段墙钟时间内世界不推进）
+		r.metrics.AddSkipped(behind)
+		return time.Now().Add(interval)
+	}
+	extra := behind
+	if extra > r.maxCatchUpTicks {
+		extra = r.maxCatchUpTicks
+	}
+	for i := 1; i <= extra; i++ {
+		r.runTick(due.Add(time.Duration(i) * interval))
+	}
+	r.metrics.AddCatchUp(extra)
+	if skipped := behind - extra; skipped > 0 {
+		r.metrics.AddSkipped(skipped)
+	}
+	// 对齐到原有时间网格，保持节拍稳定
+	return due.Add(time.Duration(behind+1) * interval)
+}
+
+// runTick 执行一帧：处理输入 → 更新世界 → 广播结果；帧内 panic 被隔离在本房间
+func (r *Room) runTick(scheduled time.Time) {
+	r.tickMu.Lock()
+	defer r.tickMu.Unlock()
+	if r.closed.Load() {
+		return
+	}
+	start := time.Now()
+	allocsBefore := r.allocs.read()
+	r.markTick(start)
+	defer func() {
+		if v := recover(); v != nil {
+			r.onTickPanic(v)
 		}
 	}()
+	if r.metrics != nil {
+		r.metrics.ObserveLateness(start.Sub(scheduled).Nanoseconds())
+	}
+	r.BeginTick() // 同一 Tick 时间线：重置输入计数等帧内状态
+	r.ProcessInputs()
+	t1 := time.Now()
+	r.UpdateWorld()
+	t2 := time.Now()
+	r.BroadcastDelta()
+	r.flushOutbound()
+	t3 := time.Now()
+	r.afterGoodTick()
+	elapsed := time.Since(start)
+	if r.metrics != nil {
+		r.metrics.ObservePhases(t1.Sub(start), t2.Sub(t1), t3.Sub(t2))
+		r.metrics.AddTick(elapsed.Nanoseconds())
+		r.metrics.AddTickAllocs(r.allocs.read() - allocsBefore)
+		if elapsed > r.TickInterval() {
+			r.metrics.IncOverrun()
+		}
+		r.metrics.SetPlayers(len(r.Players))
+	}
 }
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tickRoom 10 TPS（100ms 间隔）的测试房间
+func tickRoom(t *testing.T, policy string, maxCatchUp int) *Room {
+	t.Helper()
+	return testRoom(t, func(p *RoomProfile) {
+		p.TicksPerSecond = 10
+		p.CatchUpPolicy = policy
+		p.MaxCatchUpTicks = maxCatchUp
+	})
+}
+
+func TestAdvanceOnTime(t *testing.T) {
+	r := tickRoom(t, CatchUpRun, 5)
+	due := time.Now()
+	next := r.advance(due)
+	if r.Tick() != 1 || !next.Equal(due.Add(r.TickInterval())) {
+		t.Fatalf("tick=%d next=%v, want 1 tick and due+interval", r.Tick(), next.Sub(due))
+	}
+}
+
+func TestAdvanceCatchesUp(t *testing.T) {
+	r := tickRoom(t, CatchUpRun, 5)
+	interval := r.TickInterval()
+	due := time.Now().Add(-3*interval - interval/2)
+	next := r.advance(due)
+	if r.Tick() != 4 {
+		t.Fatalf("ticks run = %d, want 1 + 3 catch-up", r.Tick())
+	}
+	if got := atomic.LoadInt64(&r.metrics.CatchUpTicks); got != 3 {
+		t.Fatalf("catch-up metric = %d, want 3", got)
+	}
+	if !next.Equal(due.Add(4 * interval)) {
+		t.Fatalf("next = due+%v, want due+%v (original grid)", next.Sub(due), 4*interval)
+	}
+}
+
+func TestAdvanceSkipsBeyondMaxCatchUp(t *testing.T) {
+	r := tickRoom(t, CatchUpRun, 2)
+	interval := r.TickInterval()
+	due := time.Now().Add(-10*interval - interval/2)
+	next := r.advance(due)
+	if r.Tick() != 3 {
+		t.Fatalf("ticks run = %d, want 1 + 2 catch-up", r.Tick())
+	}
+	if c, s := atomic.LoadInt64(&r.metrics.CatchUpTicks), atomic.LoadInt64(&r.metrics.SkippedTicks); c != 2 || s != 8 {
+		t.Fatalf("catch-up=%d skipped=%d, want 2 and 8", c, s)
+	}
+	if !next.Equal(due.Add(11 * interval)) {
+		t.Fatalf("next = due+%v, want due+%v", next.Sub(due), 11*interval)
+	}
+}
+
+func TestAdvanceSkipPolicyRealigns(t *testing.T) {
+	r := tickRoom(t, CatchUpSkip, 5)
+	interval := r.TickInterval()
+	due := time.Now().Add(-3*interval - interval/2)
+	before := time.Now()
+	next := r.advance(due)
+	if r.Tick() != 1 || atomic.LoadInt64(&r.metrics.SkippedTicks) != 3 || atomic.LoadInt64(&r.metrics.CatchUpTicks) != 0 {
+		t.Fatalf("tick=%d skipped=%d catch-up=%d, want 1, 3, 0",
+			r.Tick(), r.metrics.SkippedTicks, r.metrics.CatchUpTicks)
+	}
+	if next.Before(before.Add(interval)) || next.After(time.Now().Add(interval)) {
+		t.Fatalf("next not realigned to now+interval: %v", time.Until(next))
+	}
+}
+
+func TestSetTickRateRescalesPerTickValues(t *testing.T) {
+	r := testRoom(t, func(p *RoomProfile) {
+		p.TicksPerSecond = 10
+		p.Speed = 30
+		p.MaxInputsPerSecond = 15
+	})
+	if r.step != 2 || r.inputsPerTick != 1.5 {
+		t.Fatalf("at 10 TPS: step=%v inputsPerTick=%v, want 2 and 1.5", r.step, r.inputsPerTick)
+	}
+	ConnectMem(r, "alice", 64)
+	r.runTick(time.Now())
+	if len(r.lastBroadcast) == 0 {
+		t.Fatal("no delta baseline after first tick")
+	}
+
+	r.setTickRate(30)
+	if r.TickRate() != 30 || r.TickInterval() != time.Second/30 {
+		t.Fatalf("tick rate = %d interval = %v", r.TickRate(), r.TickInterval())
+	}
+	// 步长按输入定义不随频率变化，每 Tick 额度按频率缩放，上限至少一条
+	if r.step != 2 || r.inputsPerTick != 0.5 || r.inputBurst() != 1 {
+		t.Fatalf("at 30 TPS: step=%v inputsPerTick=%v burst=%v", r.step, r.inputsPerTick, r.inputBurst())
+	}
+	if len(r.lastBroadcast) != 0 {
+		t.Fatal("delta baseline kept across tick rate change")
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// StartWatchdog 周期检查所有房间的 Tick 心跳，标记停滞的房间
+func StartWatchdog() {
+	cfg := CurrentConfig().Server.Watchdog
+	go func() {
+		t := time.NewTicker(time.Duration(cfg.CheckIntervalMs) * time.Millisecond)
+		defer t.Stop()
+		for now := range t.C {
+			for _, r := range GetRoomManager().Rooms() {
+				r.checkStall(now, cfg.StallTicks)
+			}
+		}
+	}()
+}
+
+// checkStall 超过 stallTicks 个 Tick 间隔未推进则判定停滞（状态变化时记录日志）
+func (r *Room) checkStall(now time.Time, stallTicks int) {
+	last := atomic.LoadInt64(&r.health.lastTickAt)
+	if last == 0 {
+		return
+	}
+	behind := now.Sub(time.Unix(0, last))
+	stalled := behind > time.Duration(stallTicks)*r.TickInterval()
+	if r.health.stalled.Swap(stalled) != stalled {
+		if stalled {
+			Log.Errorf("room stalled: room=%s last_tick_ago=%s", r.ID, behind)
+		} else {
+			Log.Infof("room resumed: room=%s", r.ID)
+		}
+	}
+}
+
+// roomHealthReport /healthz 中单个房间的状态
+type roomHealthReport struct {
+	Room          string `json:"room"`
+	Status        string `json:"status"` // stalled / recovered / closed
+	Faults        int64  `json:"faults,omitempty"`
+	LastFault     string `json:"lastFault,omitempty"`
+	LastTickAgoMs int64  `json:"lastTickAgoMs,omitempty"`
+}
+
+// HandleHealthz 健康检查：存在停滞房间时返回 503，并列出异常房间
+// GET /healthz
+func HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	rm := GetRoomManager()
+	now := time.Now()
+	status := http.StatusOK
+	var problems []roomHealthReport
+	for _, room := range rm.Rooms() {
+		faults := atomic.LoadInt64(&room.health.faults)
+		stalled := room.health.stalled.Load()
+		if !stalled && faults == 0 {
+			continue
+		}
+		rep := roomHealthReport{Room: room.ID, Status: "recovered", Faults: faults}
+		if v, ok := room.health.lastFault.Load().(string); ok {
+			rep.LastFault = v
+		}
+		if stalled {
+			rep.Status = "stalled"
+			rep.LastTickAgoMs = now.Sub(time.Unix(0, atomic.LoadInt64(&room.health.lastTickAt))).Milliseconds()
+			status = http.StatusServiceUnavailable
+		}
+		problems = append(problems, rep)
+	}
+	rm.mu.RLock()
+	for id, reason := range rm.closedFaulted {
+		problems = append(problems, roomHealthReport{Room: id, Status: "closed", LastFault: reason})
+	}
+	rm.mu.RUnlock()
+
+	body := map[string]any{"status": "ok"}
+	if status != http.StatusOK {
+		body["status"] = "degraded"
+	}
+	if len(problems) > 0 {
+		body["rooms"] = problems
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// healthz 调用 /healthz，返回状态码与指定房间的状态（未列出时为空）
+func healthz(t *testing.T, roomID string) (int, string) {
+	t.Helper()
+	code, body := serve(HandleHealthz, http.MethodGet, "/healthz", "")
+	var resp struct {
+		Rooms []roomHealthReport `json:"rooms"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("invalid healthz body %s: %v", body, err)
+	}
+	for _, rep := range resp.Rooms {
+		if rep.Room == roomID {
+			return code, rep.Status
+		}
+	}
+	return code, ""
+}
+
+// waitStall 反复执行看门狗检查，直到房间停滞状态变为 want
+func waitStall(t *testing.T, r *Room, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		r.checkStall(time.Now(), 2)
+		if r.health.stalled.Load() == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stalled = %v, want %v", !want, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchdogStalledTickFlipsHealthz(t *testing.T) {
+	r := managedRoom(t)
+	for atomic.LoadInt64(&r.health.lastTickAt) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if code, status := healthz(t, r.ID); code != http.StatusOK || status != "" {
+		t.Fatalf("healthy room: code=%d status=%q", code, status)
+	}
+
+	// 控制命令在 Tick 线程中执行，阻塞它即令 Tick 停滞
+	block := make(chan struct{})
+	r.Do(func() { <-block })
+	waitStall(t, r, true)
+	if code, status := healthz(t, r.ID); code != http.StatusServiceUnavailable || status != "stalled" {
+		t.Fatalf("stalled room: code=%d status=%q", code, status)
+	}
+
+	close(block)
+	waitStall(t, r, false)
+	if code, status := healthz(t, r.ID); code != http.StatusOK || status != "" {
+		t.Fatalf("resumed room: code=%d status=%q", code, status)
+	}
+}
@@ -1,6 +1,7 @@
 let ws = null;
 const logEl = document.getElementById('log');
 const statusEl = document.getElementById('status');
+const phaseEl = document.getElementById('phase');
 const cv = document.getElementById('cv');
 const ctx = cv.getContext('2d');
 
@@ -12,6 +13,8 @@ let localPlayers = {};  // 客户端当前展示的世界（含预测）
 let reconcileTarget = null; // 我的目标位置（服务器裁决+未确认重演）
 let animating = false;
 let lastAuthMy = null; // 最近一次服务器确认的我的权威位置
+let playerTeams = {};  // 玩家 → 队伍
+let teamColors = {};   // 队伍 → 颜色
 
 function log(msg) {
   const p = document.createElement('div');
@@ -40,7 +43,7 @@ function drawPlayers() {
     const p = localPlayers[id];
     const x = p.x * scale;
     const y = p.y * scale;
-    ctx.fillStyle = '#1e88e5';
+    ctx.fillStyle = teamColors[playerTeams[id]] || '#1e88e5';
     ctx.beginPath();
     ctx.arc(x, y, 6, 0, Math.PI*2);
     ctx.fill();
@@ -97,18 +100,21 @@ function connect() {
   ws.onmessage = (ev) => {
     try {
       const msg = JSON.parse(ev.data);
+      // 队伍归属与颜色（分队房间）
+      for (const t of (msg.teams || [])) teamColors[t.id] = t.color;
+      for (const p of (msg.players || [])) if (p.team) playerTeams[p.id] = p.team;
       if (msg.type === 'state' || msg.type === 'snapshot') {
         // 权威状态（服务器裁决）
         const auth = {};
         for (const p of (msg.players || [])) auth[p.id] = {x:p.x, y:p.y};
-        log(`recv ${msg.type} tick=${msg.tick} myId=${myId} ack=${msg.acks?msg.acks[myId]:0} players=[${Object.keys(auth).join(',')}]`);
+        log(`recv ${msg.type} tick=${msg.tick} myId=${myId} ack=${msg.ack||0} players=[${Object.keys(auth).join(',')}]`);
         // 初始化 localPlayers 中其他人的位置为权威值
         for (const id of Object.keys(auth)) {
           if (id !== myId) localPlayers[id] = {x:auth[id].x, y:auth[id].y};
         }
-        // 处理我的未确认输入的确认序列
+        // 处理我的未确认输入的确认序列（服务端只下发本连接的 ack）
         let ack = 0;
-        if (msg.acks && msg.acks[myId] != null) ack = msg.acks[myId];
+        if (msg.ack != null) ack = msg.ack;
         pendingInputs = pendingInputs.filter(it => it.seq > ack);
         // 关键修正：根据服务器确认序列推进 nextSeq，避免重连后继续从 1 发送被判旧包
         if (ack + 1 > nextSeq) {
@@ -137,7 +143,7 @@ function connect() {
       else if (msg.type === 'delta') {
         const auth = {};
         for (const p of (msg.players || [])) auth[p.id] = {x:p.x, y:p.y};
-        log(`recv delta tick=${msg.tick} myId=${myId} ack=${msg.acks?msg.acks[myId]:0} changed=[${Object.keys(auth).join(',')}] removed=[${(msg.removed||[]).join(',')}]`);
+        log(`recv delta tick=${msg.tick} myId=${myId} ack=${msg.ack||0} changed=[${Object.keys(auth).join(',')}] removed=[${(msg.removed||[]).join(',')}]`);
         // 应用 removed
         for (const id of (msg.removed || [])) {
           delete localPlayers[id];
@@ -149,7 +155,7 @@ function connect() {
         }
         // ack 推进与未确认过滤
         let ack = 0;
-        if (msg.acks && msg.acks[myId] != null) ack = msg.acks[myId];
+        if (msg.ack != null) ack = msg.ack;
         pendingInputs = pendingInputs.filter(it => it.seq > ack);
         if (ack + 1 > nextSeq) {
           nextSeq = ack + 1;
@@ -168,11 +174,55 @@ function connect() {
         if (!localPlayers[myId]) localPlayers[myId] = desired;
         startAnimation();
       }
+      else if (msg.type === 'phase') {
+        // 对局阶段：仅 playing 阶段移动生效
+        let text = msg.phase;
+        if (msg.remainingMs) text += ` (${Math.ceil(msg.remainingMs/1000)}s)`;
+        if (msg.minPlayers) text += ` 最少 ${msg.minPlayers} 人，已准备 [${(msg.ready||[]).join(',')}]`;
+        phaseEl.textContent = text;
+        log(`recv phase ${msg.phase} match=${msg.match} tick=${msg.tick}`);
+      }
+      else if (msg.type === 'match_result') {
+        log(`match ${msg.match} over (${msg.reason}): ` + (msg.players||[]).map(p => `#${p.rank} ${p.id} ${p.score}`).join(', '));
+      }
+      else if (msg.type === 'scoreboard') {
+        log(`scoreboard tick=${msg.tick}: ` + (msg.players||[]).map(p => {
+          const s = p.stats || {};
+          return `#${p.rank} ${p.id} ${p.score} dist=${(s.distance||0).toFixed(1)} alive=${Math.round((s.aliveMs||0)/1000)}s`;
+        }).join(', '));
+      }
+      else if (msg.type === 'chat') {
+        log(`[${msg.channel === 'team' ? 'team' : 'room'}] ${msg.name || msg.from}: ${msg.text}`);
+      }
+      else if (msg.type === 'error' && msg.ref === 'chat') {
+        log(`chat rejected: ${msg.code}`);
+      }
+      else if (msg.type === 'error') {
+        log(`error ${msg.code}` + (msg.ref ? ` (${msg.ref})` : '') + (msg.error ? `: ${msg.error}` : ''));
+      }
     } catch (e) {}
   };
 }
 
 document.getElementById('btnConnect').onclick = connect;
+document.getElementById('btnReady').onclick = () => {
+  if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify({type:'ready', ready:true}));
+};
+document.getElementById('btnScoreboard').onclick = () => {
+  if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify({type:'scoreboard'}));
+};
+function sendChat() {
+  const input = document.getElementById('chatText');
+  const text = input.value.trim();
+  if (!text || !ws || ws.readyState !== WebSocket.OPEN) return;
+  ws.send(JSON.stringify({type:'chat', channel: document.getElementById('chatChannel').value, text}));
+  input.value = '';
+}
+document.getElementById('btnChat').onclick = sendChat;
+document.getElementById('chatText').addEventListener('keydown', (e) => {
+  e.stopPropagation(); // 输入框内的方向键不发送 move
+  if (e.key === 'Enter') sendChat();
+});
 document.getElementById('btnDisconnect').onclick = () => { if (ws) { ws.close(); ws = null; }};
 
 window.addEventListener('keydown', (e) => {